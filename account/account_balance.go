@@ -3,15 +3,15 @@
 package account
 
 import (
+	"cmp"
 	"encoding/json"
-	"fmt"
 	"io"
 	"net/http"
-	"slices"
+	"strconv"
 
 	"github.com/coleYab/mpesasdk/common"
 	sdkError "github.com/coleYab/mpesasdk/errors"
-	"github.com/coleYab/mpesasdk/utils"
+	"github.com/coleYab/mpesasdk/validation"
 )
 
 // AccountBalanceRequest represents the parameters for querying the account balance for a shortcode.
@@ -43,26 +43,36 @@ type AccountBalanceRequest struct {
 
     // OriginatorConversationID is a unique identifier for the originator of the transaction.
     OriginatorConversationID string `json:"OriginatorConversationID"`
+
+    securityCredential string
+}
+
+// SetSecurityCredential sets the SecurityCredential used when the request leaves it empty.
+func (a *AccountBalanceRequest) SetSecurityCredential(credential string) {
+    a.securityCredential = credential
 }
 
 // AccountBalanceSuccessResponse represents the successful response to an account balance query.
 type AccountBalanceSuccessResponse common.MpesaSuccessResponse
 
 func (a *AccountBalanceRequest) DecodeResponse(res *http.Response) (interface{}, error) {
-    bodyData, _ := io.ReadAll(res.Body)
+    bodyData, err := io.ReadAll(res.Body)
+    if err != nil {
+        return AccountBalanceSuccessResponse{}, sdkError.NewResponseReadError(res.StatusCode, bodyData, err)
+    }
+
     responseData := AccountBalanceSuccessResponse{}
-    err := json.Unmarshal(bodyData, &responseData)
+    err = json.Unmarshal(bodyData, &responseData)
     if err != nil {
-        return AccountBalanceSuccessResponse{}, sdkError.ProcessingError(err.Error())
+        return AccountBalanceSuccessResponse{}, sdkError.NewUnexpectedResponseError(res.StatusCode, bodyData, err)
     }
 
     if responseData.ResponseCode != "0" {
-        errorResponseData := common.MpesaErrorResponse{}
-        err := json.Unmarshal(bodyData, &errorResponseData)
+        errorResponseData, err := common.ParseErrorResponse(bodyData)
         if err != nil {
-            return AccountBalanceSuccessResponse{}, sdkError.ProcessingError(err.Error())
+            return AccountBalanceSuccessResponse{}, sdkError.NewUnexpectedResponseError(res.StatusCode, bodyData, err)
         }
-        return AccountBalanceSuccessResponse{}, a.decodeError(errorResponseData)
+        return AccountBalanceSuccessResponse{}, a.decodeError(res, bodyData, errorResponseData)
     }
 
     return responseData, nil
@@ -70,24 +80,23 @@ func (a *AccountBalanceRequest) DecodeResponse(res *http.Response) (interface{},
 
 func (a *AccountBalanceRequest) FillDefaults() {
     a.CommandID = common.AccountBalanceCommand
+    if a.SecurityCredential == "" {
+        a.SecurityCredential = a.securityCredential
+    }
 }
 
 func (a *AccountBalanceRequest) Validate() error {
-    validIdentifiers := []common.IdentifierType{common.MsisdnIdentifierType, common.TillNumberIdentifierType, common.ShortCodeIdentifierType}
-    if !slices.Contains(validIdentifiers, a.IdentifierType) {
-        return sdkError.ValidationError("unknown identifier type")
-    }
-
-    if err := utils.ValidateURL(a.QueueTimeOutURL); err != nil {
-        return err
-    }
-
-    if err := utils.ValidateURL(a.ResultURL); err != nil {
-        return err
-    }
-    return nil
+    v := validation.New()
+    v.Required("Initiator", a.Initiator)
+    v.Required("SecurityCredential", cmp.Or(a.SecurityCredential, a.securityCredential))
+    v.OneOf("IdentifierType", a.IdentifierType, common.MsisdnIdentifierType, common.TillNumberIdentifierType, common.ShortCodeIdentifierType)
+    v.Party("PartyA", strconv.Itoa(a.PartyA), a.IdentifierType)
+    v.Length("Remarks", a.Remarks, 2, validation.MaxRemarksLength)
+    v.URL("QueueTimeOutURL", a.QueueTimeOutURL)
+    v.URL("ResultURL", a.ResultURL)
+    return v.Err()
 }
 
-func (a *AccountBalanceRequest) decodeError(e common.MpesaErrorResponse) error {
-    return sdkError.NewSDKError("REQUEST_ERROR", fmt.Sprintf("Request %v failed due to %v", e.RequestId, e.ErrorMessage))
+func (a *AccountBalanceRequest) decodeError(res *http.Response, body []byte, e common.MpesaErrorResponse) error {
+    return sdkError.NewAPIError(res.StatusCode, e.RequestId, e.ErrorCode, e.ErrorMessage, body)
 }
@@ -3,27 +3,67 @@
 package auth
 
 import (
+	"context"
 	"encoding/json"
-	"errors"
 	"fmt"
 	"io"
 	"net/http"
 	"strconv"
+	"sync"
 	"time"
 
+	"go.opentelemetry.io/otel/trace"
+
 	"github.com/coleYab/mpesasdk/common"
-	"github.com/coleYab/mpesasdk/service"
+	sdkError "github.com/coleYab/mpesasdk/errors"
+	"github.com/coleYab/mpesasdk/metrics"
+	"github.com/coleYab/mpesasdk/tracing"
 	"github.com/coleYab/mpesasdk/utils"
 )
 
+// Default timings of the token cache.
+const (
+	// expirySafetyMargin is how long before its expiry a token stops being handed out.
+	expirySafetyMargin = 2 * time.Second
+
+	// DefaultRefreshBefore is how long before its expiry a token is refreshed in the background.
+	DefaultRefreshBefore = time.Minute
+
+	// tokenRequestTimeout bounds a token request that is no longer tied to a single caller.
+	tokenRequestTimeout = 30 * time.Second
+
+	// refreshRetryDelay is how long a failed background refresh waits before being attempted again.
+	refreshRetryDelay = 5 * time.Second
+)
+
 // AuthorizationToken manages the authentication token used for M-Pesa API requests.
-// It stores the token, its creation time, expiry duration, and the associated consumer key and secret.
+// It stores the token, its expiry, and the associated consumer key and secret.
+//
+// AuthorizationToken is safe for concurrent use. Concurrent callers needing a new token share
+// a single upstream request, and a token close to its expiry is refreshed in the background
+// while the current one keeps being served.
 type AuthorizationToken struct {
-	token         string    // Bearer token for authorization
-	createdAt     time.Time // Timestamp of token creation
-	expiresIn     int       // Token expiry duration in seconds
-	consumerKey   string    // API consumer key
-	consumerSecret string   // API consumer secret
+	mu             sync.Mutex
+	token          string           // Bearer token for authorization
+	expiresAt      time.Time        // Time the token expires
+	lifetime       time.Duration    // Lifetime of the token as reported by the API
+	refreshBefore  time.Duration    // How long before expiry the token is refreshed in the background
+	inflight       *tokenRequest    // Token request in progress, shared by concurrent callers
+	failedAt       time.Time        // Time the last token request failed
+	consumerKey    string           // API consumer key
+	consumerSecret string           // API consumer secret
+	endpoints      *utils.Endpoints // Resolves the URL of the token endpoint
+	httpClient     *http.Client     // Client used to request tokens
+	store          TokenStore       // Shares the token with other clients, nil when not shared
+	tracer         trace.Tracer     // Creates the span of every token acquisition
+	metrics        metrics.Metrics  // Receives the token refreshes
+}
+
+// tokenRequest is a token request shared by every caller waiting for a new token.
+type tokenRequest struct {
+	done  chan struct{}
+	token string
+	err   error
 }
 
 // Constants representing authentication types.
@@ -43,39 +83,92 @@ const (
 //   - A pointer to an initialized AuthorizationToken instance.
 func NewAuthorizationToken(key, secret string) *AuthorizationToken {
 	return &AuthorizationToken{
-		consumerKey:   key,
+		consumerKey:    key,
 		consumerSecret: secret,
+		refreshBefore:  DefaultRefreshBefore,
+		endpoints:      utils.NewEndpoints(),
+		httpClient:     &http.Client{},
+		tracer:         tracing.Tracer(nil),
+		metrics:        metrics.Nop{},
 	}
 }
 
 // setAuthToken sets the authorization token along with its metadata.
+// The caller must hold a.mu.
 //
 // Parameters:
-//   - tokenType: The type of the token (e.g., "Bearer").
-//   - token: The authorization token.
-//   - expiresIn: The token's expiry duration in seconds.
+//   - token: The authorization token with its issue and expiry times.
 //   - key: The API consumer key.
 //   - secret: The API consumer secret.
-func (a *AuthorizationToken) setAuthToken(tokenType, token string, expiresIn int, key, secret string) {
-	a.token = fmt.Sprintf("%v %v", tokenType, token)
-	a.createdAt = time.Now()
-	a.expiresIn = expiresIn
+func (a *AuthorizationToken) setAuthToken(token Token, key, secret string) {
+	a.token = token.Value
+	a.lifetime = token.ExpiresAt.Sub(token.IssuedAt)
+	a.expiresAt = token.ExpiresAt
 	a.consumerKey = key
 	a.consumerSecret = secret
 }
 
+// SetEndpoints sets the endpoint configuration the token URL is resolved from.
+func (a *AuthorizationToken) SetEndpoints(endpoints *utils.Endpoints) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.endpoints = endpoints
+}
+
+// SetHTTPClient sets the http.Client used to request tokens.
+func (a *AuthorizationToken) SetHTTPClient(client *http.Client) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.httpClient = client
+}
+
+// SetRefreshBefore sets how long before its expiry a token is refreshed in the background.
+// A token is never refreshed before half of its lifetime has elapsed.
+func (a *AuthorizationToken) SetRefreshBefore(d time.Duration) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.refreshBefore = d
+}
+
+// SetTokenStore shares the token through store with the other clients using it, so that
+// clients of several processes or replicas request one token for the same credentials.
+// When store implements TokenLocker, a single client at a time requests a new token.
+func (a *AuthorizationToken) SetTokenStore(store TokenStore) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.store = store
+}
+
+// SetTracerProvider sets the provider of the spans created for every token acquisition, the
+// global tracer provider by default.
+func (a *AuthorizationToken) SetTracerProvider(tp trace.TracerProvider) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.tracer = tracing.Tracer(tp)
+}
+
+// SetMetrics sets the Metrics the token refreshes are reported to, none by default.
+func (a *AuthorizationToken) SetMetrics(m metrics.Metrics) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.metrics = m
+}
+
 // GetConsumerKeyAndSecret retrieves the consumer key and secret associated with the token.
 //
 // Returns:
 //   - consumerKey: The API consumer key.
 //   - consumerSecret: The API consumer secret.
 func (a *AuthorizationToken) GetConsumerKeyAndSecret() (string, string) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
 	return a.consumerKey, a.consumerSecret
 }
 
 // GetAuthorizationToken retrieves the current valid authorization token, or generates a new one if expired.
 //
 // Parameters:
+//   - ctx: The context controlling how long the caller waits for the token.
 //   - env: The environment (e.g., PRODUCTION or SANDBOX) to determine the base URL.
 //   - key: The API consumer key.
 //   - secret: The API consumer secret.
@@ -86,66 +179,241 @@ func (a *AuthorizationToken) GetConsumerKeyAndSecret() (string, string) {
 //
 // Behavior:
 //   - If the token is valid and not expired (within 2 seconds before expiry), it is returned.
+//   - If the token is close to its expiry, a refresh is started in the background and the current token is returned.
 //   - If expired or not set, a new token is generated by making a request to the M-Pesa API token endpoint.
+//     Concurrent callers wait for the same request instead of issuing their own.
 //   - The token is then stored with its metadata for future use.
 //
 // Example:
-//   token, err := authToken.GetAuthorizationToken(common.SANDBOX, "consumerKey", "consumerSecret")
-//   if err != nil {
-//       log.Fatalf("Failed to get token: %v", err)
-//   }
-//   fmt.Println("Authorization Token:", token)
-func (a *AuthorizationToken) GetAuthorizationToken(env common.Enviroment, key, secret string) (string, error) {
-	url := utils.ConstructURL(env, "/v1/token/generate?grant_type=client_credentials")
-	method := "GET"
+//
+//	token, err := authToken.GetAuthorizationToken(ctx, common.SANDBOX, "consumerKey", "consumerSecret")
+//	if err != nil {
+//	    log.Fatalf("Failed to get token: %v", err)
+//	}
+//	fmt.Println("Authorization Token:", token)
+func (a *AuthorizationToken) GetAuthorizationToken(ctx context.Context, env common.Enviroment, key, secret string) (string, error) {
+	a.mu.Lock()
+	tracer := a.tracer
+	a.mu.Unlock()
+
+	ctx, span := tracer.Start(ctx, "mpesa.token", trace.WithAttributes(tracing.EnvironmentKey.String(string(env))))
+	token, cached, err := a.authorizationToken(ctx, env, key, secret)
+	span.SetAttributes(tracing.TokenCachedKey.Bool(cached))
+	tracing.End(span, err)
+	return token, err
+}
+
+// authorizationToken implements GetAuthorizationToken, also reporting whether the token was
+// served from the cache.
+func (a *AuthorizationToken) authorizationToken(ctx context.Context, env common.Enviroment, key, secret string) (string, bool, error) {
+	a.mu.Lock()
+	now := time.Now()
 
 	// If token is still valid (2 seconds before expiry), return it
-	if a.token != "" && time.Now().Before(a.createdAt.Add(time.Duration(a.expiresIn-2)*time.Second)) {
-		return a.token, nil
+	if a.token != "" && now.Before(a.expiresAt.Add(-expirySafetyMargin)) {
+		token := a.token
+		refreshDue := now.After(a.expiresAt.Add(-refreshWindow(a.lifetime, a.refreshBefore)))
+		if refreshDue && a.inflight == nil && now.Sub(a.failedAt) > refreshRetryDelay {
+			a.startTokenRequest(ctx, env, key, secret)
+		}
+		a.mu.Unlock()
+		return token, true, nil
+	}
+
+	// Otherwise, join the token request in progress or start a new one
+	request := a.inflight
+	if request == nil {
+		request = a.startTokenRequest(ctx, env, key, secret)
+	}
+	a.mu.Unlock()
+
+	select {
+	case <-request.done:
+		return request.token, false, request.err
+	case <-ctx.Done():
+		return "", false, ctx.Err()
+	}
+}
+
+// refreshWindow returns how long before its expiry a token with the given lifetime is refreshed.
+func refreshWindow(lifetime, refreshBefore time.Duration) time.Duration {
+	if half := lifetime / 2; half < refreshBefore {
+		return half
+	}
+	return refreshBefore
+}
+
+// startTokenRequest requests a new token in the background and registers it as the request
+// in progress. The request is detached from the cancellation of ctx because other callers
+// may be waiting for it. The caller must hold a.mu.
+func (a *AuthorizationToken) startTokenRequest(ctx context.Context, env common.Enviroment, key, secret string) *tokenRequest {
+	request := &tokenRequest{done: make(chan struct{})}
+	a.inflight = request
+
+	source := tokenSource{
+		url:           a.endpoints.URL(env, common.TokenOperation) + "?grant_type=client_credentials",
+		client:        a.httpClient,
+		store:         a.store,
+		storeKey:      TokenStoreKey(env, key),
+		refreshBefore: a.refreshBefore,
+	}
+	observer := a.metrics
+
+	go func() {
+		ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), tokenRequestTimeout)
+		defer cancel()
+
+		start := time.Now()
+		token, err := source.token(ctx, key, secret)
+		observer.TokenRefreshed(time.Since(start), metrics.Code(err))
+
+		a.mu.Lock()
+		if err == nil {
+			a.setAuthToken(token, key, secret)
+			request.token = a.token
+		} else {
+			a.failedAt = time.Now()
+		}
+		request.err = err
+		a.inflight = nil
+		a.mu.Unlock()
+
+		close(request.done)
+	}()
+
+	return request
+}
+
+// tokenSource obtains new tokens, from the token store when another client already requested
+// one, or from the M-Pesa API token endpoint.
+type tokenSource struct {
+	url           string
+	client        *http.Client
+	store         TokenStore
+	storeKey      string
+	refreshBefore time.Duration
+}
+
+// token returns a token that is not due for a refresh. Failures of the store are not fatal,
+// the token is then requested without being shared.
+func (s tokenSource) token(ctx context.Context, key, secret string) (Token, error) {
+	if s.store != nil {
+		if token, ok := s.stored(ctx); ok {
+			return token, nil
+		}
+
+		if locker, ok := s.store.(TokenLocker); ok {
+			unlock, err := locker.Lock(ctx, s.storeKey, tokenRequestTimeout)
+			if err != nil && ctx.Err() != nil {
+				return Token{}, ctx.Err()
+			}
+			if err == nil {
+				defer unlock()
+
+				// Another client may have stored a token while the lock was held
+				if token, ok := s.stored(ctx); ok {
+					return token, nil
+				}
+			}
+		}
 	}
 
-	// Otherwise, request a new token
-	req, err := http.NewRequest(method, url, nil)
+	tokenType, value, expiresIn, err := requestToken(ctx, s.client, s.url, key, secret)
 	if err != nil {
-		return "", errors.New("error: while creating auth request")
+		return Token{}, err
+	}
+
+	now := time.Now()
+	token := Token{
+		Value:     fmt.Sprintf("%v %v", tokenType, value),
+		IssuedAt:  now,
+		ExpiresAt: now.Add(time.Duration(expiresIn) * time.Second),
+	}
+
+	if s.store != nil {
+		s.store.Set(ctx, s.storeKey, token)
+	}
+	return token, nil
+}
+
+// stored returns the token of the store if it is not due for a refresh.
+func (s tokenSource) stored(ctx context.Context) (Token, bool) {
+	token, ok, err := s.store.Get(ctx, s.storeKey)
+	if err != nil || !ok || token.Value == "" {
+		return Token{}, false
+	}
+
+	refreshAt := token.ExpiresAt.Add(-refreshWindow(token.ExpiresAt.Sub(token.IssuedAt), s.refreshBefore))
+	if !time.Now().Before(refreshAt) {
+		return Token{}, false
+	}
+	return token, true
+}
+
+// requestToken requests a new token from the M-Pesa API token endpoint.
+//
+// Returns:
+//   - The type of the token, the token and its lifetime in seconds.
+//   - An error if the token cannot be generated.
+func requestToken(ctx context.Context, client *http.Client, url, key, secret string) (string, string, int, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return "", "", 0, sdkError.ProcessingError("error: while creating auth request")
 	}
 
 	req.Header.Add("Content-Type", "application/json")
 	req.SetBasicAuth(key, secret)
 
-	client := &http.Client{}
 	res, err := client.Do(req)
 	if err != nil {
-		return "", err
+		return "", "", 0, sdkError.NewTransportError(err)
 	}
 
+	defer res.Body.Close()
+
 	body, err := io.ReadAll(res.Body)
 	if err != nil {
-		return "", err
+		return "", "", 0, sdkError.NewTransportError(err)
 	}
-	defer res.Body.Close()
 
 	var authResponse struct {
-		AccessToken string `json:"access_token"`
-		TokenType   string `json:"token_type"`
-		ExpiresIn   string `json:"expires_in"`
-		ResultCode  string `json:"resultCode"`
-		ResultDesc  string `json:"resultDesc"`
+		AccessToken  string `json:"access_token"`
+		TokenType    string `json:"token_type"`
+		ExpiresIn    string `json:"expires_in"`
+		ResultCode   string `json:"resultCode"`
+		ResultDesc   string `json:"resultDesc"`
+		RequestId    string `json:"requestId"`
+		ErrorCode    string `json:"errorCode"`
+		ErrorMessage string `json:"errorMessage"`
 	}
 
 	err = json.Unmarshal(body, &authResponse)
 	if err != nil {
-		return "", err
+		return "", "", 0, sdkError.NewUnexpectedResponseError(res.StatusCode, body, err)
 	}
 
-	// Handle errors from the response
-	if authResponse.ResultCode != "" {
-		return "", errors.New(authResponse.ResultDesc)
+	// Handle errors from the response, a token request can only fail for a server error or
+	// because the credentials were rejected
+	code, message := authResponse.ResultCode, authResponse.ResultDesc
+	if code == "" {
+		code, message = authResponse.ErrorCode, authResponse.ErrorMessage
+	}
+	if code != "" || authResponse.AccessToken == "" {
+		if code == "" {
+			code, message = "AUTH_ERROR", "no access token in the response"
+		}
+		kind := sdkError.ErrAuth
+		if res.StatusCode >= http.StatusInternalServerError || res.StatusCode == http.StatusTooManyRequests {
+			kind = nil
+		}
+		return "", "", 0, sdkError.NewDetailedError(code, message, sdkError.Details{
+			Kind:       kind,
+			HTTPStatus: res.StatusCode,
+			RequestID:  authResponse.RequestId,
+			RawBody:    body,
+		})
 	}
 
 	expiresIn, _ := strconv.Atoi(authResponse.ExpiresIn)
-	a.setAuthToken(authResponse.TokenType, authResponse.AccessToken, expiresIn, key, secret)
-
-	return a.token, nil
+	return authResponse.TokenType, authResponse.AccessToken, expiresIn, nil
 }
-
@@ -0,0 +1,146 @@
+package auth_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/coleYab/mpesasdk/auth"
+	"github.com/coleYab/mpesasdk/common"
+	"github.com/coleYab/mpesasdk/utils"
+)
+
+// tokenServer is a token endpoint counting the tokens it issues.
+type tokenServer struct {
+	*httptest.Server
+	issued    atomic.Int32
+	delay     time.Duration
+	expiresIn string
+}
+
+func newTokenServer(t *testing.T, delay time.Duration, expiresIn string) *tokenServer {
+	s := &tokenServer{delay: delay, expiresIn: expiresIn}
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(s.delay)
+		n := s.issued.Add(1)
+		json.NewEncoder(w).Encode(map[string]string{
+			"access_token": fmt.Sprintf("token-%d", n),
+			"token_type":   "Bearer",
+			"expires_in":   s.expiresIn,
+		})
+	}))
+	t.Cleanup(s.Close)
+	return s
+}
+
+func newAuthorizationToken(server *tokenServer) *auth.AuthorizationToken {
+	endpoints := utils.NewEndpoints()
+	endpoints.SetBaseURL(server.URL)
+
+	token := auth.NewAuthorizationToken("key", "secret")
+	token.SetEndpoints(endpoints)
+	return token
+}
+
+func TestConcurrentCallersShareOneTokenRequest(t *testing.T) {
+	server := newTokenServer(t, 50*time.Millisecond, "3599")
+	token := newAuthorizationToken(server)
+
+	var wg sync.WaitGroup
+	tokens := make([]string, 50)
+	errs := make([]error, 50)
+	for i := range tokens {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			tokens[i], errs[i] = token.GetAuthorizationToken(context.Background(), common.SANDBOX, "key", "secret")
+		}(i)
+	}
+	wg.Wait()
+
+	for i := range tokens {
+		if errs[i] != nil || tokens[i] != "Bearer token-1" {
+			t.Fatalf("caller %d got %q (%v), expecting the shared token", i, tokens[i], errs[i])
+		}
+	}
+
+	if issued := server.issued.Load(); issued != 1 {
+		t.Fatalf("expecting a single upstream token request but got %d", issued)
+	}
+}
+
+func TestCancelledCallerDoesNotFailOthers(t *testing.T) {
+	server := newTokenServer(t, 100*time.Millisecond, "3599")
+	token := newAuthorizationToken(server)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancelled := make(chan error, 1)
+	go func() {
+		_, err := token.GetAuthorizationToken(ctx, common.SANDBOX, "key", "secret")
+		cancelled <- err
+	}()
+
+	time.Sleep(20 * time.Millisecond)
+	cancel()
+
+	if err := <-cancelled; !errors.Is(err, context.Canceled) {
+		t.Fatalf("expecting the cancelled caller to get context.Canceled but got %v", err)
+	}
+
+	got, err := token.GetAuthorizationToken(context.Background(), common.SANDBOX, "key", "secret")
+	if err != nil || got != "Bearer token-1" {
+		t.Fatalf("expecting the shared request to complete but got %q (%v)", got, err)
+	}
+
+	if issued := server.issued.Load(); issued != 1 {
+		t.Fatalf("expecting a single upstream token request but got %d", issued)
+	}
+}
+
+func TestTokenIsRefreshedInTheBackground(t *testing.T) {
+	server := newTokenServer(t, 0, "6")
+	token := newAuthorizationToken(server)
+	token.SetRefreshBefore(time.Hour)
+
+	first, err := token.GetAuthorizationToken(context.Background(), common.SANDBOX, "key", "secret")
+	if err != nil || first != "Bearer token-1" {
+		t.Fatalf("unexpected first token %q (%v)", first, err)
+	}
+
+	// Half of the lifetime elapsed: the current token is served while a new one is requested
+	time.Sleep(3100 * time.Millisecond)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			got, err := token.GetAuthorizationToken(context.Background(), common.SANDBOX, "key", "secret")
+			if err != nil || got != "Bearer token-1" {
+				t.Errorf("expecting the current token while refreshing but got %q (%v)", got, err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	deadline := time.Now().Add(time.Second)
+	for server.issued.Load() < 2 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	if issued := server.issued.Load(); issued != 2 {
+		t.Fatalf("expecting exactly one background refresh but got %d token requests", issued)
+	}
+
+	got, err := token.GetAuthorizationToken(context.Background(), common.SANDBOX, "key", "secret")
+	if err != nil || got != "Bearer token-2" {
+		t.Fatalf("expecting the refreshed token but got %q (%v)", got, err)
+	}
+}
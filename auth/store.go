@@ -0,0 +1,201 @@
+package auth
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/coleYab/mpesasdk/common"
+)
+
+// Token is an authorization token as kept in a TokenStore.
+//
+// Fields:
+//   - Value: The value of the Authorization header (e.g. "Bearer <token>").
+//   - IssuedAt: The time the token was issued.
+//   - ExpiresAt: The time the token expires.
+type Token struct {
+	Value     string    `json:"value"`
+	IssuedAt  time.Time `json:"issued_at"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// TokenStore keeps authorization tokens outside of a single AuthorizationToken, so that several
+// clients, processes or replicas using the same credentials share one token instead of each
+// requesting their own.
+//
+// A store backed by Redis or a similar service implements Get and Set with plain GET/SET commands
+// (using ExpiresAt as the key expiry) and TokenLocker with `SET key NX PX ttl`.
+type TokenStore interface {
+	// Get returns the token stored under key, false when there is none.
+	Get(ctx context.Context, key string) (Token, bool, error)
+
+	// Set stores the token under key.
+	Set(ctx context.Context, key string, token Token) error
+}
+
+// TokenLocker is optionally implemented by a TokenStore able to coordinate refreshes, so that
+// only one of the processes sharing the store requests a new token at a time.
+type TokenLocker interface {
+	// Lock blocks until the lock on key is acquired or ctx is done. The lock is released by
+	// calling the returned function, or automatically after ttl if its holder disappeared.
+	Lock(ctx context.Context, key string, ttl time.Duration) (unlock func(), err error)
+}
+
+// TokenStoreKey returns the key the token of the credentials is stored under. The consumer key
+// is hashed so that it does not leak into the store.
+func TokenStoreKey(env common.Enviroment, consumerKey string) string {
+	sum := sha256.Sum256([]byte(consumerKey))
+	return "mpesasdk-token-" + strings.ToLower(string(env)) + "-" + hex.EncodeToString(sum[:8])
+}
+
+// MemoryTokenStore is a TokenStore sharing tokens between the clients of a single process.
+type MemoryTokenStore struct {
+	mu     sync.Mutex
+	tokens map[string]Token
+	locks  map[string]chan struct{}
+}
+
+// NewMemoryTokenStore creates an empty MemoryTokenStore.
+func NewMemoryTokenStore() *MemoryTokenStore {
+	return &MemoryTokenStore{
+		tokens: map[string]Token{},
+		locks:  map[string]chan struct{}{},
+	}
+}
+
+// Get implements TokenStore.
+func (s *MemoryTokenStore) Get(ctx context.Context, key string) (Token, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	token, ok := s.tokens[key]
+	return token, ok, nil
+}
+
+// Set implements TokenStore.
+func (s *MemoryTokenStore) Set(ctx context.Context, key string, token Token) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.tokens[key] = token
+	return nil
+}
+
+// Lock implements TokenLocker. Locks of a process are never abandoned, so ttl is not used.
+func (s *MemoryTokenStore) Lock(ctx context.Context, key string, ttl time.Duration) (func(), error) {
+	s.mu.Lock()
+	lock, ok := s.locks[key]
+	if !ok {
+		lock = make(chan struct{}, 1)
+		s.locks[key] = lock
+	}
+	s.mu.Unlock()
+
+	select {
+	case lock <- struct{}{}:
+		var once sync.Once
+		return func() { once.Do(func() { <-lock }) }, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// FileTokenStore is a TokenStore keeping tokens as JSON files in a directory, so that processes
+// sharing a file system (e.g. a volume mounted by every replica) share one token.
+type FileTokenStore struct {
+	dir          string
+	pollInterval time.Duration
+}
+
+// NewFileTokenStore creates a FileTokenStore keeping its files in dir, creating it if needed.
+func NewFileTokenStore(dir string) (*FileTokenStore, error) {
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, err
+	}
+	return &FileTokenStore{dir: dir, pollInterval: 50 * time.Millisecond}, nil
+}
+
+// Get implements TokenStore.
+func (s *FileTokenStore) Get(ctx context.Context, key string) (Token, bool, error) {
+	data, err := os.ReadFile(s.path(key, ".json"))
+	if errors.Is(err, os.ErrNotExist) {
+		return Token{}, false, nil
+	}
+	if err != nil {
+		return Token{}, false, err
+	}
+
+	token := Token{}
+	if err := json.Unmarshal(data, &token); err != nil {
+		return Token{}, false, err
+	}
+	return token, true, nil
+}
+
+// Set implements TokenStore. The file is replaced atomically so readers never see a partial token.
+func (s *FileTokenStore) Set(ctx context.Context, key string, token Token) error {
+	data, err := json.Marshal(token)
+	if err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(s.dir, ".token-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), s.path(key, ".json"))
+}
+
+// Lock implements TokenLocker with an exclusively created lock file. A lock file older than
+// ttl is considered abandoned and taken over.
+func (s *FileTokenStore) Lock(ctx context.Context, key string, ttl time.Duration) (func(), error) {
+	path := s.path(key, ".lock")
+	for {
+		file, err := os.OpenFile(path, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0o600)
+		if err == nil {
+			file.Close()
+			var once sync.Once
+			return func() { once.Do(func() { os.Remove(path) }) }, nil
+		}
+		if !errors.Is(err, os.ErrExist) {
+			return nil, err
+		}
+
+		if info, err := os.Stat(path); err == nil && time.Since(info.ModTime()) > ttl {
+			os.Remove(path)
+			continue
+		}
+
+		select {
+		case <-time.After(s.pollInterval):
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+}
+
+// path returns the path of the file of key with the given extension.
+func (s *FileTokenStore) path(key, ext string) string {
+	safe := strings.Map(func(r rune) rune {
+		if r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '-' || r == '_' {
+			return r
+		}
+		return '_'
+	}, key)
+	return filepath.Join(s.dir, safe+ext)
+}
@@ -0,0 +1,84 @@
+package auth_test
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/coleYab/mpesasdk/auth"
+	"github.com/coleYab/mpesasdk/common"
+)
+
+func TestReplicasShareOneTokenThroughTheStore(t *testing.T) {
+	fileStore, err := auth.NewFileTokenStore(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	stores := map[string]auth.TokenStore{
+		"memory": auth.NewMemoryTokenStore(),
+		"file":   fileStore,
+	}
+
+	for name, store := range stores {
+		t.Run(name, func(t *testing.T) {
+			server := newTokenServer(t, 50*time.Millisecond, "3599")
+
+			// Each AuthorizationToken stands for a replica with its own local cache
+			replicas := make([]*auth.AuthorizationToken, 5)
+			for i := range replicas {
+				replicas[i] = newAuthorizationToken(server)
+				replicas[i].SetTokenStore(store)
+			}
+
+			var wg sync.WaitGroup
+			for i, replica := range replicas {
+				for j := 0; j < 10; j++ {
+					wg.Add(1)
+					go func() {
+						defer wg.Done()
+						got, err := replica.GetAuthorizationToken(context.Background(), common.SANDBOX, "key", "secret")
+						if err != nil || got != "Bearer token-1" {
+							t.Errorf("replica %d got %q (%v), expecting the shared token", i, got, err)
+						}
+					}()
+				}
+			}
+			wg.Wait()
+
+			if issued := server.issued.Load(); issued != 1 {
+				t.Fatalf("expecting a single upstream token request but got %d", issued)
+			}
+
+			token, ok, err := store.Get(context.Background(), auth.TokenStoreKey(common.SANDBOX, "key"))
+			if err != nil || !ok || token.Value != "Bearer token-1" {
+				t.Fatalf("expecting the token to be stored but got %+v, %v (%v)", token, ok, err)
+			}
+		})
+	}
+}
+
+func TestExpiredStoredTokenIsReplaced(t *testing.T) {
+	server := newTokenServer(t, 0, "3599")
+	store := auth.NewMemoryTokenStore()
+	key := auth.TokenStoreKey(common.SANDBOX, "key")
+	store.Set(context.Background(), key, auth.Token{
+		Value:     "Bearer stale",
+		IssuedAt:  time.Now().Add(-time.Hour),
+		ExpiresAt: time.Now().Add(-time.Second),
+	})
+
+	token := newAuthorizationToken(server)
+	token.SetTokenStore(store)
+
+	got, err := token.GetAuthorizationToken(context.Background(), common.SANDBOX, "key", "secret")
+	if err != nil || got != "Bearer token-1" {
+		t.Fatalf("expecting a new token but got %q (%v)", got, err)
+	}
+
+	stored, _, _ := store.Get(context.Background(), key)
+	if stored.Value != "Bearer token-1" {
+		t.Fatalf("expecting the stored token to be replaced but got %q", stored.Value)
+	}
+}
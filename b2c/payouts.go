@@ -3,15 +3,16 @@
 package b2c
 
 import (
+	"cmp"
 	"encoding/json"
-	"fmt"
 	"io"
 	"net/http"
-	"slices"
+	"strconv"
+
 
 	"github.com/coleYab/mpesasdk/common"
 	sdkError "github.com/coleYab/mpesasdk/errors"
-	"github.com/coleYab/mpesasdk/utils"
+	"github.com/coleYab/mpesasdk/validation"
 )
 
 // B2CRequest defines the parameters required to initiate a B2C payment request.
@@ -41,6 +42,13 @@ type B2CRequest struct {
 	ResultURL                string           `json:"ResultURL"`
 	Occasion                 string           `json:"Occasion"`
 	OriginatorConversationID string           `json:"OriginatorConversationID"`
+
+	securityCredential string
+}
+
+// SetSecurityCredential sets the SecurityCredential used when the request leaves it empty.
+func (b *B2CRequest) SetSecurityCredential(credential string) {
+	b.securityCredential = credential
 }
 
 // B2CSuccessResponse represents a successful response from the B2C payment API.
@@ -48,53 +56,53 @@ type B2CSuccessResponse common.MpesaSuccessResponse
 
 // DecodeResponse processes the HTTP response for a B2C payment request and decodes it into the appropriate response type.
 func (b *B2CRequest) DecodeResponse(res *http.Response) (interface{}, error) {
-	bodyData, _ := io.ReadAll(res.Body)
+	bodyData, err := io.ReadAll(res.Body)
+	if err != nil {
+		return B2CSuccessResponse{}, sdkError.NewResponseReadError(res.StatusCode, bodyData, err)
+	}
+
 	responseData := B2CSuccessResponse{}
-	err := json.Unmarshal(bodyData, &responseData)
+	err = json.Unmarshal(bodyData, &responseData)
 	if err != nil {
-		return B2CSuccessResponse{}, sdkError.ProcessingError(err.Error())
+		return B2CSuccessResponse{}, sdkError.NewUnexpectedResponseError(res.StatusCode, bodyData, err)
 	}
 
 	if responseData.ResponseCode != "0" {
-		errorResponseData := common.MpesaErrorResponse{}
-		err := json.Unmarshal(bodyData, &errorResponseData)
+		errorResponseData, err := common.ParseErrorResponse(bodyData)
 		if err != nil {
-			return B2CSuccessResponse{}, sdkError.ProcessingError(err.Error())
+			return B2CSuccessResponse{}, sdkError.NewUnexpectedResponseError(res.StatusCode, bodyData, err)
 		}
-		return B2CSuccessResponse{}, b.decodeError(errorResponseData)
+		return B2CSuccessResponse{}, b.decodeError(res, bodyData, errorResponseData)
 	}
 
 	return responseData, nil
 }
 
 // FillDefaults sets default values for the B2CRequest instance.
-// This function is currently a placeholder and can be used to initialize default values in future implementations.
-func (b *B2CRequest) FillDefaults() {}
+func (b *B2CRequest) FillDefaults() {
+	if b.SecurityCredential == "" {
+		b.SecurityCredential = b.securityCredential
+	}
+}
 
 // Validate checks the validity of the B2CRequest parameters.
 func (b *B2CRequest) Validate() error {
-	validCommands := []common.CommandId{
-		common.BusinessPaymentCommand,
-		common.SalaryPaymentCommand,
-		common.PromotionPaymentCommand,
-	}
-	if !slices.Contains(validCommands, b.CommandID) {
-		return sdkError.ValidationError("unknown CommandID " + string(b.CommandID))
-	}
-
-	if err := utils.ValidateURL(b.QueueTimeOutURL); err != nil {
-		return err
-	}
-
-	if err := utils.ValidateURL(b.ResultURL); err != nil {
-		return err
-	}
-
-	return nil
+	v := validation.New()
+	v.Required("InitiatorName", b.InitiatorName)
+	v.Required("SecurityCredential", cmp.Or(b.SecurityCredential, b.securityCredential))
+	v.OneOf("CommandID", b.CommandID, common.BusinessPaymentCommand, common.SalaryPaymentCommand, common.PromotionPaymentCommand)
+	v.Min("Amount", uint64(b.Amount), 1)
+	v.Shortcode("PartyA", strconv.FormatUint(uint64(b.PartyA), 10))
+	v.MSISDN("PartyB", strconv.FormatUint(uint64(b.PartyB), 10))
+	v.Length("Remarks", b.Remarks, 2, validation.MaxRemarksLength)
+	v.URL("QueueTimeOutURL", b.QueueTimeOutURL)
+	v.URL("ResultURL", b.ResultURL)
+	v.MaxLength("Occasion", b.Occasion, validation.MaxOccasionLength)
+	return v.Err()
 }
 
-// decodeError converts a MpesaErrorResponse into a structured error message.
-func (b *B2CRequest) decodeError(e common.MpesaErrorResponse) error {
-	return sdkError.NewSDKError(e.ErrorCode, fmt.Sprintf("Request %v failed due to %v", e.RequestId, e.ErrorMessage))
+// decodeError converts a MpesaErrorResponse into a structured error.
+func (b *B2CRequest) decodeError(res *http.Response, body []byte, e common.MpesaErrorResponse) error {
+	return sdkError.NewAPIError(res.StatusCode, e.RequestId, e.ErrorCode, e.ErrorMessage, body)
 }
 
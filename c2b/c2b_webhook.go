@@ -0,0 +1,290 @@
+package c2b
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"time"
+
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/coleYab/mpesasdk/common"
+	sdkError "github.com/coleYab/mpesasdk/errors"
+	"github.com/coleYab/mpesasdk/tracing"
+	"github.com/coleYab/mpesasdk/utils"
+)
+
+// Result codes accepted by M-Pesa in the response to a C2B validation request.
+const (
+	C2BAccepted                = "0"
+	C2BRejectInvalidMSISDN     = "C2B00011"
+	C2BRejectInvalidAccount    = "C2B00012"
+	C2BRejectInvalidAmount     = "C2B00013"
+	C2BRejectInvalidKYCDetails = "C2B00014"
+	C2BRejectInvalidShortCode  = "C2B00015"
+	C2BRejectOther             = "C2B00016"
+)
+
+// DefaultC2BResponseTimeout is the time the webhook server gives user code before it answers
+// M-Pesa on its own. It is kept below the time M-Pesa waits for the validation response.
+const DefaultC2BResponseTimeout = 5 * time.Second
+
+// C2BPayment is the payload M-Pesa posts to the registered `ValidationURL` and `ConfirmationURL`
+// when a customer pays to a shortcode.
+type C2BPayment struct {
+	TransactionType   string      `json:"TransactionType"`
+	TransID           string      `json:"TransID"`
+	TransTime         string      `json:"TransTime"`
+	TransAmount       json.Number `json:"TransAmount"`
+	BusinessShortCode string      `json:"BusinessShortCode"`
+	BillRefNumber     string      `json:"BillRefNumber"`
+	InvoiceNumber     string      `json:"InvoiceNumber"`
+	OrgAccountBalance string      `json:"OrgAccountBalance"`
+	ThirdPartyTransID string      `json:"ThirdPartyTransID"`
+	MSISDN            string      `json:"MSISDN"`
+	FirstName         string      `json:"FirstName"`
+	MiddleName        string      `json:"MiddleName"`
+	LastName          string      `json:"LastName"`
+}
+
+// Amount returns the transaction amount as a number.
+func (p *C2BPayment) Amount() (float64, error) {
+	return p.TransAmount.Float64()
+}
+
+// Time returns the transaction time, sent by M-Pesa as YYYYMMDDHHMMSS in East Africa Time.
+func (p *C2BPayment) Time() (time.Time, error) {
+	return time.ParseInLocation("20060102150405", p.TransTime, time.FixedZone("EAT", 3*60*60))
+}
+
+// ParseC2BPayment decodes a C2B validation or confirmation payload.
+func ParseC2BPayment(r io.Reader) (*C2BPayment, error) {
+	payment := C2BPayment{}
+	if err := json.NewDecoder(r).Decode(&payment); err != nil {
+		return nil, sdkError.ValidationError("invalid c2b payload: " + err.Error())
+	}
+
+	if payment.TransID == "" {
+		return nil, sdkError.ValidationError("invalid c2b payload: missing TransID")
+	}
+	return &payment, nil
+}
+
+// AcceptC2BPayment builds the validation response accepting a payment.
+func AcceptC2BPayment() common.CallbackResponse {
+	return common.CallbackResponse{ResultCode: C2BAccepted, ResultDesc: "Accepted"}
+}
+
+// RejectC2BPayment builds the validation response rejecting a payment with one of the C2BReject codes.
+func RejectC2BPayment(code, description string) common.CallbackResponse {
+	return common.CallbackResponse{ResultCode: code, ResultDesc: description}
+}
+
+// C2BValidator decides whether a payment should be accepted.
+// When it returns an error, panics or does not answer in time, the webhook server
+// falls back to the `ResponseType` the URLs were registered with.
+type C2BValidator func(ctx context.Context, payment *C2BPayment) (common.CallbackResponse, error)
+
+// C2BConfirmationFunc is invoked for every payment confirmed by M-Pesa. Returning an error makes
+// M-Pesa send the confirmation again, so it should be idempotent by TransID.
+type C2BConfirmationFunc func(ctx context.Context, payment *C2BPayment) error
+
+// WebhookServer serves the validation and confirmation URLs registered with a RegisterC2BURLRequest.
+//
+// It can be mounted as a single http.Handler, in which case requests are routed by the paths of
+// the registered URLs, or each side can be mounted separately through ValidationHandler and
+// ConfirmationHandler. The validator and confirmation functions run within a span of the global
+// tracer provider, or the one set with WithTracerProvider, see the tracing package.
+type WebhookServer struct {
+	validationPath   string
+	confirmationPath string
+	responseType     common.ResponseType
+	validator        C2BValidator
+	confirm          C2BConfirmationFunc
+	timeout          time.Duration
+	tracerProvider   trace.TracerProvider
+}
+
+// NewWebhookServer creates a WebhookServer for the given URL registration.
+//
+// Parameters:
+//   - registration: The request the URLs were registered with, its ResponseType is used as fallback.
+//   - validator: Decides on validation requests, nil accepts every payment.
+//   - confirm: Receives confirmed payments, nil ignores them.
+//   - opts: The options of the handlers, e.g. WithTracerProvider.
+//
+// Returns:
+//   - A pointer to the initialized WebhookServer.
+//   - An error if the registration is invalid.
+func NewWebhookServer(registration RegisterC2BURLRequest, validator C2BValidator, confirm C2BConfirmationFunc, opts ...HandlerOption) (*WebhookServer, error) {
+	if err := registration.Validate(); err != nil {
+		return nil, err
+	}
+
+	validationURL, err := url.Parse(registration.ValidationURL)
+	if err != nil {
+		return nil, sdkError.ValidationError("invalid validation url: " + err.Error())
+	}
+
+	confirmationURL, err := url.Parse(registration.ConfirmationURL)
+	if err != nil {
+		return nil, sdkError.ValidationError("invalid confirmation url: " + err.Error())
+	}
+
+	if validator == nil {
+		validator = func(context.Context, *C2BPayment) (common.CallbackResponse, error) {
+			return AcceptC2BPayment(), nil
+		}
+	}
+
+	if confirm == nil {
+		confirm = func(context.Context, *C2BPayment) error { return nil }
+	}
+
+	return &WebhookServer{
+		validationPath:   validationURL.Path,
+		confirmationPath: confirmationURL.Path,
+		responseType:     registration.ResponseType,
+		validator:        validator,
+		confirm:          confirm,
+		timeout:          DefaultC2BResponseTimeout,
+		tracerProvider:   newHandlerConfig(opts).tracerProvider,
+	}, nil
+}
+
+// SetTimeout changes how long user code may take before the server answers M-Pesa on its own.
+func (s *WebhookServer) SetTimeout(timeout time.Duration) {
+	if timeout > 0 {
+		s.timeout = timeout
+	}
+}
+
+// ServeHTTP routes the request to the validation or confirmation handler based on its path.
+func (s *WebhookServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	switch r.URL.Path {
+	case s.validationPath:
+		s.ValidationHandler().ServeHTTP(w, r)
+	case s.confirmationPath:
+		s.ConfirmationHandler().ServeHTTP(w, r)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+// ValidationHandler returns the handler for the registered `ValidationURL`.
+func (s *WebhookServer) ValidationHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		payment, ok := decodeC2BPayment(w, r)
+		if !ok {
+			return
+		}
+
+		ctx, span := tracing.StartCallback(s.tracerProvider, r, "mpesa.callback.c2b_validation")
+		ctx, cancel := context.WithTimeout(ctx, s.timeout)
+		defer cancel()
+
+		response, err := runWithTimeout(ctx, func() (common.CallbackResponse, error) {
+			return s.validator(ctx, payment)
+		}, nil)
+		span.SetAttributes(tracing.ResultCodeKey.String(response.ResultCode))
+		tracing.End(span, err)
+		if err != nil {
+			response = s.fallbackResponse()
+		}
+
+		utils.WriteJSON(w, http.StatusOK, response)
+	})
+}
+
+// ConfirmationHandler returns the handler for the registered `ConfirmationURL`.
+//
+// M-Pesa is acknowledged with 200 once the confirmation function succeeded, or with 500 when it
+// failed so that the confirmation is sent again. When the function does not return within the
+// timeout, M-Pesa is acknowledged with 200 so that the payment is not confirmed twice, and the
+// function keeps running with a context that is not cancelled by the response; a later error is
+// only recorded on its span.
+func (s *WebhookServer) ConfirmationHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		payment, ok := decodeC2BPayment(w, r)
+		if !ok {
+			return
+		}
+
+		ctx, span := tracing.StartCallback(s.tracerProvider, r, "mpesa.callback.c2b_confirmation")
+		wait, cancel := context.WithTimeout(r.Context(), s.timeout)
+		defer cancel()
+
+		ctx = context.WithoutCancel(ctx)
+		_, err := runWithTimeout(wait, func() (common.CallbackResponse, error) {
+			return common.CallbackResponse{}, s.confirm(ctx, payment)
+		}, func(_ common.CallbackResponse, err error) {
+			tracing.End(span, err)
+		})
+		if err != nil && wait.Err() == nil {
+			utils.WriteJSON(w, http.StatusInternalServerError, common.CallbackResponse{ResultCode: "1", ResultDesc: "Rejected"})
+			return
+		}
+
+		utils.WriteJSON(w, http.StatusOK, common.CallbackResponse{ResultCode: "0", ResultDesc: "Success"})
+	})
+}
+
+// fallbackResponse answers a validation request according to the registered ResponseType.
+func (s *WebhookServer) fallbackResponse() common.CallbackResponse {
+	if s.responseType == common.CancelledResponse {
+		return RejectC2BPayment(C2BRejectOther, "Rejected")
+	}
+	return AcceptC2BPayment()
+}
+
+// decodeC2BPayment reads the payment from the request, answering M-Pesa itself when it cannot.
+func decodeC2BPayment(w http.ResponseWriter, r *http.Request) (*C2BPayment, bool) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		utils.WriteJSON(w, http.StatusMethodNotAllowed, common.CallbackResponse{ResultCode: "1", ResultDesc: "Method not allowed"})
+		return nil, false
+	}
+
+	payment, err := ParseC2BPayment(r.Body)
+	if err != nil {
+		utils.WriteJSON(w, http.StatusBadRequest, common.CallbackResponse{ResultCode: "1", ResultDesc: "Rejected"})
+		return nil, false
+	}
+	return payment, true
+}
+
+// runWithTimeout runs fn in its own goroutine and waits for it until ctx is done.
+// A panic in fn is reported as an error. finish, when not nil, is called with the outcome of fn
+// once it returned, even after ctx is done.
+func runWithTimeout(ctx context.Context, fn func() (common.CallbackResponse, error), finish func(common.CallbackResponse, error)) (common.CallbackResponse, error) {
+	type result struct {
+		response common.CallbackResponse
+		err      error
+	}
+
+	done := make(chan result, 1)
+	go func() {
+		res := result{}
+		defer func() {
+			if r := recover(); r != nil {
+				res.err = fmt.Errorf("c2b handler panicked: %v", r)
+			}
+			if finish != nil {
+				finish(res.response, res.err)
+			}
+			done <- res
+		}()
+
+		res.response, res.err = fn()
+	}()
+
+	select {
+	case <-ctx.Done():
+		return common.CallbackResponse{}, ctx.Err()
+	case res := <-done:
+		return res.response, res.err
+	}
+}
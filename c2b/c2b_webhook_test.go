@@ -0,0 +1,124 @@
+package c2b_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/coleYab/mpesasdk/c2b"
+	"github.com/coleYab/mpesasdk/common"
+)
+
+const c2bPayment = `{
+  "TransactionType": "Pay Bill",
+  "TransID": "RKTQDM7W6S",
+  "TransTime": "20191122063845",
+  "TransAmount": "10",
+  "BusinessShortCode": "600638",
+  "BillRefNumber": "invoice008",
+  "MSISDN": "251708374149",
+  "FirstName": "John"
+}`
+
+func newWebhookServer(t *testing.T, responseType common.ResponseType, validator c2b.C2BValidator, opts ...c2b.HandlerOption) *c2b.WebhookServer {
+	server, err := c2b.NewWebhookServer(c2b.RegisterC2BURLRequest{
+		ShortCode:       "600638",
+		ResponseType:    responseType,
+		ConfirmationURL: "https://example.com/c2b/confirmation",
+		ValidationURL:   "https://example.com/c2b/validation",
+	}, validator, nil, opts...)
+	if err != nil {
+		t.Fatalf("expecting webhook server to be created but got: %v", err)
+	}
+	return server
+}
+
+func postPayment(t *testing.T, handler http.Handler, path string) common.CallbackResponse {
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, path, strings.NewReader(c2bPayment)))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expecting 200 but got %v", rec.Code)
+	}
+
+	ack := common.CallbackResponse{}
+	if err := json.NewDecoder(rec.Body).Decode(&ack); err != nil {
+		t.Fatalf("failed to decode acknowledgement: %v", err)
+	}
+	return ack
+}
+
+func TestWebhookServerValidation(t *testing.T) {
+	server := newWebhookServer(t, common.CompletedResponse, func(ctx context.Context, p *c2b.C2BPayment) (common.CallbackResponse, error) {
+		if amount, _ := p.Amount(); amount < 100 {
+			return c2b.RejectC2BPayment(c2b.C2BRejectInvalidAmount, "Amount too small"), nil
+		}
+		return c2b.AcceptC2BPayment(), nil
+	})
+
+	if ack := postPayment(t, server, "/c2b/validation"); ack.ResultCode != c2b.C2BRejectInvalidAmount {
+		t.Fatalf("expecting payment to be rejected but got %+v", ack)
+	}
+
+	if ack := postPayment(t, server, "/c2b/confirmation"); ack.ResultCode != "0" {
+		t.Fatalf("expecting confirmation to be acknowledged but got %+v", ack)
+	}
+}
+
+func TestWebhookServerFallsBackToResponseType(t *testing.T) {
+	slow := func(ctx context.Context, p *c2b.C2BPayment) (common.CallbackResponse, error) {
+		<-ctx.Done()
+		time.Sleep(10 * time.Millisecond)
+		return c2b.AcceptC2BPayment(), nil
+	}
+
+	cancelled := newWebhookServer(t, common.CancelledResponse, slow)
+	cancelled.SetTimeout(20 * time.Millisecond)
+	if ack := postPayment(t, cancelled, "/c2b/validation"); ack.ResultCode != c2b.C2BRejectOther {
+		t.Fatalf("expecting timeout to reject the payment but got %+v", ack)
+	}
+
+	completed := newWebhookServer(t, common.CompletedResponse, func(ctx context.Context, p *c2b.C2BPayment) (common.CallbackResponse, error) {
+		panic("validator bug")
+	})
+	if ack := postPayment(t, completed, "/c2b/validation"); ack.ResultCode != c2b.C2BAccepted {
+		t.Fatalf("expecting failing validator to accept the payment but got %+v", ack)
+	}
+}
+
+func TestSlowConfirmationIsAcknowledgedAndKeepsRunning(t *testing.T) {
+	release := make(chan struct{})
+	finished := make(chan error, 1)
+	server, err := c2b.NewWebhookServer(c2b.RegisterC2BURLRequest{
+		ShortCode:       "600638",
+		ResponseType:    common.CompletedResponse,
+		ConfirmationURL: "https://example.com/c2b/confirmation",
+		ValidationURL:   "https://example.com/c2b/validation",
+	}, nil, func(ctx context.Context, p *c2b.C2BPayment) error {
+		<-release
+		finished <- ctx.Err()
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("expecting webhook server to be created but got: %v", err)
+	}
+	server.SetTimeout(20 * time.Millisecond)
+
+	// M-Pesa must not send the confirmation again while it is still being processed
+	if ack := postPayment(t, server, "/c2b/confirmation"); ack.ResultCode != "0" {
+		t.Fatalf("expecting the slow confirmation to be acknowledged but got %+v", ack)
+	}
+
+	close(release)
+	select {
+	case err := <-finished:
+		if err != nil {
+			t.Fatalf("expecting the confirmation to keep running after the acknowledgement but got: %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("the confirmation did not finish")
+	}
+}
@@ -16,11 +16,10 @@ import (
     "fmt"
     "io"
     "net/http"
-    "slices"
 
     "github.com/coleYab/mpesasdk/common"
     sdkError "github.com/coleYab/mpesasdk/errors"
-    "github.com/coleYab/mpesasdk/utils"
+    "github.com/coleYab/mpesasdk/validation"
 )
 
 /*
@@ -70,11 +69,15 @@ Behavior:
   - If the response indicates failure, it parses the error details and returns an appropriate error.
 */
 func (s *RegisterC2BURLRequest) DecodeResponse(res *http.Response) (interface{}, error) {
-    bodyData, _ :=  io.ReadAll(res.Body)
+    bodyData, err := io.ReadAll(res.Body)
+    if err != nil {
+        return RegisterC2BURLSuccessResponse{}, sdkError.NewResponseReadError(res.StatusCode, bodyData, err)
+    }
+
     responseData := registerUrlResponse{}
-    err := json.Unmarshal(bodyData, &responseData)
+    err = json.Unmarshal(bodyData, &responseData)
     if err != nil {
-        return RegisterC2BURLSuccessResponse{}, sdkError.ProcessingError(err.Error())
+        return RegisterC2BURLSuccessResponse{}, sdkError.NewUnexpectedResponseError(res.StatusCode, bodyData, err)
     }
 
     switch responseData.Header.ResponseCode {
@@ -85,16 +88,16 @@ func (s *RegisterC2BURLRequest) DecodeResponse(res *http.Response) (interface{},
         }, nil
     case "":
         // In this case an error with the http.Response.StatusCode != 200 so we need the defult error handling mechanism
-        errorResponse := common.MpesaErrorResponse{}
-        err := json.Unmarshal(bodyData, &errorResponse)
+        errorResponse, err := common.ParseErrorResponse(bodyData)
         if err != nil {
-            return RegisterC2BURLSuccessResponse{}, sdkError.ProcessingError(err.Error())
+            return RegisterC2BURLSuccessResponse{}, sdkError.NewUnexpectedResponseError(res.StatusCode, bodyData, err)
         }
-        responseData.Header.ResponseCode = errorResponse.ErrorCode
-        responseData.Header.ResponseMessage = errorResponse.ErrorMessage
-        fallthrough // use the default error handling after that to improve consistency
+        return RegisterC2BURLSuccessResponse{}, s.decodeError(res, bodyData, errorResponse)
     default:
-        return RegisterC2BURLSuccessResponse{}, s.decodeError(responseData)
+        return RegisterC2BURLSuccessResponse{}, s.decodeError(res, bodyData, common.MpesaErrorResponse{
+            ErrorCode: string(responseData.Header.ResponseCode),
+            ErrorMessage: responseData.Header.ResponseMessage,
+        })
     }
 }
 
@@ -103,26 +106,20 @@ func (t *RegisterC2BURLRequest) FillDefaults() {
 }
 
 func (t *RegisterC2BURLRequest) Validate() error {
-    validResponseType := []common.ResponseType{common.CompletedResponse, common.CancelledResponse}
-    if !slices.Contains(validResponseType, t.ResponseType) {
-        return sdkError.ValidationError("invalid response type " + string(t.ResponseType))
-    }
-
-    if err := utils.ValidateURL(t.ConfirmationURL); err != nil {
-        return err
-    }
-
-    if err := utils.ValidateURL(t.ValidationURL); err != nil {
-        return err
-    }
-
-    return nil
+    v := validation.New()
+    v.Shortcode("ShortCode", t.ShortCode)
+    v.OneOf("ResponseType", t.ResponseType, common.CompletedResponse, common.CancelledResponse)
+    v.URL("ConfirmationURL", t.ConfirmationURL)
+    v.URL("ValidationURL", t.ValidationURL)
+    return v.Err()
 }
 // decodeError processes errors from the M-Pesa API.
-func (s *RegisterC2BURLRequest) decodeError(e registerUrlResponse) error {
-    errorCode := e.Header.ResponseCode
-    return sdkError.NewSDKError(
-        string(errorCode),
-        fmt.Sprintf("Url registration failed due to %v", e.Header.ResponseMessage),
+func (s *RegisterC2BURLRequest) decodeError(res *http.Response, body []byte, e common.MpesaErrorResponse) error {
+    return sdkError.NewAPIError(
+        res.StatusCode,
+        e.RequestId,
+        e.ErrorCode,
+        fmt.Sprintf("Url registration failed due to %v", e.ErrorMessage),
+        body,
         )
 }
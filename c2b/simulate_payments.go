@@ -2,13 +2,13 @@ package c2b
 
 import (
 	"encoding/json"
-	"fmt"
 	"io"
 	"net/http"
-	"slices"
 
 	"github.com/coleYab/mpesasdk/common"
 	sdkError "github.com/coleYab/mpesasdk/errors"
+	"github.com/coleYab/mpesasdk/msisdn"
+	"github.com/coleYab/mpesasdk/validation"
 )
 
 // SimulateCustomerInititatedPayment simulates a customer-initiated payment.
@@ -31,20 +31,23 @@ type SimulateCustomerInititatedPayment struct {
 type SimulatePaymentSuccessResponse  common.MpesaSuccessResponse
 
 func (s *SimulateCustomerInititatedPayment) DecodeResponse(res *http.Response) (interface{}, error) {
-    bodyData, _ := io.ReadAll(res.Body)
+    bodyData, err := io.ReadAll(res.Body)
+    if err != nil {
+        return SimulatePaymentSuccessResponse{}, sdkError.NewResponseReadError(res.StatusCode, bodyData, err)
+    }
+
     responseData := SimulatePaymentSuccessResponse{}
-    err := json.Unmarshal(bodyData, &responseData)
+    err = json.Unmarshal(bodyData, &responseData)
     if err != nil {
-        return SimulatePaymentSuccessResponse{}, err
+        return SimulatePaymentSuccessResponse{}, sdkError.NewUnexpectedResponseError(res.StatusCode, bodyData, err)
     }
 
     if responseData.ResponseCode != "0" {
-        errorResponseData := common.MpesaErrorResponse{}
-        err := json.Unmarshal(bodyData, &errorResponseData)
+        errorResponseData, err := common.ParseErrorResponse(bodyData)
         if err != nil {
-            return SimulatePaymentSuccessResponse{}, err
+            return SimulatePaymentSuccessResponse{}, sdkError.NewUnexpectedResponseError(res.StatusCode, bodyData, err)
         }
-        return SimulatePaymentSuccessResponse{}, s.decodeError(errorResponseData)
+        return SimulatePaymentSuccessResponse{}, s.decodeError(res, bodyData, errorResponseData)
     }
 
     return responseData, nil
@@ -53,16 +56,26 @@ func (s *SimulateCustomerInititatedPayment) DecodeResponse(res *http.Response) (
 func (s *SimulateCustomerInititatedPayment) FillDefaults() {
 }
 
-func (s *SimulateCustomerInititatedPayment) Validate() error {
-    validCommands := []common.CommandId{
-        common.CustomerPayBillOnlineCommand, common.CustomerBuyGoodsOnlineCommand,
+// NormalizePhoneNumbers rewrites Msisdn in the canonical form of profile, e.g. "0712 345 678"
+// as "251712345678". A number that cannot be parsed is left for Validate to report.
+func (s *SimulateCustomerInititatedPayment) NormalizePhoneNumbers(profile *msisdn.Profile) {
+    if number, err := msisdn.Normalize(s.Msisdn, profile); err == nil {
+        s.Msisdn = number
     }
-    if !slices.Contains(validCommands, s.CommandID) {
-        return sdkError.ValidationError("unknown commandID")
+}
+
+func (s *SimulateCustomerInititatedPayment) Validate() error {
+    v := validation.New()
+    v.OneOf("CommandID", s.CommandID, common.CustomerPayBillOnlineCommand, common.CustomerBuyGoodsOnlineCommand)
+    v.Min("Amount", s.Amount, 1)
+    v.MSISDN("Msisdn", s.Msisdn)
+    v.Shortcode("ShortCode", s.ShortCode)
+    if s.CommandID == common.CustomerPayBillOnlineCommand {
+        v.Required("BillRefNumber", s.BillRefNumber)
     }
-    return nil
+    return v.Err()
 }
 
-func (s *SimulateCustomerInititatedPayment) decodeError(e common.MpesaErrorResponse) error {
-    return sdkError.NewSDKError(e.ErrorCode, fmt.Sprintf("Request %v failed with %v", e.RequestId, e.ErrorMessage))
+func (s *SimulateCustomerInititatedPayment) decodeError(res *http.Response, body []byte, e common.MpesaErrorResponse) error {
+    return sdkError.NewAPIError(res.StatusCode, e.RequestId, e.ErrorCode, e.ErrorMessage, body)
 }
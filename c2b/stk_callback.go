@@ -0,0 +1,234 @@
+package c2b
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"time"
+
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/coleYab/mpesasdk/common"
+	sdkError "github.com/coleYab/mpesasdk/errors"
+	"github.com/coleYab/mpesasdk/resultcodes"
+	"github.com/coleYab/mpesasdk/tracing"
+	"github.com/coleYab/mpesasdk/utils"
+)
+
+// STKCallback is the result M-Pesa posts to the `CallBackURL` of an STKPushPaymentRequest
+// once the customer has completed, cancelled or ignored the PIN prompt.
+//
+// It models the `Body.stkCallback` object of the callback payload.
+type STKCallback struct {
+	// MerchantRequestID echoes the MerchantRequestID of the acknowledged STK push.
+	MerchantRequestID string `json:"MerchantRequestID"`
+
+	// CheckoutRequestID echoes the CheckoutRequestID of the acknowledged STK push.
+	CheckoutRequestID string `json:"CheckoutRequestID"`
+
+	// ResultCode is 0 for a successful payment and non-zero otherwise (e.g. 1032 when cancelled by the user).
+	ResultCode int `json:"ResultCode"`
+
+	// ResultDesc is a human-readable description of the result.
+	ResultDesc string `json:"ResultDesc"`
+
+	// CallbackMetadata carries the payment details, it is only present for successful payments.
+	CallbackMetadata *STKCallbackMetadata `json:"CallbackMetadata,omitempty"`
+}
+
+// STKCallbackMetadata holds the list of name/value items describing a completed payment.
+type STKCallbackMetadata struct {
+	Item []STKCallbackItem `json:"Item"`
+}
+
+// STKCallbackItem is a single name/value entry of the callback metadata.
+// Value is nil for items M-Pesa sends without a value (e.g. "Balance").
+type STKCallbackItem struct {
+	Name  string      `json:"Name"`
+	Value interface{} `json:"Value,omitempty"`
+}
+
+type stkCallbackEnvelope struct {
+	Body struct {
+		StkCallback *STKCallback `json:"stkCallback"`
+	} `json:"Body"`
+}
+
+// ParseSTKCallback decodes an STK push callback payload.
+//
+// Numeric metadata values are kept as json.Number so that phone numbers and
+// transaction dates do not lose precision.
+//
+// Returns:
+//   - The decoded callback.
+//   - A ValidationError if the payload is not a valid STK callback.
+func ParseSTKCallback(r io.Reader) (*STKCallback, error) {
+	decoder := json.NewDecoder(r)
+	decoder.UseNumber()
+
+	envelope := stkCallbackEnvelope{}
+	if err := decoder.Decode(&envelope); err != nil {
+		return nil, sdkError.ValidationError("invalid stk callback payload: " + err.Error())
+	}
+
+	if envelope.Body.StkCallback == nil {
+		return nil, sdkError.ValidationError("invalid stk callback payload: missing Body.stkCallback")
+	}
+
+	return envelope.Body.StkCallback, nil
+}
+
+// Successful reports whether the customer completed the payment.
+func (c *STKCallback) Successful() bool {
+	return c.ResultCode == 0
+}
+
+// Info describes the result code of the callback, e.g. whether the customer can be asked to retry.
+func (c *STKCallback) Info() resultcodes.Info {
+	return resultcodes.LookupInt(c.ResultCode)
+}
+
+// Item returns the raw value of the metadata item with the given name.
+func (c *STKCallback) Item(name string) (interface{}, bool) {
+	if c.CallbackMetadata == nil {
+		return nil, false
+	}
+
+	for _, item := range c.CallbackMetadata.Item {
+		if item.Name == name && item.Value != nil {
+			return item.Value, true
+		}
+	}
+	return nil, false
+}
+
+// Amount returns the amount paid by the customer.
+func (c *STKCallback) Amount() (float64, bool) {
+	value, ok := c.Item("Amount")
+	if !ok {
+		return 0, false
+	}
+
+	switch v := value.(type) {
+	case json.Number:
+		amount, err := v.Float64()
+		return amount, err == nil
+	case float64:
+		return v, true
+	}
+	return 0, false
+}
+
+// MpesaReceiptNumber returns the M-Pesa receipt number of the payment.
+func (c *STKCallback) MpesaReceiptNumber() (string, bool) {
+	return c.itemString("MpesaReceiptNumber")
+}
+
+// TransactionDate returns the time the payment was completed.
+// M-Pesa sends it as a YYYYMMDDHHMMSS number in East Africa Time.
+func (c *STKCallback) TransactionDate() (time.Time, bool) {
+	value, ok := c.itemString("TransactionDate")
+	if !ok {
+		return time.Time{}, false
+	}
+
+	date, err := time.ParseInLocation("20060102150405", value, time.FixedZone("EAT", 3*60*60))
+	if err != nil {
+		return time.Time{}, false
+	}
+	return date, true
+}
+
+// PhoneNumber returns the phone number of the customer that paid.
+func (c *STKCallback) PhoneNumber() (string, bool) {
+	return c.itemString("PhoneNumber")
+}
+
+// itemString returns the value of the named item formatted as a string.
+func (c *STKCallback) itemString(name string) (string, bool) {
+	value, ok := c.Item(name)
+	if !ok {
+		return "", false
+	}
+
+	switch v := value.(type) {
+	case string:
+		return v, true
+	case json.Number:
+		return v.String(), true
+	}
+	return "", false
+}
+
+// STKCallbackFunc is invoked by the STK callback handler for every decoded callback.
+// Returning an error makes the handler reject the notification.
+type STKCallbackFunc func(ctx context.Context, callback *STKCallback) error
+
+// HandlerOption configures a callback handler created with NewSTKCallbackHandler or NewWebhookServer.
+type HandlerOption func(*handlerConfig)
+
+// handlerConfig holds the settings collected from the options of a callback handler.
+type handlerConfig struct {
+	tracerProvider trace.TracerProvider
+}
+
+// WithTracerProvider sets the OpenTelemetry provider of the spans of the handler, the global
+// tracer provider by default.
+func WithTracerProvider(tp trace.TracerProvider) HandlerOption {
+	return func(c *handlerConfig) {
+		c.tracerProvider = tp
+	}
+}
+
+// newHandlerConfig applies the options of a handler.
+func newHandlerConfig(opts []HandlerOption) *handlerConfig {
+	cfg := &handlerConfig{}
+	for _, opt := range opts {
+		opt(cfg)
+	}
+	return cfg
+}
+
+// NewSTKCallbackHandler returns an http.Handler to be mounted at the `CallBackURL` of STK push requests.
+//
+// The handler decodes the callback, invokes fn and acknowledges M-Pesa:
+//   - 200 with ResultCode "0" when fn succeeds.
+//   - 400 with ResultCode "1" when the payload cannot be decoded.
+//   - 500 with ResultCode "1" when fn returns an error.
+//
+// fn runs within a "mpesa.callback.stk" span of the global tracer provider, or the one set with
+// WithTracerProvider, continuing the trace of the STK push when its CallBackURL carries the
+// trace context, see the tracing package.
+func NewSTKCallbackHandler(fn STKCallbackFunc, opts ...HandlerOption) http.Handler {
+	cfg := newHandlerConfig(opts)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			w.Header().Set("Allow", http.MethodPost)
+			utils.WriteJSON(w, http.StatusMethodNotAllowed, common.CallbackResponse{ResultCode: "1", ResultDesc: "Method not allowed"})
+			return
+		}
+
+		ctx, span := tracing.StartCallback(cfg.tracerProvider, r, "mpesa.callback.stk")
+		callback, err := ParseSTKCallback(r.Body)
+		if err != nil {
+			tracing.End(span, err)
+			utils.WriteJSON(w, http.StatusBadRequest, common.CallbackResponse{ResultCode: "1", ResultDesc: "Rejected"})
+			return
+		}
+		span.SetAttributes(
+			tracing.MerchantRequestIDKey.String(callback.MerchantRequestID),
+			tracing.CheckoutRequestIDKey.String(callback.CheckoutRequestID),
+			tracing.ResultCodeKey.Int(callback.ResultCode),
+		)
+
+		err = fn(ctx, callback)
+		tracing.End(span, err)
+		if err != nil {
+			utils.WriteJSON(w, http.StatusInternalServerError, common.CallbackResponse{ResultCode: "1", ResultDesc: "Rejected"})
+			return
+		}
+
+		utils.WriteJSON(w, http.StatusOK, common.CallbackResponse{ResultCode: "0", ResultDesc: "Accepted"})
+	})
+}
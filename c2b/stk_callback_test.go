@@ -0,0 +1,120 @@
+package c2b_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+
+	"github.com/coleYab/mpesasdk/c2b"
+	"github.com/coleYab/mpesasdk/common"
+)
+
+const successfulCallback = `{
+  "Body": {
+    "stkCallback": {
+      "MerchantRequestID": "29115-34620561-1",
+      "CheckoutRequestID": "ws_CO_191220191020363925",
+      "ResultCode": 0,
+      "ResultDesc": "The service request is processed successfully.",
+      "CallbackMetadata": {
+        "Item": [
+          {"Name": "Amount", "Value": 1.00},
+          {"Name": "MpesaReceiptNumber", "Value": "NLJ7RT61SV"},
+          {"Name": "Balance"},
+          {"Name": "TransactionDate", "Value": 20191219102115},
+          {"Name": "PhoneNumber", "Value": 251708374149}
+        ]
+      }
+    }
+  }
+}`
+
+func TestParseSTKCallback(t *testing.T) {
+	callback, err := c2b.ParseSTKCallback(strings.NewReader(successfulCallback))
+	if err != nil {
+		t.Fatalf("expecting callback to parse but got: %v", err)
+	}
+
+	if !callback.Successful() || callback.CheckoutRequestID != "ws_CO_191220191020363925" {
+		t.Fatalf("unexpected callback: %+v", callback)
+	}
+
+	if amount, ok := callback.Amount(); !ok || amount != 1 {
+		t.Fatalf("expecting amount 1 but got %v (%v)", amount, ok)
+	}
+
+	if receipt, ok := callback.MpesaReceiptNumber(); !ok || receipt != "NLJ7RT61SV" {
+		t.Fatalf("unexpected receipt %v (%v)", receipt, ok)
+	}
+
+	if phone, ok := callback.PhoneNumber(); !ok || phone != "251708374149" {
+		t.Fatalf("unexpected phone number %v (%v)", phone, ok)
+	}
+
+	date, ok := callback.TransactionDate()
+	if !ok || date.Year() != 2019 || date.Month() != 12 || date.Day() != 19 || date.Hour() != 10 {
+		t.Fatalf("unexpected transaction date %v (%v)", date, ok)
+	}
+
+	if _, ok := callback.Item("Balance"); ok {
+		t.Fatalf("expecting items without value to be reported as missing")
+	}
+}
+
+func TestSTKCallbackHandler(t *testing.T) {
+	var received *c2b.STKCallback
+	handler := c2b.NewSTKCallbackHandler(func(ctx context.Context, cb *c2b.STKCallback) error {
+		received = cb
+		return nil
+	})
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/callback", strings.NewReader(successfulCallback)))
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expecting 200 but got %v", rec.Code)
+	}
+
+	ack := common.CallbackResponse{}
+	if err := json.NewDecoder(rec.Body).Decode(&ack); err != nil || ack.ResultCode != "0" {
+		t.Fatalf("unexpected acknowledgement %+v (%v)", ack, err)
+	}
+
+	if received == nil || received.MerchantRequestID != "29115-34620561-1" {
+		t.Fatalf("callback function was not invoked with the decoded callback")
+	}
+
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/callback", strings.NewReader(`{"Body":{}}`)))
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("expecting malformed callbacks to be rejected but got %v", rec.Code)
+	}
+}
+
+func TestCallbackHandlersUseTheTracerProvider(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+
+	handler := c2b.NewSTKCallbackHandler(func(ctx context.Context, cb *c2b.STKCallback) error {
+		return nil
+	}, c2b.WithTracerProvider(tp))
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodPost, "/callback", strings.NewReader(successfulCallback)))
+
+	server := newWebhookServer(t, common.CompletedResponse, nil, c2b.WithTracerProvider(tp))
+	postPayment(t, server, "/c2b/validation")
+	postPayment(t, server, "/c2b/confirmation")
+
+	var names []string
+	for _, span := range recorder.Ended() {
+		names = append(names, span.Name())
+	}
+	if strings.Join(names, ",") != "mpesa.callback.stk,mpesa.callback.c2b_validation,mpesa.callback.c2b_confirmation" {
+		t.Fatalf("expecting the spans of the handlers to be recorded but got %v", names)
+	}
+}
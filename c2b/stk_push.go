@@ -1,14 +1,18 @@
 package c2b
 
 import (
+	"context"
 	"encoding/json"
 	"io"
 	"net/http"
-	"slices"
+	"strconv"
 
 	"github.com/coleYab/mpesasdk/common"
 	sdkError "github.com/coleYab/mpesasdk/errors"
+	"github.com/coleYab/mpesasdk/msisdn"
+	"github.com/coleYab/mpesasdk/tracing"
 	"github.com/coleYab/mpesasdk/utils"
+	"github.com/coleYab/mpesasdk/validation"
 )
 
 type ReferenceDataRequest struct {
@@ -66,6 +70,24 @@ func (s *STKPushPaymentRequest) SetPasskey(passkey string) {
     s.passkey = passkey
 }
 
+// NormalizePhoneNumbers rewrites PartyA and PhoneNumber in the canonical form of profile,
+// e.g. "0712 345 678" as "251712345678". Numbers that cannot be parsed are left for Validate to report.
+func (s *STKPushPaymentRequest) NormalizePhoneNumbers(profile *msisdn.Profile) {
+    if number, err := msisdn.Normalize(s.PartyA, profile); err == nil {
+        s.PartyA = number
+    }
+    if number, err := msisdn.Normalize(s.PhoneNumber, profile); err == nil {
+        s.PhoneNumber = number
+    }
+}
+
+// InjectTraceContext adds the trace context of ctx to the query of CallBackURL, so that the
+// callback handler continues the trace of the push. CallBackURL is left as is without a span.
+// The client only calls it when enabled with mpesasdk.WithCallbackTracePropagation.
+func (s *STKPushPaymentRequest) InjectTraceContext(ctx context.Context) {
+    s.CallBackURL = tracing.InjectURL(ctx, s.CallBackURL)
+}
+
 type STKPushRequestSuccessResponse struct {
     MerchantRequestID string `json:"MerchantRequestID"`
     CheckoutRequestID string `json:"CheckoutRequestID"`
@@ -77,31 +99,26 @@ type STKPushRequestSuccessResponse struct {
 type STKPushRequestError STKPushRequestSuccessResponse
 
 func (s *STKPushPaymentRequest) DecodeResponse(res *http.Response) (interface{}, error) {
-    bodyData, _ := io.ReadAll(res.Body)
+    bodyData, err := io.ReadAll(res.Body)
+    if err != nil {
+        return STKPushRequestSuccessResponse{}, sdkError.NewResponseReadError(res.StatusCode, bodyData, err)
+    }
+
     responseData := STKPushRequestSuccessResponse{}
-    err := json.Unmarshal(bodyData, &responseData)
+    err = json.Unmarshal(bodyData, &responseData)
     if err != nil {
-        return STKPushRequestSuccessResponse{}, err
+        return STKPushRequestSuccessResponse{}, sdkError.NewUnexpectedResponseError(res.StatusCode, bodyData, err)
     }
 
-    switch responseData.ResponseCode {
-    case "0":
+    if responseData.ResponseCode == "0" {
         return responseData, nil
-    case "":
-        e := common.MpesaErrorResponse{}
-        err := json.Unmarshal(bodyData, &e)
-        if err != nil {
-            return STKPushRequestSuccessResponse{}, sdkError.ProcessingError(err.Error())
-        }
-        return STKPushRequestSuccessResponse{}, s.decodeError(e)
-    default:
-        return STKPushRequestSuccessResponse{}, s.decodeError(common.MpesaErrorResponse{
-            RequestId: responseData.MerchantRequestID,
-            ErrorCode: responseData.ResponseCode,
-            ErrorMessage: responseData.ResponseDescription,
-        })
     }
 
+    e, err := common.ParseErrorResponse(bodyData)
+    if err != nil {
+        return STKPushRequestSuccessResponse{}, sdkError.NewUnexpectedResponseError(res.StatusCode, bodyData, err)
+    }
+    return STKPushRequestSuccessResponse{}, s.decodeError(res, bodyData, e)
 }
 
 func (t *STKPushPaymentRequest) FillDefaults() {
@@ -109,27 +126,19 @@ func (t *STKPushPaymentRequest) FillDefaults() {
 }
 
 func (t *STKPushPaymentRequest) Validate() error {
-    validTransactionTypes := []common.TransactionType{
-        common.CustomerBuyGoodsOnlineTransaction,
-        common.CustomerPayBillOnlineTransaction,
-    }
-
-    if !slices.Contains(validTransactionTypes, t.TransactionType) {
-        return sdkError.ValidationError("invalid `TransactionType`")
-    }
-
-    if err := utils.ValidateURL(t.CallBackURL); err != nil {
-        return err
-    }
-
-    return nil
+    v := validation.New()
+    v.Shortcode("BusinessShortCode", strconv.FormatUint(uint64(t.BusinessShortCode), 10))
+    v.OneOf("TransactionType", t.TransactionType, common.CustomerBuyGoodsOnlineTransaction, common.CustomerPayBillOnlineTransaction)
+    v.Min("Amount", t.Amount, 1)
+    v.MSISDN("PartyA", t.PartyA)
+    v.Shortcode("PartyB", t.PartyB)
+    v.MSISDN("PhoneNumber", t.PhoneNumber)
+    v.URL("CallBackURL", t.CallBackURL)
+    v.Length("AccountReference", t.AccountReference, 1, validation.MaxAccountReferenceLength)
+    v.Length("TransactionDesc", t.TransactionDesc, 1, validation.MaxTransactionDescLength)
+    return v.Err()
 }
 
-func (s *STKPushPaymentRequest) decodeError(e common.MpesaErrorResponse) error {
-    errorCode := e.ErrorCode
-    switch errorCode {
-        case "SVC0403":
-            return sdkError.AuthenticationError(e.ErrorMessage)
-    }
-    return sdkError.CustomError("UNKOWN_ERROR", e.ErrorMessage)
+func (s *STKPushPaymentRequest) decodeError(res *http.Response, body []byte, e common.MpesaErrorResponse) error {
+    return sdkError.NewAPIError(res.StatusCode, e.RequestId, e.ErrorCode, e.ErrorMessage, body)
 }
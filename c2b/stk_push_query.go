@@ -0,0 +1,129 @@
+package c2b
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"strconv"
+
+	"github.com/coleYab/mpesasdk/common"
+	sdkError "github.com/coleYab/mpesasdk/errors"
+	"github.com/coleYab/mpesasdk/resultcodes"
+	"github.com/coleYab/mpesasdk/utils"
+	"github.com/coleYab/mpesasdk/validation"
+)
+
+// STKPushQueryRequest asks M-Pesa for the status of an STK push, e.g. when its callback did not arrive.
+//
+// Fields:
+//   - BusinessShortCode: The shortcode the STK push was sent with.
+//   - Password: The base64-encoded password, generated from the shortcode, the passkey and the timestamp.
+//   - Timestamp: The time of the request, in the format YYYYMMDDHHMMSS.
+//   - CheckoutRequestID: The CheckoutRequestID of the acknowledged STK push.
+type STKPushQueryRequest struct {
+	BusinessShortCode uint   `json:"BusinessShortCode"`
+	Password          string `json:"Password"`
+	Timestamp         string `json:"Timestamp"`
+	CheckoutRequestID string `json:"CheckoutRequestID"`
+
+	passkey string
+}
+
+// SetPasskey sets the passkey the Password is generated with.
+func (s *STKPushQueryRequest) SetPasskey(passkey string) {
+	s.passkey = passkey
+}
+
+// STKPushQueryResponse is the status of an STK push.
+//
+// Fields:
+//   - ResponseCode, ResponseDescription: The acknowledgement of the query, "0" when it was processed.
+//   - MerchantRequestID, CheckoutRequestID: The identifiers of the STK push.
+//   - ResultCode, ResultDesc: The result of the STK push, like the ones of its callback
+//     (e.g. "0" when paid, "1032" when cancelled by the user).
+type STKPushQueryResponse struct {
+	ResponseCode        string `json:"ResponseCode"`
+	ResponseDescription string `json:"ResponseDescription"`
+	MerchantRequestID   string `json:"MerchantRequestID"`
+	CheckoutRequestID   string `json:"CheckoutRequestID"`
+	ResultCode          string `json:"ResultCode"`
+	ResultDesc          string `json:"ResultDesc"`
+}
+
+// UnmarshalJSON decodes the response, accepting result codes sent as numbers or strings.
+func (r *STKPushQueryResponse) UnmarshalJSON(data []byte) error {
+	type response STKPushQueryResponse
+	aux := struct {
+		*response
+		ResponseCode json.RawMessage `json:"ResponseCode"`
+		ResultCode   json.RawMessage `json:"ResultCode"`
+	}{response: (*response)(r)}
+	if err := json.Unmarshal(data, &aux); err != nil {
+		return err
+	}
+
+	r.ResponseCode = codeString(aux.ResponseCode)
+	r.ResultCode = codeString(aux.ResultCode)
+	return nil
+}
+
+// codeString returns a code sent as a JSON string or number as a string.
+func codeString(raw json.RawMessage) string {
+	var code string
+	if json.Unmarshal(raw, &code) == nil {
+		return code
+	}
+
+	var number json.Number
+	if json.Unmarshal(raw, &number) == nil {
+		return number.String()
+	}
+	return ""
+}
+
+// Successful reports whether the customer completed the payment.
+func (r *STKPushQueryResponse) Successful() bool {
+	return r.ResultCode == "0"
+}
+
+// Info describes the result code of the STK push, e.g. whether the customer can be asked to retry.
+func (r *STKPushQueryResponse) Info() resultcodes.Info {
+	return resultcodes.Lookup(r.ResultCode)
+}
+
+// DecodeResponse decodes the status of the STK push. A query for an STK push still waiting for
+// the customer fails with the "500.001.1001" APIProcessingError code.
+func (s *STKPushQueryRequest) DecodeResponse(res *http.Response) (interface{}, error) {
+	bodyData, err := io.ReadAll(res.Body)
+	if err != nil {
+		return STKPushQueryResponse{}, sdkError.NewResponseReadError(res.StatusCode, bodyData, err)
+	}
+
+	responseData := STKPushQueryResponse{}
+	if err := json.Unmarshal(bodyData, &responseData); err != nil {
+		return STKPushQueryResponse{}, sdkError.NewUnexpectedResponseError(res.StatusCode, bodyData, err)
+	}
+
+	if responseData.ResponseCode == "0" {
+		return responseData, nil
+	}
+
+	e, err := common.ParseErrorResponse(bodyData)
+	if err != nil {
+		return STKPushQueryResponse{}, sdkError.NewUnexpectedResponseError(res.StatusCode, bodyData, err)
+	}
+	return STKPushQueryResponse{}, sdkError.NewAPIError(res.StatusCode, e.RequestId, e.ErrorCode, e.ErrorMessage, bodyData)
+}
+
+// FillDefaults generates the Timestamp and the Password of the request.
+func (s *STKPushQueryRequest) FillDefaults() {
+	s.Timestamp, s.Password = utils.GenerateTimestampAndPassword(s.BusinessShortCode, s.passkey)
+}
+
+// Validate checks the shortcode and the CheckoutRequestID of the request.
+func (s *STKPushQueryRequest) Validate() error {
+	v := validation.New()
+	v.Shortcode("BusinessShortCode", strconv.FormatUint(uint64(s.BusinessShortCode), 10))
+	v.Required("CheckoutRequestID", s.CheckoutRequestID)
+	return v.Err()
+}
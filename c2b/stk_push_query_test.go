@@ -0,0 +1,61 @@
+package c2b_test
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/coleYab/mpesasdk/c2b"
+	sdkError "github.com/coleYab/mpesasdk/errors"
+	"github.com/coleYab/mpesasdk/resultcodes"
+)
+
+func response(status int, body string) *http.Response {
+	return &http.Response{StatusCode: status, Body: io.NopCloser(strings.NewReader(body))}
+}
+
+func TestSTKPushQueryDecodesResultCodes(t *testing.T) {
+	bodies := []string{
+		`{"ResponseCode": "0", "ResponseDescription": "Accepted", "MerchantRequestID": "MR-1", "CheckoutRequestID": "ws_CO-1", "ResultCode": "1032", "ResultDesc": "Request cancelled by user"}`,
+		`{"ResponseCode": 0, "ResponseDescription": "Accepted", "MerchantRequestID": "MR-1", "CheckoutRequestID": "ws_CO-1", "ResultCode": 1032, "ResultDesc": "Request cancelled by user"}`,
+	}
+
+	req := &c2b.STKPushQueryRequest{}
+	for _, body := range bodies {
+		res, err := req.DecodeResponse(response(http.StatusOK, body))
+		if err != nil {
+			t.Fatalf("expecting %s to be decoded but got: %v", body, err)
+		}
+
+		status := res.(c2b.STKPushQueryResponse)
+		if status.CheckoutRequestID != "ws_CO-1" || status.ResultCode != "1032" || status.Successful() ||
+			status.Info().Category != resultcodes.CategoryCustomerAction {
+			t.Fatalf("unexpected response %+v", status)
+		}
+	}
+}
+
+func TestSTKPushQueryReportsPendingPushesAsErrors(t *testing.T) {
+	req := &c2b.STKPushQueryRequest{}
+	_, err := req.DecodeResponse(response(http.StatusInternalServerError,
+		`{"requestId": "req-1", "errorCode": "500.001.1001", "errorMessage": "The transaction is being processed"}`))
+
+	var sdkErr *sdkError.SDKError
+	if !errors.As(err, &sdkErr) || sdkErr.Code() != string(resultcodes.APIProcessingError) || sdkErr.RequestID() != "req-1" {
+		t.Fatalf("expecting the processing error to be returned but got: %v", err)
+	}
+}
+
+func TestSTKPushQueryValidation(t *testing.T) {
+	req := &c2b.STKPushQueryRequest{BusinessShortCode: 554433}
+	if err := req.Validate(); !errors.Is(err, sdkError.ErrValidation) {
+		t.Fatalf("expecting a missing CheckoutRequestID to be rejected but got: %v", err)
+	}
+
+	req.CheckoutRequestID = "ws_CO-1"
+	if err := req.Validate(); err != nil {
+		t.Fatalf("expecting the request to be valid but got: %v", err)
+	}
+}
@@ -10,10 +10,19 @@ import (
 	"io"
 	"net"
 	"net/http"
+	"net/url"
+	"strings"
 	"time"
 
+	"go.opentelemetry.io/otel/trace"
+
 	"github.com/coleYab/mpesasdk/auth"
 	"github.com/coleYab/mpesasdk/common"
+	"github.com/coleYab/mpesasdk/debug"
+	"github.com/coleYab/mpesasdk/metrics"
+	"github.com/coleYab/mpesasdk/redact"
+	"github.com/coleYab/mpesasdk/service"
+	"github.com/coleYab/mpesasdk/tracing"
 	"github.com/coleYab/mpesasdk/utils"
 )
 
@@ -23,11 +32,27 @@ import (
 // Fields:
 //   - client: The underlying http.Client instance used for making requests.
 //   - auth: An instance of AuthorizationToken used to handle authentication.
-//   - maxRetries: The maximum number of retry attempts for timeout errors.
+//   - retryPolicy: Decides which failed attempts are retried.
+//   - operationPolicies: Retry policies overriding retryPolicy for single operations.
+//   - endpoints: The endpoint configuration used to resolve the host of the requests.
+//   - logger: The logger every attempt is logged to.
+//   - redactor: Masks the secrets of the errors returned for failed requests and of the recorded exchanges.
+//   - debugSink: Receives every attempt when the wire debug mode is enabled.
+//   - interceptors: Wrap the calls sent with Execute, in order.
+//   - tracer: Creates the span of every attempt.
+//   - metrics: Receives the retries.
 type HttpClient struct {
-	client     *http.Client
-	auth       *auth.AuthorizationToken
-	maxRetries uint
+	client            *http.Client
+	auth              *auth.AuthorizationToken
+	retryPolicy       RetryPolicy
+	operationPolicies map[common.Operation]RetryPolicy
+	endpoints         *utils.Endpoints
+	logger            service.StructuredLogger
+	redactor          *redact.Redactor
+	debugSink         debug.Sink
+	interceptors      []Interceptor
+	tracer            trace.Tracer
+	metrics           metrics.Metrics
 }
 
 // NewHttpClient creates a new instance of HttpClient.
@@ -40,20 +65,93 @@ type HttpClient struct {
 // Returns:
 //   - A pointer to the initialized HttpClient.
 func NewHttpClient(timeout time.Duration, maxRetries uint, auth *auth.AuthorizationToken) *HttpClient {
+	return NewCustomHttpClient(&http.Client{Timeout: timeout}, NewTimeoutRetryPolicy(maxRetries), auth)
+}
+
+// NewCustomHttpClient creates a new instance of HttpClient around a caller supplied http.Client,
+// allowing a tuned transport to be used.
+//
+// Parameters:
+//   - client: The http.Client used to send the requests.
+//   - retryPolicy: Decides which failed attempts are retried, nil disables retries.
+//   - auth: An instance of AuthorizationToken for managing authentication.
+//
+// Returns:
+//   - A pointer to the initialized HttpClient.
+func NewCustomHttpClient(client *http.Client, retryPolicy RetryPolicy, auth *auth.AuthorizationToken) *HttpClient {
+	if retryPolicy == nil {
+		retryPolicy = NewTimeoutRetryPolicy(0)
+	}
+
 	return &HttpClient{
-		client: &http.Client{
-			Timeout: timeout,
-		},
-		maxRetries: maxRetries,
-		auth:       auth,
+		client:            client,
+		retryPolicy:       retryPolicy,
+		operationPolicies: map[common.Operation]RetryPolicy{},
+		auth:              auth,
+		endpoints:         utils.NewEndpoints(),
+		logger:            service.NewNopLogger(),
+		redactor:          redact.New(),
+		tracer:            tracing.Tracer(nil),
+		metrics:           metrics.Nop{},
 	}
 }
 
+// SetRetryPolicy replaces the policy deciding which failed attempts are retried.
+func (c *HttpClient) SetRetryPolicy(retryPolicy RetryPolicy) {
+	c.retryPolicy = retryPolicy
+}
+
+// SetOperationRetryPolicy replaces the retry policy of a single operation, e.g. to only retry
+// non-idempotent operations when it is safe. A nil policy reverts to the client's policy.
+func (c *HttpClient) SetOperationRetryPolicy(op common.Operation, retryPolicy RetryPolicy) {
+	if retryPolicy == nil {
+		delete(c.operationPolicies, op)
+		return
+	}
+	c.operationPolicies[op] = retryPolicy
+}
+
+// SetLogger sets the logger every attempt is logged to, attempts are not logged by default.
+func (c *HttpClient) SetLogger(logger service.StructuredLogger) {
+	c.logger = logger
+}
+
+// SetRedactor sets the Redactor masking the secrets of the errors returned for failed requests,
+// such as the apikey of a URL. A nil redactor disables masking.
+func (c *HttpClient) SetRedactor(redactor *redact.Redactor) {
+	c.redactor = redactor
+}
+
+// SetDebugSink enables the wire debug mode: every attempt, including the headers and bodies of
+// its request and response, is recorded to sink after being masked by the redactor. A nil sink
+// disables it.
+func (c *HttpClient) SetDebugSink(sink debug.Sink) {
+	c.debugSink = sink
+}
+
+// SetTracerProvider sets the provider of the spans created for every attempt, the global
+// tracer provider by default.
+func (c *HttpClient) SetTracerProvider(tp trace.TracerProvider) {
+	c.tracer = tracing.Tracer(tp)
+}
+
+// SetMetrics sets the Metrics the retries are reported to, none by default.
+func (c *HttpClient) SetMetrics(m metrics.Metrics) {
+	c.metrics = m
+}
+
+// SetEndpoints sets the endpoint configuration the host of the requests is resolved from.
+func (c *HttpClient) SetEndpoints(endpoints *utils.Endpoints) {
+	c.endpoints = endpoints
+}
+
 // ApiRequest sends an HTTP request to the specified M-Pesa API endpoint.
 //
 // Parameters:
+//   - ctx: The context controlling cancellation and deadlines of the request, including retries.
 //   - env: The environment (sandbox or production) to determine the base URL.
-//   - endpoint: The API endpoint to call.
+//   - op: The operation of the request, selecting its retry policy.
+//   - endpoint: The path of the API endpoint to call, relative to the configured base URL.
 //   - method: The HTTP method (e.g., "GET", "POST").
 //   - payload: The request payload, serialized to JSON.
 //   - authType: The type of authorization to use (e.g., "Bearer", "Basic").
@@ -61,9 +159,27 @@ func NewHttpClient(timeout time.Duration, maxRetries uint, auth *auth.Authorizat
 // Returns:
 //   - *http.Response: The HTTP response from the server.
 //   - error: Any error encountered during the request.
-func (c *HttpClient) ApiRequest(env common.Enviroment, endpoint, method string, payload interface{}, authType string) (*http.Response, error) {
-	url := utils.ConstructURL(env, endpoint)
+func (c *HttpClient) ApiRequest(ctx context.Context, env common.Enviroment, op common.Operation, endpoint, method string, payload interface{}, authType string) (*http.Response, error) {
+	req, err := c.NewRequest(ctx, env, endpoint, method, payload)
+	if err != nil {
+		return nil, err
+	}
+	return c.Do(ctx, env, op, req, authType)
+}
 
+// NewRequest creates the HTTP request of an M-Pesa API endpoint, without its authorization.
+//
+// Parameters:
+//   - ctx: The context the request is bound to.
+//   - env: The environment (sandbox or production) to determine the base URL.
+//   - endpoint: The path of the API endpoint to call, relative to the configured base URL.
+//   - method: The HTTP method (e.g., "GET", "POST").
+//   - payload: The request payload, serialized to JSON.
+//
+// Returns:
+//   - *http.Request: The request, which can be sent with Do.
+//   - error: Any error encountered while creating the request.
+func (c *HttpClient) NewRequest(ctx context.Context, env common.Enviroment, endpoint, method string, payload interface{}) (*http.Request, error) {
 	var body io.Reader
 	if payload != nil {
 		jsonData, err := json.Marshal(payload)
@@ -73,57 +189,231 @@ func (c *HttpClient) ApiRequest(env common.Enviroment, endpoint, method string,
 		body = bytes.NewReader(jsonData)
 	}
 
+	req, err := http.NewRequestWithContext(ctx, method, c.endpoints.BaseURL(env)+endpoint, body)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Add("Content-Type", "application/json")
+	return req, nil
+}
+
+// Do sends a request created with NewRequest, retrying it as decided by the retry policy of
+// the operation. Every attempt sends a copy of req carrying the authorization of authType.
+//
+// Parameters:
+//   - ctx: The context controlling cancellation and deadlines of the request, including retries.
+//   - env: The environment (sandbox or production) the authorization token is requested from.
+//   - op: The operation of the request, selecting its retry policy.
+//   - req: The request to send.
+//   - authType: The type of authorization to use (e.g., "Bearer", "Basic").
+//
+// Returns:
+//   - *http.Response: The HTTP response from the server.
+//   - error: Any error encountered during the request.
+func (c *HttpClient) Do(ctx context.Context, env common.Enviroment, op common.Operation, req *http.Request, authType string) (*http.Response, error) {
+	res, _, _, err := c.send(ctx, env, op, req, authType)
+	return res, err
+}
+
+// send implements Do, also returning the number of attempts made and whether the request of
+// any of them may have reached the API.
+func (c *HttpClient) send(ctx context.Context, env common.Enviroment, op common.Operation, req *http.Request, authType string) (*http.Response, uint, bool, error) {
+	retryPolicy := c.retryPolicy
+	if policy, ok := c.operationPolicies[op]; ok {
+		retryPolicy = policy
+	}
+
 	var res *http.Response
 	var err error
+	var attempt uint
+	var sent bool
+
+	// Retry loop, the retry policy decides which failures are worth another attempt
+	for ; ; attempt++ {
+		var exchange *debug.Exchange
+		if c.debugSink != nil {
+			exchange = &debug.Exchange{Operation: op, Attempt: attempt + 1}
+		}
+
+		attemptCtx, span := c.tracer.Start(ctx, "mpesa.attempt", trace.WithSpanKind(trace.SpanKindClient), trace.WithAttributes(
+			tracing.OperationKey.String(string(op)),
+			tracing.AttemptKey.Int(int(attempt+1)),
+			tracing.HTTPMethodKey.String(req.Method),
+		))
 
-	// Retry loop for handling timeout errors
-	for attempt := uint(0); attempt <= c.maxRetries; attempt++ {
-		res, err = c.makeRequest(url, method, body, authType, env)
-		if err == nil || !isTimeoutError(err) {
+		start := time.Now()
+		var written bool
+		res, written, err = c.makeRequest(attemptCtx, req, authType, env, exchange)
+		sent = sent || written
+		if res != nil {
+			span.SetAttributes(tracing.HTTPStatusCodeKey.Int(res.StatusCode))
+		}
+		tracing.End(span, err)
+		if exchange != nil {
+			c.debugSink.Record(ctx, *exchange)
+		}
+		fields := attemptFields(op, req.URL.Path, req.Method, attempt, time.Since(start), res, err)
+		c.logger.Debug("api request attempt", fields...)
+		if ctx.Err() != nil {
 			break
 		}
 
-		// Add a delay before the next retry
-		time.Sleep(time.Duration(attempt+1) * time.Second)
+		delay, retry := retryPolicy.Retry(attempt, res, err)
+		if !retry {
+			break
+		}
+		c.logger.Warn("retrying api request", append(fields, "delay", delay)...)
+		c.metrics.RetryScheduled(op, attempt+1)
+
+		if res != nil {
+			res.Body.Close()
+		}
+
+		// Add a delay before the next retry, giving up early if the context is done
+		if err := sleepContext(ctx, delay); err != nil {
+			return nil, attempt + 1, sent, err
+		}
 	}
 
-	return res, err
+	return res, attempt + 1, sent, err
 }
 
-// makeRequest constructs and sends an HTTP request with the given parameters.
+// makeRequest sends an attempt of a request, carrying the authorization of authType.
 //
 // Parameters:
-//   - url: The full URL of the API endpoint.
-//   - method: The HTTP method (e.g., "GET", "POST").
-//   - body: The request body, if applicable.
+//   - ctx: The context the attempt is bound to.
+//   - template: The request the attempt is a copy of, left untouched.
 //   - authType: The type of authorization to use (e.g., "Bearer", "Basic").
 //   - env: The environment (sandbox or production).
+//   - exchange: The exchange the request and response are recorded in, nil when not debugging.
 //
 // Returns:
 //   - *http.Response: The HTTP response from the server.
+//   - bool: false if the request certainly did not reach the API, e.g. the token could not be
+//     fetched or the connection could not be established.
 //   - error: Any error encountered during the request.
-func (c *HttpClient) makeRequest(url, method string, body io.Reader, authType string, env common.Enviroment) (*http.Response, error) {
-	req, err := http.NewRequest(method, url, body)
-	if err != nil {
-		return nil, err
+func (c *HttpClient) makeRequest(ctx context.Context, template *http.Request, authType string, env common.Enviroment, exchange *debug.Exchange) (*http.Response, bool, error) {
+	req := template.Clone(ctx)
+	if template.GetBody != nil {
+		body, err := template.GetBody()
+		if err != nil {
+			return nil, false, err
+		}
+		req.Body = body
 	}
 
-	req.Header.Add("Content-Type", "application/json")
-
 	// Handle authorization based on the specified authType
 	switch authType {
 	case auth.AuthTypeBearer:
 		key, secret := c.auth.GetConsumerKeyAndSecret()
-		authToken, err := c.auth.GetAuthorizationToken(env, key, secret)
+		authToken, err := c.auth.GetAuthorizationToken(ctx, env, key, secret)
 		if err != nil {
-			return nil, err
+			return nil, false, err
 		}
-		req.Header.Add("Authorization", authToken)
+		req.Header.Set("Authorization", authToken)
 	case auth.AuthTypeBasic:
 		req.SetBasicAuth(c.auth.GetConsumerKeyAndSecret())
 	}
 
-	return c.client.Do(req)
+	start := time.Now()
+	res, err := c.client.Do(req)
+	var urlErr *url.Error
+	if c.redactor != nil && errors.As(err, &urlErr) {
+		// The error message quotes the URL, which may hold credentials
+		redacted := *urlErr
+		redacted.URL = c.redactor.URL(urlErr.URL)
+		err = &redacted
+	}
+
+	if exchange != nil {
+		c.record(exchange, req, res, err, start)
+	}
+	return res, err == nil || !SafeRetryable(nil, err), err
+}
+
+// record fills exchange with the masked request and response of an attempt. The body of res is
+// read and replaced, so that the caller can still read it, including the error that may have
+// interrupted reading it.
+func (c *HttpClient) record(exchange *debug.Exchange, req *http.Request, res *http.Response, err error, start time.Time) {
+	exchange.Method = req.Method
+	exchange.URL = req.URL.String()
+	exchange.RequestHeader = req.Header.Clone()
+	if req.GetBody != nil {
+		if body, bodyErr := req.GetBody(); bodyErr == nil {
+			exchange.RequestBody, _ = io.ReadAll(body)
+		}
+	}
+	exchange.Start = start
+	exchange.Err = err
+
+	if res != nil {
+		data, readErr := io.ReadAll(res.Body)
+		res.Body.Close()
+		res.Body = io.NopCloser(io.MultiReader(bytes.NewReader(data), errReader{readErr}))
+
+		exchange.Status = res.StatusCode
+		exchange.ResponseHeader = res.Header.Clone()
+		exchange.ResponseBody = data
+		if readErr != nil {
+			exchange.Err = readErr
+		}
+	}
+	exchange.Duration = time.Since(start)
+
+	if c.redactor != nil {
+		exchange.URL = c.redactor.URL(exchange.URL)
+		exchange.RequestHeader = c.redactor.Header(exchange.RequestHeader)
+		exchange.ResponseHeader = c.redactor.Header(exchange.ResponseHeader)
+		exchange.RequestBody = c.redactor.JSON(exchange.RequestBody)
+		exchange.ResponseBody = c.redactor.JSON(exchange.ResponseBody)
+		if exchange.Err != nil {
+			exchange.Err = errors.New(c.redactor.String(exchange.Err.Error()))
+		}
+	}
+}
+
+// errReader is an io.Reader returning err, io.EOF when err is nil.
+type errReader struct {
+	err error
+}
+
+// Read implements io.Reader.
+func (r errReader) Read([]byte) (int, error) {
+	if r.err == nil {
+		return 0, io.EOF
+	}
+	return 0, r.err
+}
+
+// attemptFields returns the log fields describing an attempt of a request.
+func attemptFields(op common.Operation, endpoint, method string, attempt uint, latency time.Duration, res *http.Response, err error) []any {
+	// The query may hold credentials, such as the apikey of the URL registration
+	path, _, _ := strings.Cut(endpoint, "?")
+	fields := []any{"operation", op, "endpoint", path, "method", method, "attempt", attempt + 1, "latency", latency}
+	if res != nil {
+		fields = append(fields, "status", res.StatusCode)
+	}
+	if err != nil {
+		fields = append(fields, "error", err)
+	}
+	return fields
+}
+
+// sleepContext pauses for the given duration or until the context is done,
+// whichever happens first.
+//
+// Returns:
+//   - error: The context error if the context finished before the duration elapsed.
+func sleepContext(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
 }
 
 // isTimeoutError checks whether the given error is related to a timeout.
@@ -0,0 +1,76 @@
+package client_test
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/coleYab/mpesasdk/client"
+	"github.com/coleYab/mpesasdk/common"
+	"github.com/coleYab/mpesasdk/debug"
+	"github.com/coleYab/mpesasdk/utils"
+)
+
+func TestApiRequestErrorsAreRedacted(t *testing.T) {
+	server := httptest.NewServer(http.NotFoundHandler())
+	server.Close()
+
+	endpoints := utils.NewEndpoints()
+	endpoints.SetBaseURL(server.URL)
+	c := client.NewCustomHttpClient(server.Client(), nil, nil)
+	c.SetEndpoints(endpoints)
+
+	_, err := c.ApiRequest(context.Background(), common.SANDBOX, common.RegisterURLOperation, "/register?apikey=consumer-key", http.MethodPost, nil, "")
+	if err == nil || strings.Contains(err.Error(), "consumer-key") || !strings.Contains(err.Error(), "apikey=%5BREDACTED%5D") {
+		t.Fatalf("expecting the apikey to be redacted from the error but got %v", err)
+	}
+}
+
+func TestApiRequestsAreRecordedToTheDebugSink(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusInternalServerError)
+		io.WriteString(w, `{"errorCode":"500.003.1001","errorMessage":"Internal Server Error"}`)
+	}))
+	defer server.Close()
+
+	endpoints := utils.NewEndpoints()
+	endpoints.SetBaseURL(server.URL)
+	c := client.NewCustomHttpClient(server.Client(), nil, nil)
+	c.SetEndpoints(endpoints)
+	sink := debug.NewMemorySink(5)
+	c.SetDebugSink(sink)
+
+	payload := map[string]string{"PhoneNumber": "251712345678", "Password": "secret"}
+	res, err := c.ApiRequest(context.Background(), common.SANDBOX, common.STKPushOperation, "/stkpush?apikey=consumer-key", http.MethodPost, payload, "")
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	defer res.Body.Close()
+
+	body, err := io.ReadAll(res.Body)
+	if err != nil || !strings.Contains(string(body), "500.003.1001") {
+		t.Fatalf("expecting the body to remain readable but got %q, %v", body, err)
+	}
+
+	exchange, ok := sink.Last()
+	if !ok {
+		t.Fatal("expecting the request to be recorded")
+	}
+	if exchange.Operation != common.STKPushOperation || exchange.Attempt != 1 || exchange.Method != http.MethodPost ||
+		exchange.Status != http.StatusInternalServerError || exchange.Duration <= 0 {
+		t.Fatalf("unexpected exchange %+v", exchange)
+	}
+	if strings.Contains(exchange.URL, "consumer-key") {
+		t.Fatalf("expecting the apikey to be redacted from %v", exchange.URL)
+	}
+	if got := string(exchange.RequestBody); strings.Contains(got, "secret") || strings.Contains(got, "251712345678") {
+		t.Fatalf("expecting the request body to be redacted but got %v", got)
+	}
+	if !strings.Contains(string(exchange.ResponseBody), "Internal Server Error") || exchange.ResponseHeader.Get("Content-Type") != "application/json" {
+		t.Fatalf("unexpected response %v %s", exchange.ResponseHeader, exchange.ResponseBody)
+	}
+}
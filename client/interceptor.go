@@ -0,0 +1,159 @@
+package client
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/coleYab/mpesasdk/common"
+	sdkError "github.com/coleYab/mpesasdk/errors"
+	"github.com/coleYab/mpesasdk/service"
+)
+
+// Call is a call to the M-Pesa API going through the interceptors of an HttpClient.
+//
+// Fields:
+//   - Operation: The operation of the call.
+//   - Endpoint: The path of the API endpoint, without its query.
+//   - Method: The HTTP method (e.g., "GET", "POST").
+//   - Env: The environment (sandbox or production) of the call.
+//   - AuthType: The type of authorization of the call (e.g., "Bearer", "Basic").
+//   - Request: The validated request, with its defaults filled.
+//   - HTTPRequest: The HTTP request sent for the call, without its authorization. Interceptors
+//     may change it, e.g. add headers, before calling the next RoundTrip.
+//   - HTTPResponse: The HTTP response of the last attempt, set once the next RoundTrip returned
+//     and nil if none was received. Its body is already read and closed.
+//   - Attempts: The number of attempts made, set once the next RoundTrip returned.
+//   - Sent: Whether the HTTP request of any attempt may have reached the API, set once the next
+//     RoundTrip returned. It is false when every attempt failed before its request was written,
+//     e.g. because the token could not be fetched or the connection could not be established,
+//     so that a non-idempotent call can safely be sent again.
+type Call struct {
+	Operation    common.Operation
+	Endpoint     string
+	Method       string
+	Env          common.Enviroment
+	AuthType     string
+	Request      common.MpesaRequest
+	HTTPRequest  *http.Request
+	HTTPResponse *http.Response
+	Attempts     uint
+	Sent         bool
+}
+
+// RoundTrip sends a call and returns its decoded response, e.g. a b2c.B2CSuccessResponse.
+type RoundTrip func(ctx context.Context, call *Call) (interface{}, error)
+
+// Interceptor wraps the RoundTrip sending a call, to act before and after it. An interceptor
+// may also return without calling next, e.g. to serve a call from a cache.
+//
+// Example:
+//
+//	func AuditInterceptor(next client.RoundTrip) client.RoundTrip {
+//	    return func(ctx context.Context, call *client.Call) (interface{}, error) {
+//	        call.HTTPRequest.Header.Set("X-Audit-ID", auditID(ctx))
+//	        res, err := next(ctx, call)
+//	        audit(ctx, call.Operation, res, err)
+//	        return res, err
+//	    }
+//	}
+type Interceptor func(next RoundTrip) RoundTrip
+
+// Chain combines interceptors into one, running them in order: the first interceptor is the
+// outermost, it sees the call first and its response last.
+func Chain(interceptors ...Interceptor) Interceptor {
+	return func(next RoundTrip) RoundTrip {
+		for i := len(interceptors) - 1; i >= 0; i-- {
+			next = interceptors[i](next)
+		}
+		return next
+	}
+}
+
+// Use adds interceptors around the calls sent with Execute. Interceptors run in the order they
+// were added, see Chain. It is not safe to call Use concurrently with Execute.
+func (c *HttpClient) Use(interceptors ...Interceptor) {
+	c.interceptors = append(c.interceptors, interceptors...)
+}
+
+// Execute sends a call through the interceptors and decodes its response with the
+// DecodeResponse method of its request. The HTTPRequest of the call is created when nil.
+//
+// Parameters:
+//   - ctx: The context controlling cancellation and deadlines of the call, including retries.
+//   - call: The call to send.
+//
+// Returns:
+//   - The decoded response.
+//   - An error if the request could not be sent or the API returned an error.
+func (c *HttpClient) Execute(ctx context.Context, call *Call) (interface{}, error) {
+	if call.HTTPRequest == nil {
+		req, err := c.NewRequest(ctx, call.Env, call.Endpoint, call.Method, call.Request)
+		if err != nil {
+			return nil, err
+		}
+		call.HTTPRequest = req
+	}
+	call.Endpoint, _, _ = strings.Cut(call.Endpoint, "?")
+
+	return Chain(c.interceptors...)(c.roundTrip)(ctx, call)
+}
+
+// roundTrip is the innermost RoundTrip, sending the HTTP request of a call and decoding its response.
+func (c *HttpClient) roundTrip(ctx context.Context, call *Call) (interface{}, error) {
+	res, attempts, sent, err := c.send(ctx, call.Env, call.Operation, call.HTTPRequest, call.AuthType)
+	call.Attempts, call.Sent = attempts, sent
+	if err != nil {
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		return nil, sdkError.NewTransportError(err)
+	}
+	defer res.Body.Close()
+	call.HTTPResponse = res
+
+	decoded, err := call.Request.DecodeResponse(res)
+	if err != nil && ctx.Err() != nil {
+		// The body read was interrupted by cancellation, report that instead of a decoding failure
+		return decoded, ctx.Err()
+	}
+	return decoded, err
+}
+
+// LoggingInterceptor logs every call to logger once it completed, with its operation, endpoint,
+// method, status and latency, as "call succeeded" at the INFO level or "call failed" at the
+// ERROR level.
+func LoggingInterceptor(logger service.StructuredLogger) Interceptor {
+	return func(next RoundTrip) RoundTrip {
+		return func(ctx context.Context, call *Call) (interface{}, error) {
+			start := time.Now()
+			res, err := next(ctx, call)
+
+			fields := []any{"operation", call.Operation, "endpoint", call.Endpoint, "method", call.Method}
+			if call.HTTPResponse != nil {
+				fields = append(fields, "status", call.HTTPResponse.StatusCode)
+			}
+			fields = append(fields, "latency", time.Since(start))
+			if err != nil {
+				logger.Error("call failed", append(fields, "error", err)...)
+			} else {
+				logger.Info("call succeeded", fields...)
+			}
+			return res, err
+		}
+	}
+}
+
+// TimingInterceptor reports the duration of every call, including its retries, to observe,
+// e.g. to feed a latency histogram.
+func TimingInterceptor(observe func(call *Call, duration time.Duration, err error)) Interceptor {
+	return func(next RoundTrip) RoundTrip {
+		return func(ctx context.Context, call *Call) (interface{}, error) {
+			start := time.Now()
+			res, err := next(ctx, call)
+			observe(call, time.Since(start), err)
+			return res, err
+		}
+	}
+}
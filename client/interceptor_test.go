@@ -0,0 +1,96 @@
+package client_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/coleYab/mpesasdk/client"
+	"github.com/coleYab/mpesasdk/common"
+	"github.com/coleYab/mpesasdk/utils"
+)
+
+// echoRequest is a request decoding the header the server echoes back.
+type echoRequest struct {
+	Amount uint64
+}
+
+func (r *echoRequest) Validate() error { return nil }
+
+func (r *echoRequest) FillDefaults() {}
+
+func (r *echoRequest) DecodeResponse(res *http.Response) (interface{}, error) {
+	var decoded map[string]string
+	err := json.NewDecoder(res.Body).Decode(&decoded)
+	return decoded, err
+}
+
+func TestInterceptorsRunInOrder(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]string{"trace": r.Header.Get("X-Trace")})
+	}))
+	defer server.Close()
+
+	endpoints := utils.NewEndpoints()
+	endpoints.SetBaseURL(server.URL)
+	c := client.NewCustomHttpClient(server.Client(), nil, nil)
+	c.SetEndpoints(endpoints)
+
+	var order []string
+	tracing := func(name string) client.Interceptor {
+		return func(next client.RoundTrip) client.RoundTrip {
+			return func(ctx context.Context, call *client.Call) (interface{}, error) {
+				order = append(order, "before "+name)
+				call.HTTPRequest.Header.Add("X-Trace", name)
+				res, err := next(ctx, call)
+				order = append(order, "after "+name)
+				return res, err
+			}
+		}
+	}
+
+	var timed *client.Call
+	c.Use(tracing("outer"), tracing("inner"))
+	c.Use(client.TimingInterceptor(func(call *client.Call, duration time.Duration, err error) {
+		timed = call
+	}))
+
+	call := &client.Call{Operation: common.B2COperation, Endpoint: "/b2c?apikey=key", Method: http.MethodPost, Request: &echoRequest{Amount: 10}}
+	res, err := c.Execute(context.Background(), call)
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+
+	if decoded := res.(map[string]string); decoded["trace"] != "outer" {
+		t.Fatalf("expecting the header of the interceptors to be sent but got %v", decoded)
+	}
+	if call.HTTPRequest.Header.Values("X-Trace")[1] != "inner" || call.HTTPResponse.StatusCode != http.StatusOK || call.Endpoint != "/b2c" {
+		t.Fatalf("unexpected call %+v", call)
+	}
+	if want := []string{"before outer", "before inner", "after inner", "after outer"}; len(order) != len(want) ||
+		order[0] != want[0] || order[1] != want[1] || order[2] != want[2] || order[3] != want[3] {
+		t.Fatalf("expecting the interceptors to run in order but got %v", order)
+	}
+	if timed != call {
+		t.Fatal("expecting the call to be timed")
+	}
+}
+
+func TestInterceptorsCanShortCircuit(t *testing.T) {
+	c := client.NewCustomHttpClient(http.DefaultClient, nil, nil)
+	denied := errors.New("denied")
+	c.Use(func(next client.RoundTrip) client.RoundTrip {
+		return func(ctx context.Context, call *client.Call) (interface{}, error) {
+			return nil, denied
+		}
+	})
+
+	call := &client.Call{Operation: common.B2COperation, Endpoint: "/b2c", Method: http.MethodPost, Request: &echoRequest{}}
+	if _, err := c.Execute(context.Background(), call); !errors.Is(err, denied) || call.HTTPResponse != nil {
+		t.Fatalf("expecting the call not to be sent but got %v", err)
+	}
+}
@@ -0,0 +1,178 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"io"
+	"math/rand/v2"
+	"net"
+	"net/http"
+	"strconv"
+	"syscall"
+	"time"
+)
+
+// RetryPolicy decides whether a failed attempt of an API request is retried and how long
+// the client waits before the next attempt.
+type RetryPolicy interface {
+	// Retry is called after every attempt with its zero-based index and its outcome.
+	//
+	// Returns:
+	//   - The delay before the next attempt.
+	//   - false to return the outcome of this attempt to the caller.
+	Retry(attempt uint, res *http.Response, err error) (time.Duration, bool)
+}
+
+// TimeoutRetryPolicy retries requests that failed with a timeout error, waiting one more
+// second before every attempt.
+//
+// Fields:
+//   - MaxRetries: The maximum number of retries after the first attempt.
+type TimeoutRetryPolicy struct {
+	MaxRetries uint
+}
+
+// NewTimeoutRetryPolicy creates a TimeoutRetryPolicy allowing maxRetries retries.
+func NewTimeoutRetryPolicy(maxRetries uint) *TimeoutRetryPolicy {
+	return &TimeoutRetryPolicy{MaxRetries: maxRetries}
+}
+
+// Retry implements RetryPolicy.
+func (p *TimeoutRetryPolicy) Retry(attempt uint, res *http.Response, err error) (time.Duration, bool) {
+	if err == nil || !isTimeoutError(err) || attempt >= p.MaxRetries {
+		return 0, false
+	}
+	return time.Duration(attempt+1) * time.Second, true
+}
+
+// Default settings of the retry policies created by NewDefaultRetryPolicy and NewSafeRetryPolicy.
+const (
+	DefaultMaxAttempts = 3
+	DefaultBaseDelay   = 500 * time.Millisecond
+	DefaultMaxDelay    = 10 * time.Second
+	DefaultJitter      = 0.2
+)
+
+// BackoffRetryPolicy retries the attempts its Retryable predicate accepts, waiting exponentially
+// longer before every attempt. A Retry-After header sent with the response takes precedence over
+// the computed delay.
+//
+// Fields:
+//   - MaxAttempts: The maximum number of attempts, including the first one.
+//   - BaseDelay: The delay before the first retry, doubled before every following one.
+//   - MaxDelay: The maximum delay between two attempts. A longer Retry-After is not waited for,
+//     the response is returned instead.
+//   - Jitter: The fraction (0 to 1) of every delay that is randomized, so that clients failing
+//     together do not retry together.
+//   - Retryable: Decides which outcomes are worth another attempt.
+type BackoffRetryPolicy struct {
+	MaxAttempts uint
+	BaseDelay   time.Duration
+	MaxDelay    time.Duration
+	Jitter      float64
+	Retryable   func(res *http.Response, err error) bool
+}
+
+// NewDefaultRetryPolicy creates the BackoffRetryPolicy used by default, retrying timeouts,
+// refused, reset and closed connections, and 429, 502, 503 and 504 responses.
+func NewDefaultRetryPolicy() *BackoffRetryPolicy {
+	return &BackoffRetryPolicy{
+		MaxAttempts: DefaultMaxAttempts,
+		BaseDelay:   DefaultBaseDelay,
+		MaxDelay:    DefaultMaxDelay,
+		Jitter:      DefaultJitter,
+		Retryable:   DefaultRetryable,
+	}
+}
+
+// NewSafeRetryPolicy creates a BackoffRetryPolicy for non-idempotent operations such as B2C
+// payments and reversals, only retrying attempts the API certainly did not process.
+func NewSafeRetryPolicy() *BackoffRetryPolicy {
+	policy := NewDefaultRetryPolicy()
+	policy.Retryable = SafeRetryable
+	return policy
+}
+
+// Retry implements RetryPolicy.
+func (p *BackoffRetryPolicy) Retry(attempt uint, res *http.Response, err error) (time.Duration, bool) {
+	if attempt+1 >= p.MaxAttempts || p.Retryable == nil || !p.Retryable(res, err) {
+		return 0, false
+	}
+
+	if delay, ok := retryAfter(res); ok {
+		if p.MaxDelay > 0 && delay > p.MaxDelay {
+			return 0, false
+		}
+		return delay, true
+	}
+
+	delay := p.BaseDelay << min(attempt, 30)
+	if delay <= 0 || p.MaxDelay > 0 && delay > p.MaxDelay {
+		delay = p.MaxDelay
+	}
+	if p.Jitter > 0 {
+		delay -= time.Duration(float64(delay) * min(p.Jitter, 1) * rand.Float64())
+	}
+	return delay, true
+}
+
+// DefaultRetryable reports whether an attempt failed with a timeout, a refused or reset
+// connection, a connection closed before the response was received, or a 429, 502, 503 or
+// 504 response.
+func DefaultRetryable(res *http.Response, err error) bool {
+	if err != nil {
+		return !errors.Is(err, context.Canceled) && isNetworkError(err)
+	}
+
+	switch res.StatusCode {
+	case http.StatusTooManyRequests, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	}
+	return false
+}
+
+// SafeRetryable reports whether an attempt certainly was not processed by the API: the
+// connection could not be established, or the request was rejected with a 429 or 503
+// response. Timeouts, 502 and 504 responses are not retried as the request may have been
+// processed.
+func SafeRetryable(res *http.Response, err error) bool {
+	if err != nil {
+		var opErr *net.OpError
+		return errors.As(err, &opErr) && opErr.Op == "dial"
+	}
+
+	return res.StatusCode == http.StatusTooManyRequests || res.StatusCode == http.StatusServiceUnavailable
+}
+
+// retryAfter returns the delay requested by the Retry-After header of res, if any.
+func retryAfter(res *http.Response) (time.Duration, bool) {
+	if res == nil {
+		return 0, false
+	}
+
+	value := res.Header.Get("Retry-After")
+	if value == "" {
+		return 0, false
+	}
+
+	if seconds, err := strconv.Atoi(value); err == nil && seconds >= 0 {
+		return time.Duration(seconds) * time.Second, true
+	}
+
+	if date, err := http.ParseTime(value); err == nil {
+		return max(time.Until(date), 0), true
+	}
+	return 0, false
+}
+
+// isNetworkError checks whether the given error is a timeout, or a connection refused, reset or
+// closed before the response was received. Other errors, such as TLS failures, unsupported
+// schemes or malformed URLs, would fail again the same way.
+func isNetworkError(err error) bool {
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() || isTimeoutError(err) {
+		return true
+	}
+	return errors.Is(err, syscall.ECONNREFUSED) || errors.Is(err, syscall.ECONNRESET) ||
+		errors.Is(err, io.ErrUnexpectedEOF) || errors.Is(err, io.EOF)
+}
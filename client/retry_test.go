@@ -0,0 +1,139 @@
+package client_test
+
+import (
+	"context"
+	"crypto/x509"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"sync/atomic"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/coleYab/mpesasdk/client"
+	"github.com/coleYab/mpesasdk/common"
+	"github.com/coleYab/mpesasdk/utils"
+)
+
+func TestBackoffRetryPolicy(t *testing.T) {
+	policy := &client.BackoffRetryPolicy{
+		MaxAttempts: 4,
+		BaseDelay:   100 * time.Millisecond,
+		MaxDelay:    time.Second,
+		Retryable:   client.DefaultRetryable,
+	}
+
+	status := func(code int, header ...string) *http.Response {
+		res := &http.Response{StatusCode: code, Header: http.Header{}}
+		if len(header) == 2 {
+			res.Header.Set(header[0], header[1])
+		}
+		return res
+	}
+
+	tests := []struct {
+		name    string
+		attempt uint
+		res     *http.Response
+		err     error
+		delay   time.Duration
+		retry   bool
+	}{
+		{"success", 0, status(http.StatusOK), nil, 0, false},
+		{"bad request", 0, status(http.StatusBadRequest), nil, 0, false},
+		{"rate limited", 0, status(http.StatusTooManyRequests), nil, 100 * time.Millisecond, true},
+		{"bad gateway", 1, status(http.StatusBadGateway), nil, 200 * time.Millisecond, true},
+		{"gateway timeout", 2, status(http.StatusGatewayTimeout), nil, 400 * time.Millisecond, true},
+		{"attempts exhausted", 3, status(http.StatusServiceUnavailable), nil, 0, false},
+		{"retry after", 0, status(http.StatusServiceUnavailable, "Retry-After", "0"), nil, 0, true},
+		{"retry after too long", 0, status(http.StatusServiceUnavailable, "Retry-After", "120"), nil, 0, false},
+		{"timeout", 0, nil, context.DeadlineExceeded, 100 * time.Millisecond, true},
+		{"cancelled", 0, nil, context.Canceled, 0, false},
+		{"not a network error", 0, nil, errors.New("invalid token"), 0, false},
+		{"connection refused", 0, nil, &url.Error{Op: "Post", URL: "https://api", Err: &net.OpError{Op: "dial", Net: "tcp", Err: os.NewSyscallError("connect", syscall.ECONNREFUSED)}}, 100 * time.Millisecond, true},
+		{"connection reset", 0, nil, &url.Error{Op: "Post", URL: "https://api", Err: &net.OpError{Op: "read", Net: "tcp", Err: os.NewSyscallError("read", syscall.ECONNRESET)}}, 100 * time.Millisecond, true},
+		{"connection closed", 0, nil, &url.Error{Op: "Post", URL: "https://api", Err: io.EOF}, 100 * time.Millisecond, true},
+		{"tls failure", 0, nil, &url.Error{Op: "Post", URL: "https://api", Err: x509.UnknownAuthorityError{}}, 0, false},
+		{"unsupported scheme", 0, nil, &url.Error{Op: "Post", URL: "ftp://api", Err: errors.New("unsupported protocol scheme \"ftp\"")}, 0, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			delay, retry := policy.Retry(tt.attempt, tt.res, tt.err)
+			if delay != tt.delay || retry != tt.retry {
+				t.Fatalf("expecting (%v, %v) but got (%v, %v)", tt.delay, tt.retry, delay, retry)
+			}
+		})
+	}
+}
+
+func TestBackoffRetryPolicyJitter(t *testing.T) {
+	policy := client.NewDefaultRetryPolicy()
+	for i := 0; i < 100; i++ {
+		delay, retry := policy.Retry(1, &http.Response{StatusCode: http.StatusServiceUnavailable}, nil)
+		highest := 2 * client.DefaultBaseDelay
+		lowest := time.Duration(float64(highest) * (1 - client.DefaultJitter))
+		if !retry || delay < lowest || delay > highest {
+			t.Fatalf("expecting a delay between %v and %v but got %v", lowest, highest, delay)
+		}
+	}
+}
+
+func TestSafeRetryable(t *testing.T) {
+	dialErr := &net.OpError{Op: "dial", Net: "tcp", Err: errors.New("connection refused")}
+	readErr := &net.OpError{Op: "read", Net: "tcp", Err: errors.New("connection reset by peer")}
+
+	if !client.SafeRetryable(nil, dialErr) {
+		t.Error("expecting a failed dial to be retried")
+	}
+	if client.SafeRetryable(nil, readErr) || client.SafeRetryable(nil, context.DeadlineExceeded) {
+		t.Error("expecting errors after the request was sent not to be retried")
+	}
+	if !client.SafeRetryable(&http.Response{StatusCode: http.StatusTooManyRequests}, nil) {
+		t.Error("expecting a rate limited request to be retried")
+	}
+	if client.SafeRetryable(&http.Response{StatusCode: http.StatusGatewayTimeout}, nil) {
+		t.Error("expecting a gateway timeout not to be retried")
+	}
+}
+
+func TestApiRequestUsesOperationRetryPolicy(t *testing.T) {
+	var requests atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if requests.Add(1) < 3 {
+			w.WriteHeader(http.StatusBadGateway)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	endpoints := utils.NewEndpoints()
+	endpoints.SetBaseURL(server.URL)
+
+	policy := client.NewDefaultRetryPolicy()
+	policy.BaseDelay = time.Millisecond
+	c := client.NewCustomHttpClient(server.Client(), policy, nil)
+	c.SetEndpoints(endpoints)
+
+	safe := client.NewSafeRetryPolicy()
+	safe.BaseDelay = time.Millisecond
+	c.SetOperationRetryPolicy(common.B2COperation, safe)
+
+	res, err := c.ApiRequest(context.Background(), common.SANDBOX, common.B2COperation, "/b2c", http.MethodPost, nil, "")
+	if err != nil || res.StatusCode != http.StatusBadGateway || requests.Load() != 1 {
+		t.Fatalf("expecting the bad gateway not to be retried for B2C but got %v after %d requests (%v)", res.StatusCode, requests.Load(), err)
+	}
+	res.Body.Close()
+
+	res, err = c.ApiRequest(context.Background(), common.SANDBOX, common.AccountBalanceOperation, "/balance", http.MethodPost, nil, "")
+	if err != nil || res.StatusCode != http.StatusOK || requests.Load() != 3 {
+		t.Fatalf("expecting the bad gateway to be retried but got %v after %d requests (%v)", res.StatusCode, requests.Load(), err)
+	}
+	res.Body.Close()
+}
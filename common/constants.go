@@ -76,3 +76,30 @@ const (
     CancelledResponse ResponseType = "Cancelled"
 )
 
+
+// Operation identifies an M-Pesa API operation supported by the SDK. Operations are used to
+// configure endpoint paths and to label logs, traces and metrics.
+//
+// Predefined Operations:
+//   - TokenOperation: Generating an OAuth access token.
+//   - RegisterURLOperation: Registering C2B validation and confirmation URLs.
+//   - SimulateC2BOperation: Simulating a customer initiated C2B payment.
+//   - STKPushOperation: Initiating an STK push payment.
+//   - STKPushQueryOperation: Querying the status of an STK push payment.
+//   - B2COperation: Sending a B2C payment.
+//   - TransactionStatusOperation: Querying the status of a transaction.
+//   - AccountBalanceOperation: Querying the balance of a shortcode.
+//   - TransactionReversalOperation: Reversing a transaction.
+type Operation string
+
+const (
+    TokenOperation               Operation = "Token"
+    RegisterURLOperation         Operation = "RegisterURL"
+    SimulateC2BOperation         Operation = "SimulateC2B"
+    STKPushOperation             Operation = "STKPush"
+    STKPushQueryOperation        Operation = "STKPushQuery"
+    B2COperation                 Operation = "B2C"
+    TransactionStatusOperation   Operation = "TransactionStatus"
+    AccountBalanceOperation      Operation = "AccountBalance"
+    TransactionReversalOperation Operation = "TransactionReversal"
+)
@@ -1,5 +1,10 @@
 package common;
 
+import (
+    "encoding/json"
+    "errors"
+)
+
 // MpesaSuccessResponse represents a successful response from the M-Pesa API.
 //
 // Fields:
@@ -28,3 +33,49 @@ type MpesaErrorResponse struct {
     ErrorMessage string `json:"errorMessage"`
 }
 
+// ParseErrorResponse decodes the body of a failed M-Pesa API response. Errors are reported either
+// as `{"requestId", "errorCode", "errorMessage"}` or, for requests the API accepted but could not
+// process, as a response with a non-zero `ResponseCode`; both are returned as a MpesaErrorResponse.
+//
+// Returns:
+//   - The error response.
+//   - An error if the body holds neither form.
+func ParseErrorResponse(body []byte) (MpesaErrorResponse, error) {
+    var e struct {
+        MpesaErrorResponse
+        ResponseCode             string `json:"ResponseCode"`
+        ResponseDescription      string `json:"ResponseDescription"`
+        OriginatorConversationID string `json:"OriginatorConversationID"`
+        MerchantRequestID        string `json:"MerchantRequestID"`
+    }
+    if err := json.Unmarshal(body, &e); err != nil {
+        return MpesaErrorResponse{}, err
+    }
+
+    if e.ErrorCode == "" {
+        e.ErrorCode = e.ResponseCode
+        e.ErrorMessage = e.ResponseDescription
+    }
+    if e.RequestId == "" {
+        e.RequestId = e.OriginatorConversationID
+    }
+    if e.RequestId == "" {
+        e.RequestId = e.MerchantRequestID
+    }
+
+    if e.ErrorCode == "" {
+        return MpesaErrorResponse{}, errors.New("missing error code")
+    }
+    return e.MpesaErrorResponse, nil
+}
+
+// CallbackResponse is the acknowledgement body returned to M-Pesa when it posts to one of the
+// callback URLs (STK callback, C2B validation/confirmation, result and queue timeout URLs).
+//
+// Fields:
+//   - ResultCode: "0" to accept the notification, any other value to reject it.
+//   - ResultDesc: A human-readable description of the acknowledgement.
+type CallbackResponse struct {
+    ResultCode string `json:"ResultCode"`
+    ResultDesc string `json:"ResultDesc"`
+}
@@ -0,0 +1,158 @@
+// Package debug records the HTTP requests sent to the M-Pesa API and the responses received,
+// to find out what the API actually returned when a call fails.
+//
+// Recording is enabled by giving the client a Sink:
+//
+//	sink := debug.NewMemorySink(10)
+//	client, err := mpesasdk.New(key, secret, mpesasdk.WithDebugSink(sink))
+//	...
+//	if last, ok := sink.Last(); ok {
+//	    log.Printf("%v %v returned %v: %s", last.Method, last.URL, last.Status, last.ResponseBody)
+//	}
+//
+// Every attempt of a request is recorded, with its secrets and phone numbers masked by the
+// redactor of the client.
+package debug
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/coleYab/mpesasdk/common"
+)
+
+// Exchange is a recorded attempt of a request.
+//
+// Fields:
+//   - Operation: The operation of the request.
+//   - Attempt: The number of the attempt, starting at 1.
+//   - Method: The HTTP method of the request.
+//   - URL: The URL of the request.
+//   - RequestHeader: The headers of the request.
+//   - RequestBody: The body of the request.
+//   - Status: The HTTP status of the response, 0 if none was received.
+//   - ResponseHeader: The headers of the response.
+//   - ResponseBody: The body of the response.
+//   - Start: The time the request was sent.
+//   - Duration: The time until the response body was read.
+//   - Err: The error of the attempt, if any.
+type Exchange struct {
+	Operation      common.Operation
+	Attempt        uint
+	Method         string
+	URL            string
+	RequestHeader  http.Header
+	RequestBody    []byte
+	Status         int
+	ResponseHeader http.Header
+	ResponseBody   []byte
+	Start          time.Time
+	Duration       time.Duration
+	Err            error
+}
+
+// Sink receives the recorded exchanges. Record is called synchronously for every attempt, so
+// it should not block, and it may be called concurrently.
+type Sink interface {
+	Record(ctx context.Context, exchange Exchange)
+}
+
+// SinkFunc adapts a function to the Sink interface.
+type SinkFunc func(ctx context.Context, exchange Exchange)
+
+// Record implements Sink.
+func (f SinkFunc) Record(ctx context.Context, exchange Exchange) {
+	f(ctx, exchange)
+}
+
+// WriterSink is a Sink dumping the exchanges to an io.Writer in a human readable form.
+type WriterSink struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+// NewWriterSink creates a WriterSink dumping the exchanges to w, e.g. os.Stderr.
+func NewWriterSink(w io.Writer) *WriterSink {
+	return &WriterSink{w: w}
+}
+
+// Record implements Sink.
+func (s *WriterSink) Record(ctx context.Context, e Exchange) {
+	var b strings.Builder
+	fmt.Fprintf(&b, "--> %v %v (%v attempt %v)\n", e.Method, e.URL, e.Operation, e.Attempt)
+	writeHeader(&b, e.RequestHeader)
+	fmt.Fprintf(&b, "\n%s\n", e.RequestBody)
+
+	if e.Err != nil {
+		fmt.Fprintf(&b, "<-- error after %v: %v\n", e.Duration, e.Err)
+	}
+	if e.Status != 0 {
+		fmt.Fprintf(&b, "<-- %v %v (%v)\n", e.Status, http.StatusText(e.Status), e.Duration)
+		writeHeader(&b, e.ResponseHeader)
+		fmt.Fprintf(&b, "\n%s\n", e.ResponseBody)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	io.WriteString(s.w, b.String())
+}
+
+// writeHeader writes the headers sorted by name.
+func writeHeader(b *strings.Builder, header http.Header) {
+	keys := make([]string, 0, len(header))
+	for key := range header {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		for _, value := range header[key] {
+			fmt.Fprintf(b, "%v: %v\n", key, value)
+		}
+	}
+}
+
+// MemorySink is a Sink keeping the most recent exchanges in memory.
+type MemorySink struct {
+	mu        sync.Mutex
+	capacity  int
+	exchanges []Exchange
+}
+
+// NewMemorySink creates a MemorySink keeping the last capacity exchanges, at least one.
+func NewMemorySink(capacity int) *MemorySink {
+	return &MemorySink{capacity: max(capacity, 1)}
+}
+
+// Record implements Sink.
+func (s *MemorySink) Record(ctx context.Context, exchange Exchange) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(s.exchanges) == s.capacity {
+		s.exchanges = s.exchanges[1:]
+	}
+	s.exchanges = append(s.exchanges, exchange)
+}
+
+// Exchanges returns the kept exchanges, oldest first.
+func (s *MemorySink) Exchanges() []Exchange {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]Exchange(nil), s.exchanges...)
+}
+
+// Last returns the most recent exchange, false if none was recorded.
+func (s *MemorySink) Last() (Exchange, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(s.exchanges) == 0 {
+		return Exchange{}, false
+	}
+	return s.exchanges[len(s.exchanges)-1], true
+}
@@ -0,0 +1,61 @@
+package debug_test
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/coleYab/mpesasdk/common"
+	"github.com/coleYab/mpesasdk/debug"
+)
+
+func TestMemorySinkKeepsTheLastExchanges(t *testing.T) {
+	sink := debug.NewMemorySink(2)
+	if _, ok := sink.Last(); ok {
+		t.Fatal("expecting no exchange")
+	}
+
+	for attempt := uint(1); attempt <= 3; attempt++ {
+		sink.Record(context.Background(), debug.Exchange{Attempt: attempt})
+	}
+
+	exchanges := sink.Exchanges()
+	if len(exchanges) != 2 || exchanges[0].Attempt != 2 || exchanges[1].Attempt != 3 {
+		t.Fatalf("unexpected exchanges %+v", exchanges)
+	}
+	if last, ok := sink.Last(); !ok || last.Attempt != 3 {
+		t.Fatalf("unexpected last exchange %+v", last)
+	}
+}
+
+func TestWriterSinkDumpsTheExchange(t *testing.T) {
+	var out bytes.Buffer
+	debug.NewWriterSink(&out).Record(context.Background(), debug.Exchange{
+		Operation:      common.B2COperation,
+		Attempt:        1,
+		Method:         http.MethodPost,
+		URL:            "https://apisandbox.safaricom.et/mpesa/b2c/v2/paymentrequest",
+		RequestHeader:  http.Header{"Content-Type": {"application/json"}, "Authorization": {"Bearer [REDACTED]"}},
+		RequestBody:    []byte(`{"Amount":10}`),
+		Status:         http.StatusBadRequest,
+		ResponseHeader: http.Header{"Content-Type": {"application/json"}},
+		ResponseBody:   []byte(`{"errorCode":"400.002.02"}`),
+		Duration:       25 * time.Millisecond,
+	})
+
+	dump := out.String()
+	for _, want := range []string{
+		"--> POST https://apisandbox.safaricom.et/mpesa/b2c/v2/paymentrequest",
+		"Authorization: Bearer [REDACTED]\nContent-Type: application/json\n",
+		`{"Amount":10}`,
+		"<-- 400 Bad Request (25ms)",
+		`{"errorCode":"400.002.02"}`,
+	} {
+		if !strings.Contains(dump, want) {
+			t.Fatalf("expecting %q in the dump\n%v", want, dump)
+		}
+	}
+}
@@ -1,10 +1,69 @@
 // Package errors provides a structured way to handle and represent errors in the SDK.
 // It defines various error types to encapsulate different failure scenarios,
 // making it easier to debug and handle issues effectively.
+//
+// Every error returned by the SDK can be inspected with the standard library:
+//
+//	var sdkErr *errors.SDKError
+//	if stderrors.As(err, &sdkErr) {
+//	    log.Printf("request %v failed with HTTP %v: %s", sdkErr.RequestID(), sdkErr.HTTPStatus(), sdkErr.RawBody())
+//	}
+//	if stderrors.Is(err, errors.ErrRateLimited) {
+//	    // back off
+//	}
 package errors
 
 import (
+	"context"
+	stderrors "errors"
 	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/coleYab/mpesasdk/resultcodes"
+)
+
+// Kind classifies an SDKError. The predefined kinds are sentinel values usable with errors.Is.
+type Kind struct {
+	name      string
+	retryable bool
+}
+
+// Error implements the error interface for Kind.
+func (k *Kind) Error() string {
+	return k.name
+}
+
+// Retryable reports whether errors of this kind are transient, so that the failed request may
+// succeed if sent again.
+func (k *Kind) Retryable() bool {
+	return k.retryable
+}
+
+// Predefined kinds of errors.
+//
+//   - ErrNetwork: The request or its response could not be exchanged with the API.
+//   - ErrTimeout: The request timed out, the API may or may not have processed it.
+//   - ErrAuth: The credentials, access token or security credential were rejected.
+//   - ErrValidation: The request is invalid, as found by the SDK or the API.
+//   - ErrRateLimited: Too many requests were sent (HTTP 429).
+//   - ErrInsufficientFunds: The account does not hold enough funds for the transaction.
+//   - ErrNotFound: The requested resource or transaction does not exist.
+//   - ErrServer: The API failed to process the request (HTTP 5xx).
+//   - ErrProcessing: The SDK failed to prepare the request or to understand the response.
+//   - ErrEnvironment: The SDK is misconfigured for the environment.
+var (
+	ErrNetwork           = &Kind{name: "network error", retryable: true}
+	ErrTimeout           = &Kind{name: "timeout", retryable: true}
+	ErrAuth              = &Kind{name: "authentication failed"}
+	ErrValidation        = &Kind{name: "validation failed"}
+	ErrRateLimited       = &Kind{name: "rate limited", retryable: true}
+	ErrInsufficientFunds = &Kind{name: "insufficient funds"}
+	ErrNotFound          = &Kind{name: "not found"}
+	ErrServer            = &Kind{name: "server error", retryable: true}
+	ErrProcessing        = &Kind{name: "processing error"}
+	ErrEnvironment       = &Kind{name: "environment error"}
 )
 
 // SDKError represents a custom error type used throughout the SDK.
@@ -14,21 +73,102 @@ import (
 //   - RequestId: An optional field, typically used to store the originator conversation ID for failed requests.
 //   - code: A short, unique identifier for the type of error.
 //   - message: A detailed description of the error.
+//   - kind: The classification of the error, nil when unknown.
+//   - httpStatus: The HTTP status of the response the error was decoded from, 0 if none.
+//   - rawBody: The body of the response the error was decoded from.
+//   - cause: The underlying error, if any.
 type SDKError struct {
-	RequestId string
-	code      string
-	message   string
+	RequestId  string
+	code       string
+	message    string
+	kind       *Kind
+	httpStatus int
+	rawBody    []byte
+	cause      error
+}
+
+// Details holds the optional information of an SDKError created with NewDetailedError.
+//
+// Fields:
+//   - Kind: The classification of the error, derived from the code and HTTPStatus when nil.
+//   - HTTPStatus: The HTTP status of the response the error was decoded from.
+//   - RequestID: The request ID reported by the API.
+//   - RawBody: The body of the response the error was decoded from.
+//   - Cause: The underlying error.
+type Details struct {
+	Kind       *Kind
+	HTTPStatus int
+	RequestID  string
+	RawBody    []byte
+	Cause      error
 }
 
 // Error implements the error interface for SDKError.
 // It formats the error as a string containing the code and message.
 //
 // Returns:
-//   - A string representation of the error in the format: "<code>: <message>".
+//   - A string representation of the error in the format: "<code>: <message>",
+//     followed by the request ID when known.
 func (e *SDKError) Error() string {
+	if e.RequestId != "" {
+		return fmt.Sprintf("%v: %v (request %v)", e.code, e.message, e.RequestId)
+	}
 	return fmt.Sprintf("%v: %v", e.code, e.message)
 }
 
+// Code returns the error code, as reported by the API (e.g. "500.003.1001") or set by the SDK (e.g. "VALIDATION_ERROR").
+func (e *SDKError) Code() string {
+	return e.code
+}
+
+// Message returns the description of the error.
+func (e *SDKError) Message() string {
+	return e.message
+}
+
+// HTTPStatus returns the HTTP status of the response the error was decoded from, 0 if none.
+func (e *SDKError) HTTPStatus() int {
+	return e.httpStatus
+}
+
+// RequestID returns the request ID reported by the API, empty if unknown.
+func (e *SDKError) RequestID() string {
+	return e.RequestId
+}
+
+// RawBody returns the body of the response the error was decoded from, nil if none.
+func (e *SDKError) RawBody() []byte {
+	return e.rawBody
+}
+
+// Info returns the description of the error code from the resultcodes catalog.
+func (e *SDKError) Info() resultcodes.Info {
+	return resultcodes.Lookup(e.code)
+}
+
+// Kind returns the classification of the error, nil when unknown.
+func (e *SDKError) Kind() *Kind {
+	return e.kind
+}
+
+// Retryable reports whether the error is transient, so that the failed request may succeed if
+// sent again. Requests moving money should only be sent again after checking their status.
+func (e *SDKError) Retryable() bool {
+	return e.kind != nil && e.kind.retryable
+}
+
+// Unwrap returns the kind and the cause of the error, so that errors.Is matches both.
+func (e *SDKError) Unwrap() []error {
+	var errs []error
+	if e.kind != nil {
+		errs = append(errs, e.kind)
+	}
+	if e.cause != nil {
+		errs = append(errs, e.cause)
+	}
+	return errs
+}
+
 // NewSDKError creates a new instance of SDKError with the given code and message.
 //
 // Parameters:
@@ -38,10 +178,166 @@ func (e *SDKError) Error() string {
 // Returns:
 //   - A pointer to the newly created SDKError.
 func NewSDKError(code, message string) *SDKError {
+	return NewDetailedError(code, message, Details{})
+}
+
+// NewDetailedError creates a new instance of SDKError with the given code, message and details.
+//
+// Parameters:
+//   - code: A short, unique identifier for the error type.
+//   - message: A descriptive message explaining the error.
+//   - details: The optional information of the error.
+//
+// Returns:
+//   - A pointer to the newly created SDKError.
+func NewDetailedError(code, message string, details Details) *SDKError {
+	kind := details.Kind
+	if kind == nil {
+		kind = classify(code, message, details.HTTPStatus)
+	}
+
 	return &SDKError{
-		code:    code,
-		message: message,
+		RequestId:  details.RequestID,
+		code:       code,
+		message:    message,
+		kind:       kind,
+		httpStatus: details.HTTPStatus,
+		rawBody:    details.RawBody,
+		cause:      details.Cause,
+	}
+}
+
+// NewAPIError creates the error of a request the M-Pesa API answered with an error body.
+//
+// Parameters:
+//   - status: The HTTP status of the response.
+//   - requestID: The request ID reported by the API.
+//   - code: The error code reported by the API.
+//   - message: The error message reported by the API, the description of code when empty.
+//   - body: The body of the response.
+//
+// Returns:
+//   - A pointer to the newly created SDKError, classified from its code and status.
+func NewAPIError(status int, requestID, code, message string, body []byte) *SDKError {
+	if message == "" {
+		message = resultcodes.Lookup(code).Description
+	}
+	return NewDetailedError(code, message, Details{HTTPStatus: status, RequestID: requestID, RawBody: body})
+}
+
+// NewUnexpectedResponseError creates the error of a response that could not be decoded, such as
+// the HTML page of a gateway. It is classified from the HTTP status of the response.
+func NewUnexpectedResponseError(status int, body []byte, cause error) *SDKError {
+	message := fmt.Sprintf("unexpected response with status %v", status)
+	if cause != nil {
+		message += ": " + cause.Error()
+	}
+
+	kind := kindForStatus(status)
+	if kind == nil {
+		kind = ErrProcessing
+	}
+	return NewDetailedError("UNEXPECTED_RESPONSE", message, Details{Kind: kind, HTTPStatus: status, RawBody: body, Cause: cause})
+}
+
+// NewTransportError creates the error of a request that could not be exchanged with the API.
+// Errors of the SDK are returned as is.
+func NewTransportError(err error) *SDKError {
+	var sdkErr *SDKError
+	if stderrors.As(err, &sdkErr) {
+		return sdkErr
+	}
+
+	var timeout interface{ Timeout() bool }
+	if stderrors.Is(err, context.DeadlineExceeded) || stderrors.As(err, &timeout) && timeout.Timeout() {
+		return NewDetailedError("TIMEOUT_ERROR", err.Error(), Details{Kind: ErrTimeout, Cause: err})
+	}
+	return NewDetailedError("NETWORK_ERROR", err.Error(), Details{Kind: ErrNetwork, Cause: err})
+}
+
+// NewResponseReadError creates the error of a response whose body could not be read entirely,
+// e.g. because the connection was closed. The error keeps the status and the part of the body
+// that was read.
+func NewResponseReadError(status int, body []byte, err error) *SDKError {
+	readErr := *NewTransportError(err)
+	readErr.httpStatus = status
+	readErr.rawBody = body
+	return &readErr
+}
+
+// codeKinds classifies the codes of the SDK and the API codes whose kind does not follow from
+// their category in the resultcodes catalog.
+var codeKinds = map[string]*Kind{
+	"NETWORK_ERROR":         ErrNetwork,
+	"AUTH_ERROR":            ErrAuth,
+	"VALIDATION_ERROR":      ErrValidation,
+	"PROCESSING_ERROR":      ErrProcessing,
+	"ENVIRONMENT_ERROR":     ErrEnvironment,
+	"TIMEOUT_ERROR":         ErrTimeout,
+	"INTERNAL_SERVER_ERROR": ErrServer,
+	"SERVICE_UNAVAILABLE":   ErrServer,
+	"BAD_REQUEST_ERROR":     ErrValidation,
+	"UNAUTHORIZED_ERROR":    ErrAuth,
+	"FORBIDDEN_ERROR":       ErrAuth,
+	"NOT_FOUND_ERROR":       ErrNotFound,
+
+	string(resultcodes.ServiceDenied):               ErrAuth,
+	string(resultcodes.InvalidAccessToken):          ErrAuth,
+	string(resultcodes.InvalidAuthenticationHeader): ErrAuth,
+	string(resultcodes.InvalidAuthentication):       ErrAuth,
+	string(resultcodes.InvalidGrantType):            ErrAuth,
+	string(resultcodes.SecurityCredentialLocked):    ErrAuth,
+	string(resultcodes.InsufficientBalance):         ErrInsufficientFunds,
+	string(resultcodes.ExceedsMinimumBalance):       ErrInsufficientFunds,
+	string(resultcodes.ResourceNotFound):            ErrNotFound,
+	string(resultcodes.SpikeArrestViolation):        ErrRateLimited,
+	string(resultcodes.QuotaViolation):              ErrRateLimited,
+	string(resultcodes.UserUnreachable):             ErrTimeout,
+}
+
+// classify returns the kind of an error from its code, message and HTTP status.
+func classify(code, message string, status int) *Kind {
+	if kind, ok := codeKinds[code]; ok {
+		return kind
+	}
+
+	if strings.Contains(strings.ToLower(message), "insufficient") {
+		return ErrInsufficientFunds
+	}
+
+	switch resultcodes.Lookup(code).Category {
+	case resultcodes.CategoryTransient:
+		return ErrServer
+	case resultcodes.CategoryMerchantConfig:
+		return ErrValidation
+	}
+
+	if status == 0 {
+		// API error codes start with the HTTP status of the error, e.g. "400.002.02"
+		if prefix, _, ok := strings.Cut(code, "."); ok {
+			status, _ = strconv.Atoi(prefix)
+		}
+	}
+	return kindForStatus(status)
+}
+
+// kindForStatus returns the kind of an error response from its HTTP status, nil if unknown.
+func kindForStatus(status int) *Kind {
+	switch {
+	case status == http.StatusUnauthorized || status == http.StatusForbidden:
+		return ErrAuth
+	case status == http.StatusTooManyRequests:
+		return ErrRateLimited
+	case status == http.StatusNotFound:
+		return ErrNotFound
+	case status == http.StatusRequestTimeout || status == http.StatusGatewayTimeout:
+		return ErrTimeout
+	case status >= 500 && status < 600:
+		return ErrServer
+	case status >= 400 && status < 500:
+		return ErrValidation
 	}
+	return nil
 }
 
 // Predefined error generators for common scenarios. Each generator returns an *SDKError
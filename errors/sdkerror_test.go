@@ -0,0 +1,86 @@
+package errors_test
+
+import (
+	"context"
+	stderrors "errors"
+	"fmt"
+	"io"
+	"net/http"
+	"testing"
+
+	sdkError "github.com/coleYab/mpesasdk/errors"
+)
+
+func TestErrorsAreClassified(t *testing.T) {
+	tests := []struct {
+		name      string
+		err       error
+		kind      *sdkError.Kind
+		retryable bool
+	}{
+		{"validation helper", sdkError.ValidationError("invalid"), sdkError.ErrValidation, false},
+		{"authentication helper", sdkError.AuthenticationError("denied"), sdkError.ErrAuth, false},
+		{"invalid access token", sdkError.NewAPIError(http.StatusNotFound, "req-1", "404.001.03", "Invalid Access Token", nil), sdkError.ErrAuth, false},
+		{"bad request", sdkError.NewAPIError(http.StatusBadRequest, "req-1", "400.002.02", "Bad Request - Invalid Amount", nil), sdkError.ErrValidation, false},
+		{"code without status", sdkError.NewSDKError("500.003.1001", "Internal Server Error"), sdkError.ErrServer, true},
+		{"rate limited", sdkError.NewAPIError(http.StatusTooManyRequests, "", "429.001.01", "Too many requests", nil), sdkError.ErrRateLimited, true},
+		{"insufficient funds", sdkError.NewAPIError(http.StatusOK, "", "1", "The balance is insufficient for the transaction", nil), sdkError.ErrInsufficientFunds, false},
+		{"gateway page", sdkError.NewUnexpectedResponseError(http.StatusBadGateway, []byte("<html>"), nil), sdkError.ErrServer, true},
+		{"timeout", sdkError.NewTransportError(fmt.Errorf("post: %w", context.DeadlineExceeded)), sdkError.ErrTimeout, true},
+		{"network", sdkError.NewTransportError(stderrors.New("connection reset by peer")), sdkError.ErrNetwork, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if !stderrors.Is(tt.err, tt.kind) {
+				t.Fatalf("expecting %v to be %v", tt.err, tt.kind)
+			}
+
+			var sdkErr *sdkError.SDKError
+			if !stderrors.As(tt.err, &sdkErr) || sdkErr.Retryable() != tt.retryable {
+				t.Fatalf("expecting %v to be retryable: %v", tt.err, tt.retryable)
+			}
+		})
+	}
+}
+
+func TestAPIErrorDetails(t *testing.T) {
+	body := []byte(`{"requestId":"req-1","errorCode":"400.002.02","errorMessage":"Bad Request"}`)
+	err := fmt.Errorf("b2c: %w", sdkError.NewAPIError(http.StatusBadRequest, "req-1", "400.002.02", "Bad Request", body))
+
+	var sdkErr *sdkError.SDKError
+	if !stderrors.As(err, &sdkErr) {
+		t.Fatalf("expecting an SDKError but got %T", err)
+	}
+
+	if sdkErr.Code() != "400.002.02" || sdkErr.Message() != "Bad Request" || sdkErr.HTTPStatus() != http.StatusBadRequest ||
+		sdkErr.RequestID() != "req-1" || string(sdkErr.RawBody()) != string(body) {
+		t.Fatalf("unexpected details %q %q %v %q %q", sdkErr.Code(), sdkErr.Message(), sdkErr.HTTPStatus(), sdkErr.RequestID(), sdkErr.RawBody())
+	}
+
+	if sdkErr.Error() != "400.002.02: Bad Request (request req-1)" {
+		t.Fatalf("unexpected message %q", sdkErr.Error())
+	}
+}
+
+func TestTransportErrorKeepsCause(t *testing.T) {
+	err := sdkError.NewTransportError(fmt.Errorf("post: %w", context.Canceled))
+	if !stderrors.Is(err, context.Canceled) {
+		t.Fatalf("expecting the cause to be matched by errors.Is")
+	}
+
+	sdkErr := sdkError.ValidationError("invalid")
+	if sdkError.NewTransportError(sdkErr) != sdkErr {
+		t.Fatalf("expecting SDK errors to be returned as is")
+	}
+}
+
+func TestResponseReadErrorKeepsPartialBody(t *testing.T) {
+	err := sdkError.NewResponseReadError(http.StatusOK, []byte(`{"ResponseCode":`), io.ErrUnexpectedEOF)
+	if !stderrors.Is(err, sdkError.ErrNetwork) || !stderrors.Is(err, io.ErrUnexpectedEOF) {
+		t.Fatalf("expecting a network error caused by the read failure but got %v", err)
+	}
+	if err.HTTPStatus() != http.StatusOK || string(err.RawBody()) != `{"ResponseCode":` {
+		t.Fatalf("unexpected details %v %q", err.HTTPStatus(), err.RawBody())
+	}
+}
@@ -0,0 +1,111 @@
+// Package idempotency prevents non-idempotent requests, such as B2C payments and transaction
+// reversals, from being submitted twice with the same OriginatorConversationID.
+//
+// Before a submission its key is reserved in a Store. A successful submission saves the
+// acknowledgement of the API, which is returned to any later submission with the same key
+// instead of sending it again. A submission that certainly did not reach the API, or that the
+// API rejected with a 4xx response, releases its reservation so that it can be retried, while a
+// submission with an unknown outcome (a timeout or a 5xx response of the API or a gateway) keeps
+// it until it expires: the transaction status has to be checked before submitting again.
+package idempotency
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	sdkError "github.com/coleYab/mpesasdk/errors"
+)
+
+// ErrInProgress is returned for a submission whose key is reserved by another submission that
+// is still in progress or whose outcome is unknown.
+var ErrInProgress = sdkError.CustomError("DUPLICATE_SUBMISSION", "a submission with the same OriginatorConversationID is in progress or has an unknown outcome")
+
+// DefaultReservationTTL is how long a reservation is kept by default when its submission has an
+// unknown outcome, long enough for the result of the submission to arrive.
+const DefaultReservationTTL = 15 * time.Minute
+
+// Store keeps the reservations and acknowledgements of submissions. A store shared between
+// processes (e.g. backed by Redis with SET NX) deduplicates submissions across replicas.
+type Store interface {
+	// Load returns the acknowledgement saved for key, false when there is none.
+	Load(ctx context.Context, key string) ([]byte, bool, error)
+
+	// Reserve claims key for a submission. It returns false if key is already reserved
+	// or has an acknowledgement. The reservation expires after ttl unless an acknowledgement
+	// is saved, so that a submission whose outcome stays unknown can be submitted again.
+	Reserve(ctx context.Context, key string, ttl time.Duration) (bool, error)
+
+	// Save stores the acknowledgement of the submission holding the reservation of key.
+	Save(ctx context.Context, key string, ack []byte) error
+
+	// Release drops the reservation of key so that the submission can be retried.
+	Release(ctx context.Context, key string) error
+}
+
+// MemoryStore is a Store deduplicating the submissions of a single process.
+type MemoryStore struct {
+	mu      sync.Mutex
+	entries map[string]entry
+	pruned  time.Time
+}
+
+// entry is a reservation, or the acknowledgement of a submission once saved.
+type entry struct {
+	ack       []byte    // nil while reserved
+	expiresAt time.Time // expiry of the reservation
+}
+
+// NewMemoryStore creates an empty MemoryStore.
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{entries: map[string]entry{}, pruned: time.Now()}
+}
+
+// Load implements Store.
+func (s *MemoryStore) Load(ctx context.Context, key string) ([]byte, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	ack := s.entries[key].ack
+	return ack, ack != nil, nil
+}
+
+// Reserve implements Store.
+func (s *MemoryStore) Reserve(ctx context.Context, key string, ttl time.Duration) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := time.Now()
+	if e, ok := s.entries[key]; ok && (e.ack != nil || now.Before(e.expiresAt)) {
+		return false, nil
+	}
+
+	// Drop the expired reservations, at most once a minute
+	if now.Sub(s.pruned) >= time.Minute {
+		s.pruned = now
+		for k, e := range s.entries {
+			if e.ack == nil && !now.Before(e.expiresAt) {
+				delete(s.entries, k)
+			}
+		}
+	}
+	s.entries[key] = entry{expiresAt: now.Add(ttl)}
+	return true, nil
+}
+
+// Save implements Store.
+func (s *MemoryStore) Save(ctx context.Context, key string, ack []byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if ack == nil {
+		ack = []byte{}
+	}
+	s.entries[key] = entry{ack: ack}
+	return nil
+}
+
+// Release implements Store.
+func (s *MemoryStore) Release(ctx context.Context, key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.entries, key)
+	return nil
+}
@@ -0,0 +1,147 @@
+// Package metrics defines the Metrics interface the SDK reports the stages of its calls to, so
+// that request counts, latencies, error rates, retries, token refreshes and callback outcomes
+// can be exported without wrapping every method of the client.
+//
+// A Metrics is given to the client with mpesasdk.WithMetrics. The prometheus sub-package
+// implements it as a Prometheus collector:
+//
+//	collector := prometheus.NewCollector()
+//	registry.MustRegister(collector)
+//	client, err := mpesasdk.New(key, secret, mpesasdk.WithMetrics(collector))
+//
+// Callbacks are served by handlers created without a client, wrap them with CallbackHandler to
+// count their outcomes.
+package metrics
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/coleYab/mpesasdk/common"
+	sdkError "github.com/coleYab/mpesasdk/errors"
+)
+
+// Callback identifies the kind of a callback posted by M-Pesa.
+type Callback string
+
+// Predefined callbacks, matching the handlers of the SDK.
+const (
+	STKCallback          Callback = "STKPush"
+	ResultCallback       Callback = "Result"
+	QueueTimeoutCallback Callback = "QueueTimeout"
+	ValidationCallback   Callback = "C2BValidation"
+	ConfirmationCallback Callback = "C2BConfirmation"
+)
+
+// Outcome is the way a callback was answered.
+type Outcome string
+
+// Predefined outcomes of callbacks.
+const (
+	Accepted Outcome = "accepted" // The callback was acknowledged.
+	Rejected Outcome = "rejected" // The callback function failed.
+	Invalid  Outcome = "invalid"  // The callback could not be decoded.
+)
+
+// Codes reported for the calls that did not fail with an SDKError.
+const (
+	CodeOK       = "OK"
+	CodeCanceled = "CANCELED"
+	CodeUnknown  = "UNKNOWN"
+)
+
+// Metrics receives the stages of the calls of the client. Its methods are called synchronously
+// and concurrently, so they should be cheap and safe for concurrent use.
+type Metrics interface {
+	// RequestStarted is called when a call starts, before the request is validated.
+	RequestStarted(op common.Operation)
+
+	// RequestFinished is called once per started call, with its duration including retries and
+	// its error code, see Code.
+	RequestFinished(op common.Operation, duration time.Duration, code string)
+
+	// RetryScheduled is called before every retry of a call, attempt being the number of the
+	// failed attempt, starting at 1.
+	RetryScheduled(op common.Operation, attempt uint)
+
+	// TokenRefreshed is called for every token requested from the API or the token store, with
+	// the duration of the request and its error code.
+	TokenRefreshed(duration time.Duration, code string)
+
+	// CallbackHandled is called for every callback served by a handler wrapped with CallbackHandler.
+	CallbackHandled(callback Callback, outcome Outcome, duration time.Duration)
+}
+
+// Code returns the code reported for the error of a call: CodeOK without error, the code of an
+// SDKError (e.g. "500.003.1001" or "VALIDATION_ERROR"), CodeCanceled for a cancelled context,
+// "TIMEOUT_ERROR" for an expired one, or CodeUnknown.
+func Code(err error) string {
+	var sdkErr *sdkError.SDKError
+	switch {
+	case err == nil:
+		return CodeOK
+	case errors.As(err, &sdkErr):
+		return sdkErr.Code()
+	case errors.Is(err, context.Canceled):
+		return CodeCanceled
+	case errors.Is(err, context.DeadlineExceeded):
+		return "TIMEOUT_ERROR"
+	}
+	return CodeUnknown
+}
+
+// Nop is a Metrics discarding everything, the default of the client.
+type Nop struct{}
+
+// RequestStarted implements Metrics.
+func (Nop) RequestStarted(common.Operation) {}
+
+// RequestFinished implements Metrics.
+func (Nop) RequestFinished(common.Operation, time.Duration, string) {}
+
+// RetryScheduled implements Metrics.
+func (Nop) RetryScheduled(common.Operation, uint) {}
+
+// TokenRefreshed implements Metrics.
+func (Nop) TokenRefreshed(time.Duration, string) {}
+
+// CallbackHandled implements Metrics.
+func (Nop) CallbackHandled(Callback, Outcome, time.Duration) {}
+
+// CallbackHandler wraps a callback handler, such as the one of c2b.NewSTKCallbackHandler, to
+// report the outcome of every callback to m from the status of its acknowledgement: Accepted
+// for 2xx, Invalid for 4xx and Rejected otherwise.
+//
+// Example:
+//
+//	http.Handle("/mpesa/stk", metrics.CallbackHandler(collector, metrics.STKCallback, c2b.NewSTKCallbackHandler(fn)))
+func CallbackHandler(m Metrics, callback Callback, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		recorder := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+		next.ServeHTTP(recorder, r)
+
+		outcome := Rejected
+		switch {
+		case recorder.status < 300:
+			outcome = Accepted
+		case recorder.status < 500:
+			outcome = Invalid
+		}
+		m.CallbackHandled(callback, outcome, time.Since(start))
+	})
+}
+
+// statusRecorder records the status written by a handler.
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+// WriteHeader implements http.ResponseWriter.
+func (r *statusRecorder) WriteHeader(status int) {
+	r.status = status
+	r.ResponseWriter.WriteHeader(status)
+}
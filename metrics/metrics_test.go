@@ -0,0 +1,57 @@
+package metrics_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	sdkError "github.com/coleYab/mpesasdk/errors"
+	"github.com/coleYab/mpesasdk/metrics"
+)
+
+func TestCode(t *testing.T) {
+	tests := []struct {
+		err  error
+		want string
+	}{
+		{nil, metrics.CodeOK},
+		{fmt.Errorf("b2c: %w", sdkError.NewAPIError(http.StatusInternalServerError, "req-1", "500.003.1001", "Internal Server Error", nil)), "500.003.1001"},
+		{context.Canceled, metrics.CodeCanceled},
+		{errors.New("boom"), metrics.CodeUnknown},
+	}
+
+	for _, tt := range tests {
+		if got := metrics.Code(tt.err); got != tt.want {
+			t.Errorf("expecting code %v for %v but got %v", tt.want, tt.err, got)
+		}
+	}
+}
+
+// callbackRecorder records the outcomes of the callbacks.
+type callbackRecorder struct {
+	metrics.Nop
+	outcomes []metrics.Outcome
+}
+
+func (r *callbackRecorder) CallbackHandled(callback metrics.Callback, outcome metrics.Outcome, duration time.Duration) {
+	r.outcomes = append(r.outcomes, outcome)
+}
+
+func TestCallbackHandlerReportsOutcomes(t *testing.T) {
+	recorder := &callbackRecorder{}
+	for _, status := range []int{http.StatusOK, http.StatusBadRequest, http.StatusInternalServerError} {
+		handler := metrics.CallbackHandler(recorder, metrics.STKCallback, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(status)
+		}))
+		handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodPost, "/stk", nil))
+	}
+
+	want := []metrics.Outcome{metrics.Accepted, metrics.Invalid, metrics.Rejected}
+	if len(recorder.outcomes) != len(want) || recorder.outcomes[0] != want[0] || recorder.outcomes[1] != want[1] || recorder.outcomes[2] != want[2] {
+		t.Fatalf("expecting outcomes %v but got %v", want, recorder.outcomes)
+	}
+}
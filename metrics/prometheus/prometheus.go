@@ -0,0 +1,156 @@
+// Package prometheus implements metrics.Metrics as a Prometheus collector.
+//
+// The collector exports, under the "mpesa" namespace by default:
+//   - mpesa_requests_total{operation, code}: The calls by error code, "OK" for successful ones.
+//   - mpesa_request_duration_seconds{operation}: The duration of the calls, including retries.
+//   - mpesa_requests_in_flight{operation}: The calls in progress.
+//   - mpesa_retries_total{operation}: The retried attempts.
+//   - mpesa_token_refreshes_total{code}: The token requests by error code.
+//   - mpesa_token_refresh_duration_seconds: The duration of the token requests.
+//   - mpesa_callbacks_total{callback, outcome}: The callbacks by outcome.
+//   - mpesa_callback_duration_seconds{callback}: The time taken to answer the callbacks.
+//
+// Example:
+//
+//	collector := prometheus.NewCollector()
+//	promclient.MustRegister(collector)
+//	client, err := mpesasdk.New(key, secret, mpesasdk.WithMetrics(collector))
+package prometheus
+
+import (
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/coleYab/mpesasdk/common"
+	"github.com/coleYab/mpesasdk/metrics"
+)
+
+// Collector is a Prometheus collector implementing metrics.Metrics.
+type Collector struct {
+	requests         *prometheus.CounterVec
+	requestDuration  *prometheus.HistogramVec
+	inFlight         *prometheus.GaugeVec
+	retries          *prometheus.CounterVec
+	tokenRefreshes   *prometheus.CounterVec
+	tokenDuration    prometheus.Histogram
+	callbacks        *prometheus.CounterVec
+	callbackDuration *prometheus.HistogramVec
+}
+
+var _ metrics.Metrics = (*Collector)(nil)
+
+// Option configures a Collector created with NewCollector.
+type Option func(*options)
+
+// options holds the settings collected from the options passed to NewCollector.
+type options struct {
+	namespace   string
+	constLabels prometheus.Labels
+	buckets     []float64
+}
+
+// WithNamespace sets the namespace of the metrics ("mpesa" by default).
+func WithNamespace(namespace string) Option {
+	return func(o *options) {
+		o.namespace = namespace
+	}
+}
+
+// WithConstLabels adds labels with fixed values to every metric, e.g. the name of the shortcode.
+func WithConstLabels(labels prometheus.Labels) Option {
+	return func(o *options) {
+		o.constLabels = labels
+	}
+}
+
+// WithBuckets sets the buckets of the duration histograms, in seconds.
+func WithBuckets(buckets []float64) Option {
+	return func(o *options) {
+		o.buckets = buckets
+	}
+}
+
+// NewCollector creates a Collector, to be registered with a prometheus.Registerer.
+func NewCollector(opts ...Option) *Collector {
+	o := &options{
+		namespace: "mpesa",
+		buckets:   []float64{.05, .1, .25, .5, 1, 2.5, 5, 10, 30},
+	}
+	for _, opt := range opts {
+		opt(o)
+	}
+
+	counter := func(name, help string, labels ...string) *prometheus.CounterVec {
+		return prometheus.NewCounterVec(prometheus.CounterOpts{Namespace: o.namespace, Name: name, Help: help, ConstLabels: o.constLabels}, labels)
+	}
+	histogram := func(name, help string, labels ...string) *prometheus.HistogramVec {
+		return prometheus.NewHistogramVec(prometheus.HistogramOpts{Namespace: o.namespace, Name: name, Help: help, ConstLabels: o.constLabels, Buckets: o.buckets}, labels)
+	}
+
+	return &Collector{
+		requests:        counter("requests_total", "Calls to the M-Pesa API by operation and error code.", "operation", "code"),
+		requestDuration: histogram("request_duration_seconds", "Duration of the calls to the M-Pesa API, including retries.", "operation"),
+		inFlight: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: o.namespace, Name: "requests_in_flight", Help: "Calls to the M-Pesa API in progress.", ConstLabels: o.constLabels,
+		}, []string{"operation"}),
+		retries:        counter("retries_total", "Retried attempts of calls to the M-Pesa API.", "operation"),
+		tokenRefreshes: counter("token_refreshes_total", "Token requests by error code.", "code"),
+		tokenDuration: prometheus.NewHistogram(prometheus.HistogramOpts{
+			Namespace: o.namespace, Name: "token_refresh_duration_seconds", Help: "Duration of the token requests.", ConstLabels: o.constLabels, Buckets: o.buckets,
+		}),
+		callbacks:        counter("callbacks_total", "Callbacks received from M-Pesa by outcome.", "callback", "outcome"),
+		callbackDuration: histogram("callback_duration_seconds", "Time taken to answer the callbacks of M-Pesa.", "callback"),
+	}
+}
+
+// collectors returns the metrics of the collector.
+func (c *Collector) collectors() []prometheus.Collector {
+	return []prometheus.Collector{
+		c.requests, c.requestDuration, c.inFlight, c.retries,
+		c.tokenRefreshes, c.tokenDuration, c.callbacks, c.callbackDuration,
+	}
+}
+
+// Describe implements prometheus.Collector.
+func (c *Collector) Describe(ch chan<- *prometheus.Desc) {
+	for _, collector := range c.collectors() {
+		collector.Describe(ch)
+	}
+}
+
+// Collect implements prometheus.Collector.
+func (c *Collector) Collect(ch chan<- prometheus.Metric) {
+	for _, collector := range c.collectors() {
+		collector.Collect(ch)
+	}
+}
+
+// RequestStarted implements metrics.Metrics.
+func (c *Collector) RequestStarted(op common.Operation) {
+	c.inFlight.WithLabelValues(string(op)).Inc()
+}
+
+// RequestFinished implements metrics.Metrics.
+func (c *Collector) RequestFinished(op common.Operation, duration time.Duration, code string) {
+	c.inFlight.WithLabelValues(string(op)).Dec()
+	c.requests.WithLabelValues(string(op), code).Inc()
+	c.requestDuration.WithLabelValues(string(op)).Observe(duration.Seconds())
+}
+
+// RetryScheduled implements metrics.Metrics.
+func (c *Collector) RetryScheduled(op common.Operation, attempt uint) {
+	c.retries.WithLabelValues(string(op)).Inc()
+}
+
+// TokenRefreshed implements metrics.Metrics.
+func (c *Collector) TokenRefreshed(duration time.Duration, code string) {
+	c.tokenRefreshes.WithLabelValues(code).Inc()
+	c.tokenDuration.Observe(duration.Seconds())
+}
+
+// CallbackHandled implements metrics.Metrics.
+func (c *Collector) CallbackHandled(callback metrics.Callback, outcome metrics.Outcome, duration time.Duration) {
+	c.callbacks.WithLabelValues(string(callback), string(outcome)).Inc()
+	c.callbackDuration.WithLabelValues(string(callback)).Observe(duration.Seconds())
+}
@@ -0,0 +1,75 @@
+package prometheus_test
+
+import (
+	"testing"
+	"time"
+
+	promclient "github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+
+	"github.com/coleYab/mpesasdk/common"
+	"github.com/coleYab/mpesasdk/metrics"
+	"github.com/coleYab/mpesasdk/metrics/prometheus"
+)
+
+// value returns the value of the counter or gauge name with the given labels, -1 if not found.
+func value(families []*dto.MetricFamily, name string, labels map[string]string) float64 {
+	for _, family := range families {
+		if family.GetName() != name {
+			continue
+		}
+	metric:
+		for _, m := range family.GetMetric() {
+			for _, label := range m.GetLabel() {
+				if labels[label.GetName()] != label.GetValue() {
+					continue metric
+				}
+			}
+			if m.GetCounter() != nil {
+				return m.GetCounter().GetValue()
+			}
+			return m.GetGauge().GetValue()
+		}
+	}
+	return -1
+}
+
+func TestCollectorExportsTheStagesOfTheCalls(t *testing.T) {
+	collector := prometheus.NewCollector()
+	registry := promclient.NewRegistry()
+	registry.MustRegister(collector)
+
+	collector.RequestStarted(common.B2COperation)
+	collector.RetryScheduled(common.B2COperation, 1)
+	collector.RequestFinished(common.B2COperation, 300*time.Millisecond, "500.003.1001")
+	collector.RequestStarted(common.B2COperation)
+	collector.RequestFinished(common.B2COperation, 100*time.Millisecond, metrics.CodeOK)
+	collector.TokenRefreshed(50*time.Millisecond, metrics.CodeOK)
+	collector.CallbackHandled(metrics.STKCallback, metrics.Accepted, time.Millisecond)
+
+	families, err := registry.Gather()
+	if err != nil {
+		t.Fatalf("failed to gather the metrics: %v", err)
+	}
+	if len(families) != 8 {
+		t.Fatalf("expecting 8 metric families but got %v", len(families))
+	}
+
+	b2c := string(common.B2COperation)
+	for _, tt := range []struct {
+		name   string
+		labels map[string]string
+		want   float64
+	}{
+		{"mpesa_requests_total", map[string]string{"operation": b2c, "code": "500.003.1001"}, 1},
+		{"mpesa_requests_total", map[string]string{"operation": b2c, "code": metrics.CodeOK}, 1},
+		{"mpesa_requests_in_flight", map[string]string{"operation": b2c}, 0},
+		{"mpesa_retries_total", map[string]string{"operation": b2c}, 1},
+		{"mpesa_token_refreshes_total", map[string]string{"code": metrics.CodeOK}, 1},
+		{"mpesa_callbacks_total", map[string]string{"callback": string(metrics.STKCallback), "outcome": string(metrics.Accepted)}, 1},
+	} {
+		if got := value(families, tt.name, tt.labels); got != tt.want {
+			t.Errorf("%v%v: expecting %v but got %v", tt.name, tt.labels, tt.want, got)
+		}
+	}
+}
@@ -10,33 +10,220 @@
 package mpesasdk
 
 import (
+	"context"
+	"encoding/json"
 	"errors"
 	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
 	"time"
 
+	"github.com/google/uuid"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+
 	"github.com/coleYab/mpesasdk/account"
 	"github.com/coleYab/mpesasdk/auth"
 	"github.com/coleYab/mpesasdk/b2c"
 	"github.com/coleYab/mpesasdk/c2b"
 	"github.com/coleYab/mpesasdk/client"
 	"github.com/coleYab/mpesasdk/common"
+	sdkError "github.com/coleYab/mpesasdk/errors"
+	"github.com/coleYab/mpesasdk/idempotency"
+	"github.com/coleYab/mpesasdk/metrics"
+	"github.com/coleYab/mpesasdk/msisdn"
+	"github.com/coleYab/mpesasdk/redact"
+	"github.com/coleYab/mpesasdk/security"
 	"github.com/coleYab/mpesasdk/service"
+	"github.com/coleYab/mpesasdk/tracing"
+	"github.com/coleYab/mpesasdk/tracker"
 	"github.com/coleYab/mpesasdk/transaction"
+	"github.com/coleYab/mpesasdk/utils"
 )
 
 // MpesaClient is the main client for interacting with the M-Pesa API.
 // It encapsulates the necessary configuration, such as authentication credentials,
 // environment (sandbox or production), HTTP client, and logging capabilities.
 type MpesaClient struct {
-    consumerKey    string
-    consumerSecret string
-    env            common.Enviroment
-    client         *client.HttpClient
-    logger         *service.Logger
+    consumerKey        string
+    consumerSecret     string
+    env                common.Enviroment
+    auth               *auth.AuthorizationToken
+    client             *client.HttpClient
+    endpoints          *utils.Endpoints
+    logger             service.StructuredLogger
+    shortCode          uint
+    passkey            string
+    initiator          string
+    securityCredential string
+    idempotency        idempotency.Store
+    idempotencyTTL     time.Duration
+    msisdnProfile      *msisdn.Profile
+    tracer             trace.Tracer
+    tracePropagation   bool
+    metrics            metrics.Metrics
+    tracker            *tracker.Tracker
+    stkPushPollAfter   time.Duration
+    stkPushPollEvery   time.Duration
+}
+
+// New creates a new instance of MpesaClient configured with functional options.
+//
+// Parameters:
+//   - consumerKey: Your M-Pesa API consumer key.
+//   - consumerSecret: Your M-Pesa API consumer secret.
+//   - opts: Options such as WithEnvironment, WithHTTPClient, WithTimeout or WithRetryPolicy.
+//
+// Returns:
+//   - A pointer to an initialized MpesaClient instance.
+//   - An error if any of the input parameters are invalid.
+//
+// Example:
+//   client, err := mpesasdk.New(key, secret,
+//       mpesasdk.WithEnvironment(common.PRODUCTION),
+//       mpesasdk.WithTimeout(10*time.Second),
+//       mpesasdk.WithDefaultShortCode(554433),
+//   )
+func New(consumerKey, consumerSecret string, opts ...Option) (*MpesaClient, error) {
+    cfg := &config{
+        env:               common.SANDBOX,
+        paths:             map[common.Operation]string{},
+        operationPolicies: map[common.Operation]client.RetryPolicy{},
+        idempotencyTTL:    idempotency.DefaultReservationTTL,
+        msisdnProfile:     msisdn.Ethiopia,
+        stkPushPollAfter:  30 * time.Second,
+        stkPushPollEvery:  5 * time.Second,
+    }
+    for _, opt := range opts {
+        opt(cfg)
+    }
+
+    if cfg.env != common.PRODUCTION && cfg.env != common.SANDBOX {
+        return nil, errors.New("invalid environment: must be either 'Production' or 'Sandbox'")
+    }
+
+    if consumerKey == "" || consumerSecret == "" {
+        return nil, errors.New("consumer key and consumer secret cannot be empty")
+    }
+
+    httpClient := cfg.httpClient
+    switch {
+    case httpClient == nil:
+        timeout := cfg.timeout
+        if timeout <= 0 {
+            timeout = 5 * time.Second
+        }
+        httpClient = &http.Client{Timeout: timeout}
+    case cfg.timeout > 0:
+        // Never modify the caller's client
+        copied := *httpClient
+        copied.Timeout = cfg.timeout
+        httpClient = &copied
+    }
+
+    retryPolicy := cfg.retryPolicy
+    if retryPolicy == nil {
+        retryPolicy = client.NewDefaultRetryPolicy()
+    }
+
+    logger := cfg.logger
+    if logger == nil {
+        logger = service.NewStdLogger(service.INFO)
+    }
+    redactor := cfg.redactor
+    if redactor == nil {
+        redactor = redact.New(redact.WithMSISDNProfiles(cfg.msisdnProfile))
+    }
+    logger = redactor.Logger(logger)
+
+    securityCredential := ""
+    if cfg.initiatorPassword != "" {
+        cert := cfg.certificate
+        if cert == nil {
+            var err error
+            if cert, err = security.Certificate(cfg.env); err != nil {
+                return nil, err
+            }
+        }
+
+        var err error
+        if securityCredential, err = security.EncryptPassword(cfg.initiatorPassword, cert); err != nil {
+            return nil, err
+        }
+    }
+
+    observer := cfg.metrics
+    if observer == nil {
+        observer = metrics.Nop{}
+    }
+
+    endpoints := utils.NewEndpoints()
+    endpoints.SetBaseURL(cfg.baseURL)
+    for op, path := range cfg.paths {
+        endpoints.SetPath(op, path)
+    }
+
+    auth := auth.NewAuthorizationToken(consumerKey, consumerSecret)
+    auth.SetEndpoints(endpoints)
+    auth.SetHTTPClient(httpClient)
+    auth.SetTracerProvider(cfg.tracerProvider)
+    auth.SetMetrics(observer)
+    if cfg.tokenStore != nil {
+        auth.SetTokenStore(cfg.tokenStore)
+    }
+
+    apiClient := client.NewCustomHttpClient(httpClient, retryPolicy, auth)
+    apiClient.SetEndpoints(endpoints)
+    apiClient.SetLogger(logger)
+    apiClient.SetRedactor(redactor)
+    apiClient.SetDebugSink(cfg.debugSink)
+    apiClient.Use(cfg.interceptors...)
+    if cfg.tracker != nil {
+        apiClient.Use(cfg.tracker.Interceptor())
+    }
+    apiClient.SetTracerProvider(cfg.tracerProvider)
+    apiClient.SetMetrics(observer)
+
+    // Moving money twice is worse than failing, only retry these when the API did not process them
+    apiClient.SetOperationRetryPolicy(common.B2COperation, client.NewSafeRetryPolicy())
+    apiClient.SetOperationRetryPolicy(common.TransactionReversalOperation, client.NewSafeRetryPolicy())
+    for op, policy := range cfg.operationPolicies {
+        apiClient.SetOperationRetryPolicy(op, policy)
+    }
+
+    logger.Info("created mpesa client", "environment", cfg.env)
+
+    return &MpesaClient{
+        consumerKey:        consumerKey,
+        consumerSecret:     consumerSecret,
+        env:                cfg.env,
+        auth:               auth,
+        client:             apiClient,
+        endpoints:          endpoints,
+        logger:             logger,
+        shortCode:          cfg.shortCode,
+        passkey:            cfg.passkey,
+        initiator:          cfg.initiator,
+        securityCredential: securityCredential,
+        idempotency:        cfg.idempotencyStore,
+        idempotencyTTL:     cfg.idempotencyTTL,
+        msisdnProfile:      cfg.msisdnProfile,
+        tracer:             tracing.Tracer(cfg.tracerProvider),
+        tracePropagation:   cfg.tracePropagation,
+        metrics:            observer,
+        tracker:            cfg.tracker,
+        stkPushPollAfter:   cfg.stkPushPollAfter,
+        stkPushPollEvery:   cfg.stkPushPollEvery,
+    }, nil
 }
 
 // NewMpesaClient creates a new instance of MpesaClient.
 //
+// It is kept for compatibility, New accepts the same settings as options and more. Unlike
+// New, every operation including B2C payments and reversals is retried on timeouts, as before
+// New existed.
+//
 // Parameters:
 //   - consumerKey: Your M-Pesa API consumer key.
 //   - consumerSecret: Your M-Pesa API consumer secret.
@@ -55,66 +242,225 @@ func NewMpesaClient(
     timeout time.Duration,
     maxRetries uint,
 ) (*MpesaClient, error) {
-    if env != common.PRODUCTION && env != common.SANDBOX {
-        return nil, errors.New("invalid environment: must be either 'Production' or 'Sandbox'")
+    if maxRetries == 0 {
+        maxRetries = 1
     }
 
-    if consumerKey == "" || consumerSecret == "" {
-        return nil, errors.New("consumer key and consumer secret cannot be empty")
+    policy := client.NewTimeoutRetryPolicy(maxRetries)
+    return New(consumerKey, consumerSecret,
+        WithEnvironment(env),
+        WithLogger(service.NewStdLogger(logLevel)),
+        WithTimeout(timeout),
+        WithRetryPolicy(policy),
+        WithOperationRetryPolicy(common.B2COperation, policy),
+        WithOperationRetryPolicy(common.TransactionReversalOperation, policy),
+    )
+}
+
+// SetBaseURL sends every request, including token requests, to baseURL instead of the
+// environment's default host, e.g. a local stub (see package mpesatest), a proxy or a
+// Kenya Daraja deployment. An empty baseURL restores the default host.
+func (m *MpesaClient) SetBaseURL(baseURL string) {
+    m.endpoints.SetBaseURL(baseURL)
+}
+
+// SetEndpointPath overrides the path of a single operation, e.g. to use version 1 of the B2C API:
+//
+//   client.SetEndpointPath(common.B2COperation, "/mpesa/b2c/v1/paymentrequest")
+func (m *MpesaClient) SetEndpointPath(op common.Operation, path string) {
+    m.endpoints.SetPath(op, path)
+}
+
+// defaultShortCode returns the shortcode configured with WithDefaultShortCode formatted
+// for requests carrying it as a string, or an empty string when none is configured.
+func (m *MpesaClient) defaultShortCode() string {
+    if m.shortCode == 0 {
+        return ""
     }
+    return strconv.FormatUint(uint64(m.shortCode), 10)
+}
 
-    if timeout <= 0 {
-        timeout = 5 * time.Second
+// submitOnce submits the non-idempotent request of call keyed by its OriginatorConversationID,
+// generating one when it is empty. With an idempotency store configured, a key that was already
+// submitted successfully returns the saved acknowledgement instead of submitting the request again.
+func submitOnce[T any](ctx context.Context, m *MpesaClient, call *client.Call, id *string) (T, error) {
+    if *id == "" {
+        *id = uuid.NewString()
+    }
+    if m.idempotency == nil {
+        return executeCall[T](ctx, m, call)
     }
 
-    if maxRetries == 0 {
-        maxRetries = 1
+    op := call.Operation
+    key := string(op) + ":" + *id
+    if ack, ok, err := m.idempotency.Load(ctx, key); err != nil {
+        return *new(T), err
+    } else if ok {
+        m.logger.Info("returning the saved acknowledgement", "operation", op, "originator_conversation_id", *id)
+        response := *new(T)
+        err := json.Unmarshal(ack, &response)
+        return response, err
     }
 
-    auth := auth.NewAuthorizationToken(consumerKey, consumerSecret)
-    httpClient := client.NewHttpClient(timeout, maxRetries, auth)
-    logger := service.NewLogger(logLevel)
+    reserved, err := m.idempotency.Reserve(ctx, key, m.idempotencyTTL)
+    if err != nil {
+        return *new(T), err
+    }
+    if !reserved {
+        return *new(T), idempotency.ErrInProgress
+    }
+
+    fields := []any{"operation", op, "originator_conversation_id", *id}
+    response, err := executeCall[T](ctx, m, call)
+    if err != nil {
+        // Release the reservation when the request certainly did not reach the API or the API
+        // rejected it. Keep it until it expires after a timeout or a 5xx response of the API or
+        // a gateway, the request may have been processed
+        if !call.Sent || call.HTTPResponse != nil && call.HTTPResponse.StatusCode < http.StatusInternalServerError {
+            if releaseErr := m.idempotency.Release(context.WithoutCancel(ctx), key); releaseErr != nil {
+                m.logger.Error("failed to release the idempotency key", append(fields, "error", releaseErr)...)
+                return response, errors.Join(err, releaseErr)
+            }
+        }
+        return response, err
+    }
 
-    logger.Info("Successfully created mpesa client.")
+    ack, err := json.Marshal(response)
+    if err == nil {
+        err = m.idempotency.Save(context.WithoutCancel(ctx), key, ack)
+    }
+    if err != nil {
+        // The request was acknowledged, the reservation blocks new submissions until it expires
+        m.logger.Error("failed to save the acknowledgement", append(fields, "error", err)...)
+    }
+    return response, nil
+}
 
-    return &MpesaClient{
-        consumerKey:    consumerKey,
-        consumerSecret: consumerSecret,
-        env:            env,
-        client:         httpClient,
-        logger:         logger,
-    }, nil
+// executeRequest validates a request, fills its defaults and sends it, within a span of the
+// operation, reporting the call to the metrics of the client.
+func executeRequest[T any](ctx context.Context, m *MpesaClient, req common.MpesaRequest, op common.Operation, endpoint, method string, authType string) (T, error) {
+    return executeCall[T](ctx, m, m.newCall(req, op, endpoint, method, authType))
+}
+
+// newCall creates the call of a request to the API.
+func (m *MpesaClient) newCall(req common.MpesaRequest, op common.Operation, endpoint, method string, authType string) *client.Call {
+    return &client.Call{Operation: op, Endpoint: endpoint, Method: method, Env: m.env, AuthType: authType, Request: req}
+}
+
+// executeCall implements executeRequest, leaving the outcome of the exchange in call.
+func executeCall[T any](ctx context.Context, m *MpesaClient, call *client.Call) (T, error) {
+    op, method := call.Operation, call.Method
+
+    // The query may hold credentials, such as the apikey of the URL registration
+    path, _, _ := strings.Cut(call.Endpoint, "?")
+
+    ctx, span := m.tracer.Start(ctx, "mpesa."+string(op), trace.WithSpanKind(trace.SpanKindClient), trace.WithAttributes(
+        tracing.OperationKey.String(string(op)),
+        tracing.EndpointKey.String(path),
+        tracing.EnvironmentKey.String(string(m.env)),
+        tracing.HTTPMethodKey.String(method),
+    ))
+    start := time.Now()
+    m.metrics.RequestStarted(op)
+    response, err := sendRequest[T](ctx, m, call, path)
+    m.metrics.RequestFinished(op, time.Since(start), metrics.Code(err))
+    tracing.End(span, err)
+    return response, err
 }
 
-func executeRequest[T any](m *MpesaClient, req common.MpesaRequest, endpoint, method string, authType string) (T, error) {
+// sendRequest implements executeCall, recording the outcome of the request on the span of ctx.
+func sendRequest[T any](ctx context.Context, m *MpesaClient, call *client.Call, path string) (T, error) {
+    if err := ctx.Err(); err != nil {
+        return *new(T), err
+    }
+
+    req := call.Request
+    span := trace.SpanFromContext(ctx)
+    fields := []any{"operation", call.Operation, "endpoint", path, "method", call.Method}
+
+    // Add the trace context to the callback URL before validating it
+    if propagator, ok := req.(interface{ InjectTraceContext(context.Context) }); ok && m.tracePropagation {
+        propagator.InjectTraceContext(ctx)
+    }
+
     // Validate the request
-    m.logger.Info("Sending request to %v", endpoint)
     if err := req.Validate(); err != nil {
-        m.logger.Error("Request to %v validation failed", endpoint)
+        m.logger.Error("request validation failed", append(fields, "error", err)...)
         return *new(T), err
     }
 
     // Populate defaults
     req.FillDefaults()
+    fields = append(fields, correlationFields(req)...)
+    span.SetAttributes(correlationAttributes(correlationFields(req))...)
+    m.logger.Debug("sending request", fields...)
 
-    response, err := m.client.ApiRequest(m.env, endpoint, method, req, authType)
-    if err != nil {
-        m.logger.Error("Request to %v api request failed", endpoint)
+    // Send the request through the interceptors, which also decode the response
+    start := time.Now()
+    res, err := m.client.Execute(ctx, call)
+    span.SetAttributes(tracing.AttemptsKey.Int(int(call.Attempts)))
+    if call.HTTPResponse == nil && err != nil {
+        m.logger.Error("api request failed", append(fields, "latency", time.Since(start), "error", err)...)
         return *new(T), err
     }
-    defer response.Body.Close()
 
-    // Decode the response and type assert the response failing is impossible
-    res, err := req.DecodeResponse(response)
+    if call.HTTPResponse != nil {
+        fields = append(fields, "status", call.HTTPResponse.StatusCode)
+        span.SetAttributes(tracing.HTTPStatusCodeKey.Int(call.HTTPResponse.StatusCode))
+    }
+    fields = append(fields, "latency", time.Since(start))
     if err != nil {
-        m.logger.Error("Request to %v failed to decode response", endpoint)
+        var sdkErr *sdkError.SDKError
+        if errors.As(err, &sdkErr) {
+            fields = append(fields, "code", sdkErr.Code(), "request_id", sdkErr.RequestID())
+            span.SetAttributes(tracing.ErrorCodeKey.String(sdkErr.Code()))
+        }
+        m.logger.Error("request failed", append(fields, "error", err)...)
     } else {
-        m.logger.Info("Request to %v successful", endpoint)
+        m.logger.Info("request succeeded", append(fields, correlationFields(res)...)...)
+        span.SetAttributes(correlationAttributes(correlationFields(res))...)
     }
     castedResponse, _ := res.(T)
     return castedResponse, err
 }
 
+// correlationKeys maps the fields of requests and responses identifying a transaction, and the
+// response code, to their log keys.
+var correlationKeys = []struct{ field, key string }{
+    {"ConversationID", "conversation_id"},
+    {"OriginatorConversationID", "originator_conversation_id"},
+    {"OriginatorConversatonId", "originator_conversation_id"},
+    {"MerchantRequestID", "merchant_request_id"},
+    {"CheckoutRequestID", "checkout_request_id"},
+    {"ResponseCode", "response_code"},
+}
+
+// correlationFields returns the log fields of the non-empty identifiers of a request or response.
+func correlationFields(v any) []any {
+    value := reflect.Indirect(reflect.ValueOf(v))
+    if value.Kind() != reflect.Struct {
+        return nil
+    }
+
+    var fields []any
+    for _, c := range correlationKeys {
+        if f := value.FieldByName(c.field); f.Kind() == reflect.String && f.String() != "" {
+            fields = append(fields, c.key, f.String())
+        }
+    }
+    return fields
+}
+
+// correlationAttributes converts correlation fields to span attributes, e.g. conversation_id to
+// mpesa.conversation_id.
+func correlationAttributes(fields []any) []attribute.KeyValue {
+    attributes := make([]attribute.KeyValue, 0, len(fields)/2)
+    for i := 0; i+1 < len(fields); i += 2 {
+        attributes = append(attributes, attribute.String("mpesa."+fields[i].(string), fields[i+1].(string)))
+    }
+    return attributes
+}
+
 // RegisterNewURL registers a new URL for receiving C2B (Customer-to-Business) payment notifications.
 //
 // Parameters:
@@ -124,8 +470,17 @@ func executeRequest[T any](m *MpesaClient, req common.MpesaRequest, endpoint, me
 //   - A RegisterC2BURLSuccessResponse if the registration is successful.
 //   - An error if the request fails validation or the API call fails.
 func (m *MpesaClient) RegisterNewURL(req c2b.RegisterC2BURLRequest) (c2b.RegisterC2BURLSuccessResponse, error) {
-    endpoint := "/v1/c2b-register-url/register?apikey=" + m.consumerKey
-    return executeRequest[c2b.RegisterC2BURLSuccessResponse](m, &req, endpoint, http.MethodPost, auth.AuthTypeNone)
+    return m.RegisterNewURLCtx(context.Background(), req)
+}
+
+// RegisterNewURLCtx is like RegisterNewURL but binds the request to ctx, so it is aborted
+// (including pending retries and token fetches) once ctx is cancelled or its deadline passes.
+func (m *MpesaClient) RegisterNewURLCtx(ctx context.Context, req c2b.RegisterC2BURLRequest) (c2b.RegisterC2BURLSuccessResponse, error) {
+    if req.ShortCode == "" {
+        req.ShortCode = m.defaultShortCode()
+    }
+    endpoint := m.endpoints.Path(common.RegisterURLOperation) + "?apikey=" + m.consumerKey
+    return executeRequest[c2b.RegisterC2BURLSuccessResponse](ctx, m, &req, common.RegisterURLOperation, endpoint, http.MethodPost, auth.AuthTypeNone)
 }
 
 // MakeB2CPaymentRequest initiates a B2C (Business-to-Customer) payment request.
@@ -134,13 +489,32 @@ func (m *MpesaClient) RegisterNewURL(req c2b.RegisterC2BURLRequest) (c2b.Registe
 //   - req: A B2CRequest containing the details of the payment.
 //
 // Returns:
-//   - A B2CSuccessResponse if the payment is successful.
+//   - A B2CSuccessResponse if the payment is successful. Its OriginatorConversationID, the one of
+//     req or the one generated when req has none, is set even when an error is returned, so that
+//     the status of a payment that timed out can be queried.
 //   - An error if the request fails validation or the API call fails.
 func (m *MpesaClient) MakeB2CPaymentRequest(req b2c.B2CRequest) (b2c.B2CSuccessResponse, error) {
-    endpoint := "/mpesa/b2c/v2/paymentrequest"
-    return executeRequest[b2c.B2CSuccessResponse](m, &req, endpoint, http.MethodPost, auth.AuthTypeBearer)
+    return m.MakeB2CPaymentRequestCtx(context.Background(), req)
 }
 
+// MakeB2CPaymentRequestCtx is like MakeB2CPaymentRequest but binds the request to ctx, so it is aborted
+// (including pending retries and token fetches) once ctx is cancelled or its deadline passes.
+func (m *MpesaClient) MakeB2CPaymentRequestCtx(ctx context.Context, req b2c.B2CRequest) (b2c.B2CSuccessResponse, error) {
+    if req.InitiatorName == "" {
+        req.InitiatorName = m.initiator
+    }
+    if req.PartyA == 0 {
+        req.PartyA = m.shortCode
+    }
+    req.SetSecurityCredential(m.securityCredential)
+    endpoint := m.endpoints.Path(common.B2COperation)
+    call := m.newCall(&req, common.B2COperation, endpoint, http.MethodPost, auth.AuthTypeBearer)
+    response, err := submitOnce[b2c.B2CSuccessResponse](ctx, m, call, &req.OriginatorConversationID)
+    if response.OriginatorConversatonId == "" {
+        response.OriginatorConversatonId = req.OriginatorConversationID
+    }
+    return response, err
+}
 
 // SimulateCustomerInitiatedPayment simulates a C2B (Customer-to-Business) payment for testing purposes.
 //
@@ -151,8 +525,18 @@ func (m *MpesaClient) MakeB2CPaymentRequest(req b2c.B2CRequest) (b2c.B2CSuccessR
 //   - A SimulatePaymentSuccessResponse if the simulation is successful.
 //   - An error if the request fails validation or the API call fails.
 func (m *MpesaClient) SimulateCustomerInitiatedPayment(req c2b.SimulateCustomerInititatedPayment) (c2b.SimulatePaymentSuccessResponse, error) {
-    endpoint := "/mpesa/b2c/simulatetransaction/v1/request"
-    return executeRequest[c2b.SimulatePaymentSuccessResponse](m, &req, endpoint, http.MethodPost, auth.AuthTypeBearer)
+    return m.SimulateCustomerInitiatedPaymentCtx(context.Background(), req)
+}
+
+// SimulateCustomerInitiatedPaymentCtx is like SimulateCustomerInitiatedPayment but binds the request to ctx, so it is aborted
+// (including pending retries and token fetches) once ctx is cancelled or its deadline passes.
+func (m *MpesaClient) SimulateCustomerInitiatedPaymentCtx(ctx context.Context, req c2b.SimulateCustomerInititatedPayment) (c2b.SimulatePaymentSuccessResponse, error) {
+    if req.ShortCode == "" {
+        req.ShortCode = m.defaultShortCode()
+    }
+    req.NormalizePhoneNumbers(m.msisdnProfile)
+    endpoint := m.endpoints.Path(common.SimulateC2BOperation)
+    return executeRequest[c2b.SimulatePaymentSuccessResponse](ctx, m, &req, common.SimulateC2BOperation, endpoint, http.MethodPost, auth.AuthTypeBearer)
 }
 
 // CheckTransactionStatus checks the status of a specific transaction.
@@ -164,8 +548,21 @@ func (m *MpesaClient) SimulateCustomerInitiatedPayment(req c2b.SimulateCustomerI
 //   - A TransactionStatusSuccessResponse if the transaction status is successfully retrieved.
 //   - An error if the request fails validation or the API call fails.
 func (m *MpesaClient) CheckTransactionStatus(req transaction.TransactionStatusRequest) (transaction.TransactionStatusSuccessResponse, error) {
-    endpoint := "/mpesa/transactionstatus/v1/query"
-    return executeRequest[transaction.TransactionStatusSuccessResponse](m, &req, endpoint, http.MethodPost, auth.AuthTypeBearer)
+    return m.CheckTransactionStatusCtx(context.Background(), req)
+}
+
+// CheckTransactionStatusCtx is like CheckTransactionStatus but binds the request to ctx, so it is aborted
+// (including pending retries and token fetches) once ctx is cancelled or its deadline passes.
+func (m *MpesaClient) CheckTransactionStatusCtx(ctx context.Context, req transaction.TransactionStatusRequest) (transaction.TransactionStatusSuccessResponse, error) {
+    if req.Initiator == "" {
+        req.Initiator = m.initiator
+    }
+    if req.PartyA == "" {
+        req.PartyA = m.defaultShortCode()
+    }
+    req.SetSecurityCredential(m.securityCredential)
+    endpoint := m.endpoints.Path(common.TransactionStatusOperation)
+    return executeRequest[transaction.TransactionStatusSuccessResponse](ctx, m, &req, common.TransactionStatusOperation, endpoint, http.MethodPost, auth.AuthTypeBearer)
 }
 
 // AccountBalance retrieves the balance of an account linked to the M-Pesa system.
@@ -177,8 +574,21 @@ func (m *MpesaClient) CheckTransactionStatus(req transaction.TransactionStatusRe
 //   - An AccountBalanceSuccessResponse if the balance is successfully retrieved.
 //   - An error if the request fails validation or the API call fails.
 func (m *MpesaClient) AccountBalance(req account.AccountBalanceRequest) (account.AccountBalanceSuccessResponse, error) {
-    endpoint := "/mpesa/accountbalance/v1/query"
-    return executeRequest[account.AccountBalanceSuccessResponse](m, &req, endpoint, http.MethodPost, auth.AuthTypeBearer)
+    return m.AccountBalanceCtx(context.Background(), req)
+}
+
+// AccountBalanceCtx is like AccountBalance but binds the request to ctx, so it is aborted
+// (including pending retries and token fetches) once ctx is cancelled or its deadline passes.
+func (m *MpesaClient) AccountBalanceCtx(ctx context.Context, req account.AccountBalanceRequest) (account.AccountBalanceSuccessResponse, error) {
+    if req.Initiator == "" {
+        req.Initiator = m.initiator
+    }
+    if req.PartyA == 0 {
+        req.PartyA = int(m.shortCode)
+    }
+    req.SetSecurityCredential(m.securityCredential)
+    endpoint := m.endpoints.Path(common.AccountBalanceOperation)
+    return executeRequest[account.AccountBalanceSuccessResponse](ctx, m, &req, common.AccountBalanceOperation, endpoint, http.MethodPost, auth.AuthTypeBearer)
 }
 
 // STKPushPaymentRequest initiates an STK Push request to facilitate a C2B payment.
@@ -191,11 +601,55 @@ func (m *MpesaClient) AccountBalance(req account.AccountBalanceRequest) (account
 //   - An STKPushRequestSuccessResponse if the payment is successfully initiated.
 //   - An error if the request fails validation or the API call fails.
 func (m *MpesaClient) STKPushPaymentRequest(passkey string, req c2b.STKPushPaymentRequest) (c2b.STKPushRequestSuccessResponse, error) {
+    return m.STKPushPaymentRequestCtx(context.Background(), passkey, req)
+}
+
+// STKPushPaymentRequestCtx is like STKPushPaymentRequest but binds the request to ctx, so it is aborted
+// (including pending retries and token fetches) once ctx is cancelled or its deadline passes.
+func (m *MpesaClient) STKPushPaymentRequestCtx(ctx context.Context, passkey string, req c2b.STKPushPaymentRequest) (c2b.STKPushRequestSuccessResponse, error) {
+    if passkey == "" {
+        passkey = m.passkey
+    }
+    if req.BusinessShortCode == 0 {
+        req.BusinessShortCode = m.shortCode
+    }
+    if req.PartyB == "" {
+        req.PartyB = m.defaultShortCode()
+    }
     req.SetPasskey(passkey)
-    endpoint := "/mpesa/stkpush/v1/processrequest"
-    return executeRequest[c2b.STKPushRequestSuccessResponse](m, &req, endpoint, http.MethodPost, auth.AuthTypeBearer)
+    req.NormalizePhoneNumbers(m.msisdnProfile)
+    endpoint := m.endpoints.Path(common.STKPushOperation)
+    return executeRequest[c2b.STKPushRequestSuccessResponse](ctx, m, &req, common.STKPushOperation, endpoint, http.MethodPost, auth.AuthTypeBearer)
 }
 
+// QuerySTKPush queries the status of an STK push by its CheckoutRequestID, e.g. when its
+// callback did not arrive.
+//
+// Parameters:
+//   - passkey: The STK passkey the Password of the query is generated with.
+//   - req: An STKPushQueryRequest holding the CheckoutRequestID of the STK push.
+//
+// Returns:
+//   - An STKPushQueryResponse with the ResultCode and ResultDesc of the STK push.
+//   - An error if the request fails validation or the API call fails. The API fails with the
+//     "500.001.1001" code while the customer has not answered the PIN prompt.
+func (m *MpesaClient) QuerySTKPush(passkey string, req c2b.STKPushQueryRequest) (c2b.STKPushQueryResponse, error) {
+    return m.QuerySTKPushCtx(context.Background(), passkey, req)
+}
+
+// QuerySTKPushCtx is like QuerySTKPush but binds the request to ctx, so it is aborted
+// (including pending retries and token fetches) once ctx is cancelled or its deadline passes.
+func (m *MpesaClient) QuerySTKPushCtx(ctx context.Context, passkey string, req c2b.STKPushQueryRequest) (c2b.STKPushQueryResponse, error) {
+    if passkey == "" {
+        passkey = m.passkey
+    }
+    if req.BusinessShortCode == 0 {
+        req.BusinessShortCode = m.shortCode
+    }
+    req.SetPasskey(passkey)
+    endpoint := m.endpoints.Path(common.STKPushQueryOperation)
+    return executeRequest[c2b.STKPushQueryResponse](ctx, m, &req, common.STKPushQueryOperation, endpoint, http.MethodPost, auth.AuthTypeBearer)
+}
 
 // ReverseTransaction reverses a previously completed M-Pesa transaction.
 //
@@ -203,10 +657,30 @@ func (m *MpesaClient) STKPushPaymentRequest(passkey string, req c2b.STKPushPayme
 //   - req: A TransactionReversalRequest containing the transaction details to be reversed.
 //
 // Returns:
-//   - A TransactionReversalSuccessResponse if the transaction is successfully reversed.
+//   - A TransactionReversalSuccessResponse if the transaction is successfully reversed. Its
+//     OriginatorConversationID, the one of req or the one generated when req has none, is set even
+//     when an error is returned, so that the status of a reversal that timed out can be queried.
 //   - An error if the request fails validation or the API call fails.
 func (m *MpesaClient) ReverseTransaction(req transaction.TransactionReversalRequest) (transaction.TransactionReversalSuccessResponse, error) {
-    endpoint := "/mpesa/reversal/v1/request"
-    return executeRequest[transaction.TransactionReversalSuccessResponse](m, &req, endpoint, http.MethodPost, auth.AuthTypeBearer)
+    return m.ReverseTransactionCtx(context.Background(), req)
+}
+
+// ReverseTransactionCtx is like ReverseTransaction but binds the request to ctx, so it is aborted
+// (including pending retries and token fetches) once ctx is cancelled or its deadline passes.
+func (m *MpesaClient) ReverseTransactionCtx(ctx context.Context, req transaction.TransactionReversalRequest) (transaction.TransactionReversalSuccessResponse, error) {
+    if req.Initiator == "" {
+        req.Initiator = m.initiator
+    }
+    if req.ReceiverParty == "" {
+        req.ReceiverParty = m.defaultShortCode()
+    }
+    req.SetSecurityCredential(m.securityCredential)
+    endpoint := m.endpoints.Path(common.TransactionReversalOperation)
+    call := m.newCall(&req, common.TransactionReversalOperation, endpoint, http.MethodPost, auth.AuthTypeBearer)
+    response, err := submitOnce[transaction.TransactionReversalSuccessResponse](ctx, m, call, &req.OriginatorConversationID)
+    if response.OriginatorConversatonId == "" {
+        response.OriginatorConversatonId = req.OriginatorConversationID
+    }
+    return response, err
 }
 
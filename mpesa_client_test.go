@@ -0,0 +1,925 @@
+package mpesasdk_test
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"syscall"
+	"testing"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+
+	"github.com/coleYab/mpesasdk"
+	"github.com/coleYab/mpesasdk/account"
+	"github.com/coleYab/mpesasdk/b2c"
+	"github.com/coleYab/mpesasdk/c2b"
+	"github.com/coleYab/mpesasdk/client"
+	"github.com/coleYab/mpesasdk/common"
+	sdkError "github.com/coleYab/mpesasdk/errors"
+	"github.com/coleYab/mpesasdk/idempotency"
+	"github.com/coleYab/mpesasdk/metrics"
+	"github.com/coleYab/mpesasdk/mpesatest"
+	"github.com/coleYab/mpesasdk/msisdn"
+	"github.com/coleYab/mpesasdk/service"
+	"github.com/coleYab/mpesasdk/tracing"
+	"github.com/coleYab/mpesasdk/tracker"
+)
+
+type countingTransport struct {
+	requests int
+}
+
+func (t *countingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	t.requests++
+	return http.DefaultTransport.RoundTrip(req)
+}
+
+// failingTransport fails the next requests to a path with an error, before sending them.
+type failingTransport struct {
+	mu       sync.Mutex
+	path     string
+	err      error
+	failures int
+}
+
+func (t *failingTransport) failNext(path string, err error, times int) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.path, t.err, t.failures = path, err, times
+}
+
+func (t *failingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	t.mu.Lock()
+	fail := req.URL.Path == t.path && t.failures > 0
+	if fail {
+		t.failures--
+	}
+	t.mu.Unlock()
+
+	if fail {
+		return nil, t.err
+	}
+	return http.DefaultTransport.RoundTrip(req)
+}
+
+func TestNewWithOptions(t *testing.T) {
+	sim := mpesatest.NewServer()
+	defer sim.Close()
+
+	transport := &countingTransport{}
+	client, err := sim.NewClient(
+		mpesasdk.WithHTTPClient(&http.Client{Transport: transport}),
+		mpesasdk.WithDefaultShortCode(554433),
+		mpesasdk.WithPasskey("default-passkey"),
+	)
+	if err != nil {
+		t.Fatalf("failed to create client: %v", err)
+	}
+
+	sim.SetOutcome(mpesatest.OutcomeNoCallback)
+	_, err = client.STKPushPaymentRequest("", c2b.STKPushPaymentRequest{
+		TransactionType:  common.CustomerPayBillOnlineTransaction,
+		Amount:           10,
+		PartyA:           "251700000000",
+		PhoneNumber:      "251700000000",
+		CallBackURL:      "https://example.com/callback",
+		AccountReference: "INV-1",
+		TransactionDesc:  "Payment",
+	})
+	if err != nil {
+		t.Fatalf("expecting stk push to be accepted but got: %v", err)
+	}
+
+	if transport.requests != 2 {
+		t.Fatalf("expecting the token and stk push requests to use the injected client but got %v requests", transport.requests)
+	}
+
+	requests := sim.Requests()
+	sent := c2b.STKPushPaymentRequest{}
+	if err := json.Unmarshal(requests[len(requests)-1].Body, &sent); err != nil {
+		t.Fatalf("failed to decode the sent request: %v", err)
+	}
+
+	if sent.BusinessShortCode != 554433 || sent.PartyB != "554433" {
+		t.Fatalf("expecting the defaults to be applied but got %+v", sent)
+	}
+
+	password, _ := base64.StdEncoding.DecodeString(sent.Password)
+	if !strings.HasPrefix(string(password), "554433default-passkey") {
+		t.Fatalf("expecting the default passkey to be used but got %s", password)
+	}
+}
+
+func TestNewRejectsInvalidSettings(t *testing.T) {
+	if _, err := mpesasdk.New("", "secret"); err == nil {
+		t.Fatalf("expecting an empty consumer key to be rejected")
+	}
+
+	if _, err := mpesasdk.New("key", "secret", mpesasdk.WithEnvironment("Staging")); err == nil {
+		t.Fatalf("expecting an unknown environment to be rejected")
+	}
+}
+
+func TestNewMpesaClientRetriesB2CTimeouts(t *testing.T) {
+	sim := mpesatest.NewServer()
+	defer sim.Close()
+	sim.SetOutcome(mpesatest.OutcomeNoCallback)
+
+	client, err := mpesasdk.NewMpesaClient(mpesatest.ConsumerKey, mpesatest.ConsumerSecret, common.SANDBOX, service.ERROR, 300*time.Millisecond, 1)
+	if err != nil {
+		t.Fatalf("failed to create client: %v", err)
+	}
+	client.SetBaseURL(sim.URL())
+
+	req := b2c.B2CRequest{
+		InitiatorName:      "apiuser",
+		SecurityCredential: "credential",
+		CommandID:          common.BusinessPaymentCommand,
+		Amount:             100,
+		PartyA:             600000,
+		PartyB:             251700000000,
+		Remarks:            "Payout",
+		QueueTimeOutURL:    "https://example.com/timeout",
+		ResultURL:          "https://example.com/result",
+	}
+	if _, err := client.MakeB2CPaymentRequest(req); err != nil {
+		t.Fatalf("expecting b2c to be accepted but got: %v", err)
+	}
+
+	// The first attempt times out, the retry a second later is answered
+	sim.SetDelay(time.Second)
+	time.AfterFunc(600*time.Millisecond, func() { sim.SetDelay(0) })
+	if _, err := client.MakeB2CPaymentRequest(req); err != nil {
+		t.Fatalf("expecting the timed out b2c to be retried but got: %v", err)
+	}
+
+	if sent := sim.RequestsTo(mpesatest.B2CPath); sent != 3 {
+		t.Fatalf("expecting the timed out b2c to be sent twice but got %d requests", sent-1)
+	}
+}
+
+func TestRequestsAreLoggedWithStructuredFields(t *testing.T) {
+	sim := mpesatest.NewServer()
+	defer sim.Close()
+	sim.SetOutcome(mpesatest.OutcomeNoCallback)
+
+	var out bytes.Buffer
+	client, err := sim.NewClient(
+		mpesasdk.WithDefaultShortCode(554433),
+		mpesasdk.WithLogger(service.NewSlogLogger(slog.New(slog.NewJSONHandler(&out, nil)))),
+	)
+	if err != nil {
+		t.Fatalf("failed to create client: %v", err)
+	}
+
+	_, err = client.MakeB2CPaymentRequest(b2c.B2CRequest{
+		InitiatorName:            "testapi",
+		SecurityCredential:       "credential",
+		CommandID:                common.BusinessPaymentCommand,
+		Amount:                   10,
+		PartyB:                   251700000000,
+		Remarks:                  "Payout",
+		QueueTimeOutURL:          "https://example.com/timeout",
+		ResultURL:                "https://example.com/result",
+		OriginatorConversationID: "payout-1",
+	})
+	if err != nil {
+		t.Fatalf("expecting b2c to be accepted but got: %v", err)
+	}
+
+	var record map[string]any
+	for _, line := range bytes.Split(bytes.TrimSpace(out.Bytes()), []byte("\n")) {
+		json.Unmarshal(line, &record)
+	}
+	if record["msg"] != "request succeeded" || record["endpoint"] != mpesatest.B2CPath || record["method"] != http.MethodPost ||
+		record["status"] != 200.0 || record["originator_conversation_id"] != "payout-1" || record["conversation_id"] == nil || record["latency"] == nil {
+		t.Fatalf("unexpected log record %v", record)
+	}
+}
+
+func TestInterceptorsSeeTypedRequestsAndResponses(t *testing.T) {
+	sim := mpesatest.NewServer()
+	defer sim.Close()
+	sim.SetOutcome(mpesatest.OutcomeNoCallback)
+
+	var seen *client.Call
+	var response interface{}
+	c, err := sim.NewClient(
+		mpesasdk.WithDefaultShortCode(554433),
+		mpesasdk.WithInterceptors(func(next client.RoundTrip) client.RoundTrip {
+			return func(ctx context.Context, call *client.Call) (interface{}, error) {
+				seen = call
+				res, err := next(ctx, call)
+				response = res
+				return res, err
+			}
+		}),
+	)
+	if err != nil {
+		t.Fatalf("failed to create client: %v", err)
+	}
+
+	ack, err := c.MakeB2CPaymentRequest(b2c.B2CRequest{
+		InitiatorName:            "testapi",
+		SecurityCredential:       "credential",
+		CommandID:                common.BusinessPaymentCommand,
+		Amount:                   10,
+		PartyB:                   251700000000,
+		Remarks:                  "Payout",
+		QueueTimeOutURL:          "https://example.com/timeout",
+		ResultURL:                "https://example.com/result",
+		OriginatorConversationID: "payout-2",
+	})
+	if err != nil {
+		t.Fatalf("expecting b2c to be accepted but got: %v", err)
+	}
+
+	if req, ok := seen.Request.(*b2c.B2CRequest); !ok || req.OriginatorConversationID != "payout-2" {
+		t.Fatalf("expecting the interceptor to see the b2c request but got %#v", seen.Request)
+	}
+	if seen.Operation != common.B2COperation || seen.Endpoint != mpesatest.B2CPath || seen.HTTPResponse.StatusCode != http.StatusOK {
+		t.Fatalf("unexpected call %+v", seen)
+	}
+	if decoded, ok := response.(b2c.B2CSuccessResponse); !ok || decoded.ConversationID != ack.ConversationID {
+		t.Fatalf("expecting the interceptor to see the decoded response but got %#v", response)
+	}
+}
+
+func TestSTKPushAndCallbackShareATrace(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+	previous := otel.GetTracerProvider()
+	otel.SetTracerProvider(tp)
+	defer otel.SetTracerProvider(previous)
+
+	sim := mpesatest.NewServer()
+	defer sim.Close()
+
+	callbacks := make(chan struct{}, 1)
+	receiver := httptest.NewTLSServer(c2b.NewSTKCallbackHandler(func(ctx context.Context, cb *c2b.STKCallback) error {
+		callbacks <- struct{}{}
+		return nil
+	}))
+	defer receiver.Close()
+
+	c, err := sim.NewClient(
+		mpesasdk.WithDefaultShortCode(554433),
+		mpesasdk.WithTracerProvider(tp),
+		mpesasdk.WithCallbackTracePropagation(),
+	)
+	if err != nil {
+		t.Fatalf("failed to create client: %v", err)
+	}
+
+	ack, err := c.STKPushPaymentRequest("passkey", c2b.STKPushPaymentRequest{
+		TransactionType:  common.CustomerPayBillOnlineTransaction,
+		Amount:           10,
+		PartyA:           "251700000000",
+		PhoneNumber:      "251700000000",
+		CallBackURL:      receiver.URL + "/stk",
+		AccountReference: "INV-1",
+		TransactionDesc:  "Payment",
+	})
+	if err != nil {
+		t.Fatalf("expecting stk push to be accepted but got: %v", err)
+	}
+
+	select {
+	case <-callbacks:
+	case <-time.After(5 * time.Second):
+		t.Fatalf("no callback received")
+	}
+
+	// The callback span ends once the callback function returned
+	spans := map[string]sdktrace.ReadOnlySpan{}
+	for deadline := time.Now().Add(time.Second); spans["mpesa.callback.stk"] == nil && time.Now().Before(deadline); time.Sleep(10 * time.Millisecond) {
+		for _, span := range recorder.Ended() {
+			spans[span.Name()] = span
+		}
+	}
+	push, attempt, token, callback := spans["mpesa.STKPush"], spans["mpesa.attempt"], spans["mpesa.token"], spans["mpesa.callback.stk"]
+	if push == nil || attempt == nil || token == nil || callback == nil {
+		t.Fatalf("missing spans, got %v", spans)
+	}
+
+	attributes := map[attribute.Key]attribute.Value{}
+	for _, kv := range push.Attributes() {
+		attributes[kv.Key] = kv.Value
+	}
+	if attributes[tracing.AttemptsKey].AsInt64() != 1 || attributes[tracing.HTTPStatusCodeKey].AsInt64() != http.StatusOK ||
+		attributes[tracing.ResponseCodeKey].AsString() != "0" || attributes[tracing.CheckoutRequestIDKey].AsString() != ack.CheckoutRequestID ||
+		attributes[tracing.EnvironmentKey].AsString() != string(common.SANDBOX) {
+		t.Fatalf("unexpected attributes %v", attributes)
+	}
+
+	if attempt.Parent().SpanID() != push.SpanContext().SpanID() || token.Parent().SpanID() != attempt.SpanContext().SpanID() {
+		t.Fatal("expecting the attempt and token spans to be children of the push span")
+	}
+	if callback.Parent().SpanID() != push.SpanContext().SpanID() || callback.SpanContext().TraceID() != push.SpanContext().TraceID() {
+		t.Fatal("expecting the callback span to continue the trace of the push")
+	}
+}
+
+// recordingMetrics records the codes of the calls and token refreshes.
+type recordingMetrics struct {
+	metrics.Nop
+	mu      sync.Mutex
+	started int
+	codes   []string
+	tokens  []string
+}
+
+func (m *recordingMetrics) RequestStarted(op common.Operation) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.started++
+}
+
+func (m *recordingMetrics) RequestFinished(op common.Operation, duration time.Duration, code string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.codes = append(m.codes, string(op)+":"+code)
+}
+
+func (m *recordingMetrics) TokenRefreshed(duration time.Duration, code string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.tokens = append(m.tokens, code)
+}
+
+func TestCallbackURLIsSentAsGivenByDefault(t *testing.T) {
+	sim := mpesatest.NewServer()
+	defer sim.Close()
+	sim.SetOutcome(mpesatest.OutcomeNoCallback)
+
+	c, err := sim.NewClient(mpesasdk.WithDefaultShortCode(554433), mpesasdk.WithTracerProvider(sdktrace.NewTracerProvider()))
+	if err != nil {
+		t.Fatalf("failed to create client: %v", err)
+	}
+
+	_, err = c.STKPushPaymentRequest("passkey", c2b.STKPushPaymentRequest{
+		TransactionType:  common.CustomerPayBillOnlineTransaction,
+		Amount:           10,
+		PartyA:           "251700000000",
+		PhoneNumber:      "251700000000",
+		CallBackURL:      "https://example.com/stk?order=42",
+		AccountReference: "INV-1",
+		TransactionDesc:  "Payment",
+	})
+	if err != nil {
+		t.Fatalf("expecting stk push to be accepted but got: %v", err)
+	}
+
+	requests := sim.Requests()
+	sent := c2b.STKPushPaymentRequest{}
+	json.Unmarshal(requests[len(requests)-1].Body, &sent)
+	if sent.CallBackURL != "https://example.com/stk?order=42" {
+		t.Fatalf("expecting the callback URL to be sent as given but got %v", sent.CallBackURL)
+	}
+}
+
+func TestCallsAreReportedToMetrics(t *testing.T) {
+	sim := mpesatest.NewServer()
+	defer sim.Close()
+	sim.SetOutcome(mpesatest.OutcomeNoCallback)
+
+	recorder := &recordingMetrics{}
+	c, err := sim.NewClient(mpesasdk.WithDefaultShortCode(554433), mpesasdk.WithMetrics(recorder))
+	if err != nil {
+		t.Fatalf("failed to create client: %v", err)
+	}
+
+	if _, err := c.AccountBalance(account.AccountBalanceRequest{}); err == nil {
+		t.Fatalf("expecting an empty request to be rejected")
+	}
+	_, err = c.MakeB2CPaymentRequest(b2c.B2CRequest{
+		InitiatorName:      "testapi",
+		SecurityCredential: "credential",
+		CommandID:          common.BusinessPaymentCommand,
+		Amount:             10,
+		PartyB:             251700000000,
+		Remarks:            "Payout",
+		QueueTimeOutURL:    "https://example.com/timeout",
+		ResultURL:          "https://example.com/result",
+	})
+	if err != nil {
+		t.Fatalf("expecting b2c to be accepted but got: %v", err)
+	}
+
+	recorder.mu.Lock()
+	defer recorder.mu.Unlock()
+	if recorder.started != 2 || len(recorder.codes) != 2 || recorder.codes[0] != "AccountBalance:VALIDATION_ERROR" || recorder.codes[1] != "B2C:OK" {
+		t.Fatalf("unexpected calls %v started, finished %v", recorder.started, recorder.codes)
+	}
+	if len(recorder.tokens) != 1 || recorder.tokens[0] != metrics.CodeOK {
+		t.Fatalf("expecting a single token refresh but got %v", recorder.tokens)
+	}
+}
+
+func TestQuerySTKPush(t *testing.T) {
+	sim := mpesatest.NewServer()
+	defer sim.Close()
+
+	c, err := sim.NewClient(mpesasdk.WithDefaultShortCode(554433), mpesasdk.WithPasskey("default-passkey"))
+	if err != nil {
+		t.Fatalf("failed to create client: %v", err)
+	}
+
+	sim.SetOutcome(mpesatest.OutcomeCancelledByUser)
+	ack, err := c.STKPushPaymentRequest("", c2b.STKPushPaymentRequest{
+		TransactionType:  common.CustomerPayBillOnlineTransaction,
+		Amount:           10,
+		PartyA:           "251700000000",
+		PhoneNumber:      "251700000000",
+		CallBackURL:      "https://example.com/callback",
+		AccountReference: "INV-1",
+		TransactionDesc:  "Payment",
+	})
+	if err != nil {
+		t.Fatalf("expecting stk push to be accepted but got: %v", err)
+	}
+
+	status, err := c.QuerySTKPush("", c2b.STKPushQueryRequest{CheckoutRequestID: ack.CheckoutRequestID})
+	if err != nil {
+		t.Fatalf("expecting the status of the stk push but got: %v", err)
+	}
+	if status.ResultCode != "1032" || status.Successful() || status.MerchantRequestID != ack.MerchantRequestID {
+		t.Fatalf("unexpected status %+v", status)
+	}
+
+	requests := sim.Requests()
+	sent := c2b.STKPushQueryRequest{}
+	if err := json.Unmarshal(requests[len(requests)-1].Body, &sent); err != nil {
+		t.Fatalf("failed to decode the sent request: %v", err)
+	}
+	password, _ := base64.StdEncoding.DecodeString(sent.Password)
+	if sent.BusinessShortCode != 554433 || string(password) != "554433default-passkey"+sent.Timestamp {
+		t.Fatalf("expecting the defaults to be applied but got %+v", sent)
+	}
+
+	if _, err := c.QuerySTKPush("", c2b.STKPushQueryRequest{CheckoutRequestID: "ws_CO_unknown"}); !errors.Is(err, sdkError.ErrValidation) {
+		t.Fatalf("expecting an unknown CheckoutRequestID to be rejected but got: %v", err)
+	}
+}
+
+func TestSTKPushAndWait(t *testing.T) {
+	sim := mpesatest.NewServer()
+	defer sim.Close()
+
+	payments := tracker.New()
+	receiver := httptest.NewTLSServer(c2b.NewSTKCallbackHandler(payments.STKCallbackFunc(nil)))
+	defer receiver.Close()
+
+	c, err := sim.NewClient(
+		mpesasdk.WithDefaultShortCode(554433),
+		mpesasdk.WithTracker(payments),
+		mpesasdk.WithSTKPushPolling(50*time.Millisecond, 20*time.Millisecond),
+	)
+	if err != nil {
+		t.Fatalf("failed to create client: %v", err)
+	}
+	req := c2b.STKPushPaymentRequest{
+		TransactionType:  common.CustomerPayBillOnlineTransaction,
+		Amount:           10,
+		PartyA:           "251700000000",
+		PhoneNumber:      "251700000000",
+		CallBackURL:      receiver.URL + "/stk",
+		AccountReference: "INV-1",
+		TransactionDesc:  "Payment",
+	}
+
+	tests := []struct {
+		name     string
+		outcome  mpesatest.Outcome
+		status   mpesasdk.STKPushStatus
+		code     string
+		callback bool
+	}{
+		{"paid", mpesatest.OutcomeSuccess, mpesasdk.STKPushPaid, "0", true},
+		{"cancelled", mpesatest.OutcomeCancelledByUser, mpesasdk.STKPushCancelled, "1032", true},
+		{"timed out", mpesatest.OutcomeTimeout, mpesasdk.STKPushTimedOut, "1037", true},
+		{"failed", mpesatest.OutcomeWrongPIN, mpesasdk.STKPushFailed, "2001", true},
+		// The query answers "being processed" until the callback is due, then reports the payment
+		{"queried", mpesatest.OutcomeNoCallback, mpesasdk.STKPushPaid, "0", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sim.SetOutcome(tt.outcome)
+			sim.SetCallbackDelay(0)
+			if !tt.callback {
+				sim.SetCallbackDelay(100 * time.Millisecond)
+			}
+
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+			outcome, err := c.STKPushAndWait(ctx, "passkey", req)
+			if err != nil {
+				t.Fatalf("expecting the outcome of the stk push but got: %v", err)
+			}
+			if outcome.Status != tt.status || outcome.ResultCode != tt.code || outcome.CheckoutRequestID == "" {
+				t.Fatalf("unexpected outcome %+v", outcome)
+			}
+			if (outcome.Callback != nil) != tt.callback {
+				t.Fatalf("expecting a callback %v but got %+v", tt.callback, outcome)
+			}
+			if tt.status == mpesasdk.STKPushPaid && tt.callback && outcome.Receipt == "" {
+				t.Fatalf("expecting the receipt of the payment")
+			}
+			if state, _ := payments.State(outcome.CheckoutRequestID); tt.status == mpesasdk.STKPushPaid && state != tracker.Succeeded {
+				t.Fatalf("expecting the tracked payment to succeed but got %v", state)
+			}
+		})
+	}
+
+	if sim.RequestsTo(mpesatest.STKPushQueryPath) < 2 {
+		t.Fatalf("expecting the status to be queried until final but got %v queries", sim.RequestsTo(mpesatest.STKPushQueryPath))
+	}
+}
+
+func TestSTKPushAndWaitQueriesFromTheStartWithoutTracker(t *testing.T) {
+	sim := mpesatest.NewServer()
+	defer sim.Close()
+
+	c, err := sim.NewClient(mpesasdk.WithDefaultShortCode(554433), mpesasdk.WithSTKPushPolling(time.Hour, 20*time.Millisecond))
+	if err != nil {
+		t.Fatalf("failed to create client: %v", err)
+	}
+
+	sim.SetOutcome(mpesatest.OutcomeCancelledByUser)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	outcome, err := c.STKPushAndWait(ctx, "passkey", c2b.STKPushPaymentRequest{
+		TransactionType:  common.CustomerPayBillOnlineTransaction,
+		Amount:           10,
+		PartyA:           "251700000000",
+		PhoneNumber:      "251700000000",
+		CallBackURL:      "https://example.com/callback",
+		AccountReference: "INV-1",
+		TransactionDesc:  "Payment",
+	})
+	if err != nil || outcome.Status != mpesasdk.STKPushCancelled || outcome.Callback != nil {
+		t.Fatalf("expecting the queried outcome but got %+v: %v", outcome, err)
+	}
+}
+
+func TestPhoneNumbersAreNormalized(t *testing.T) {
+	sim := mpesatest.NewServer()
+	defer sim.Close()
+	sim.SetOutcome(mpesatest.OutcomeNoCallback)
+
+	client, err := sim.NewClient(
+		mpesasdk.WithDefaultShortCode(554433),
+		mpesasdk.WithPasskey("passkey"),
+		mpesasdk.WithMSISDNProfile(msisdn.Kenya),
+	)
+	if err != nil {
+		t.Fatalf("failed to create client: %v", err)
+	}
+
+	_, err = client.STKPushPaymentRequest("", c2b.STKPushPaymentRequest{
+		TransactionType:  common.CustomerPayBillOnlineTransaction,
+		Amount:           10,
+		PartyA:           "+254 712-345-678",
+		PhoneNumber:      "0712 345 678",
+		CallBackURL:      "https://example.com/callback",
+		AccountReference: "INV-1",
+		TransactionDesc:  "Payment",
+	})
+	if err != nil {
+		t.Fatalf("expecting stk push to be accepted but got: %v", err)
+	}
+
+	requests := sim.Requests()
+	sent := c2b.STKPushPaymentRequest{}
+	json.Unmarshal(requests[len(requests)-1].Body, &sent)
+	if sent.PartyA != "254712345678" || sent.PhoneNumber != "254712345678" {
+		t.Fatalf("expecting the phone numbers to be normalized but got %q and %q", sent.PartyA, sent.PhoneNumber)
+	}
+}
+
+func TestSecurityCredentialIsFilledFromInitiatorPassword(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "mpesa test"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, _ := x509.ParseCertificate(der)
+
+	sim := mpesatest.NewServer()
+	defer sim.Close()
+	sim.SetOutcome(mpesatest.OutcomeNoCallback)
+
+	client, err := sim.NewClient(
+		mpesasdk.WithDefaultShortCode(554433),
+		mpesasdk.WithInitiator("testapi"),
+		mpesasdk.WithInitiatorPassword("initiator-password"),
+		mpesasdk.WithCertificate(cert),
+	)
+	if err != nil {
+		t.Fatalf("failed to create client: %v", err)
+	}
+
+	_, err = client.MakeB2CPaymentRequest(b2c.B2CRequest{
+		CommandID:       common.BusinessPaymentCommand,
+		Amount:          10,
+		PartyB:          251700000000,
+		Remarks:         "Payout",
+		QueueTimeOutURL: "https://example.com/timeout",
+		ResultURL:       "https://example.com/result",
+	})
+	if err != nil {
+		t.Fatalf("expecting b2c to be accepted but got: %v", err)
+	}
+
+	requests := sim.Requests()
+	sent := b2c.B2CRequest{}
+	if err := json.Unmarshal(requests[len(requests)-1].Body, &sent); err != nil {
+		t.Fatalf("failed to decode the sent request: %v", err)
+	}
+
+	encrypted, _ := base64.StdEncoding.DecodeString(sent.SecurityCredential)
+	password, err := rsa.DecryptPKCS1v15(rand.Reader, key, encrypted)
+	if err != nil || string(password) != "initiator-password" {
+		t.Fatalf("expecting the encrypted initiator password but got %q (%v)", password, err)
+	}
+}
+
+func TestB2CIsSubmittedOncePerOriginatorConversationID(t *testing.T) {
+	sim := mpesatest.NewServer()
+	defer sim.Close()
+	sim.SetOutcome(mpesatest.OutcomeNoCallback)
+
+	client, err := sim.NewClient(
+		mpesasdk.WithDefaultShortCode(554433),
+		mpesasdk.WithInitiator("testapi"),
+		mpesasdk.WithIdempotencyStore(idempotency.NewMemoryStore()),
+	)
+	if err != nil {
+		t.Fatalf("failed to create client: %v", err)
+	}
+
+	req := b2c.B2CRequest{
+		SecurityCredential: "credential",
+		CommandID:          common.BusinessPaymentCommand,
+		Amount:             10,
+		PartyB:             251700000000,
+		Remarks:            "Payout",
+		QueueTimeOutURL:    "https://example.com/timeout",
+		ResultURL:          "https://example.com/result",
+	}
+
+	// A rejected submission can be submitted again with the same key
+	sim.Fail(mpesatest.B2CPath, mpesatest.Failure{Status: http.StatusBadRequest, ErrorCode: "400.002.02", ErrorMessage: "Bad Request", Times: 1})
+	req.OriginatorConversationID = "payout-1"
+	if _, err := client.MakeB2CPaymentRequest(req); err == nil {
+		t.Fatalf("expecting the failed submission to return an error")
+	}
+
+	first, err := client.MakeB2CPaymentRequest(req)
+	if err != nil {
+		t.Fatalf("expecting b2c to be accepted but got: %v", err)
+	}
+
+	second, err := client.MakeB2CPaymentRequest(req)
+	if err != nil || second != first {
+		t.Fatalf("expecting the original acknowledgement %+v but got %+v (%v)", first, second, err)
+	}
+
+	if sent := sim.RequestsTo(mpesatest.B2CPath); sent != 2 {
+		t.Fatalf("expecting the payment to be sent once after the rejection but got %d requests", sent)
+	}
+
+	// An empty key is generated, so independent payments are all submitted
+	req.OriginatorConversationID = ""
+	client.MakeB2CPaymentRequest(req)
+	client.MakeB2CPaymentRequest(req)
+	if sent := sim.RequestsTo(mpesatest.B2CPath); sent != 4 {
+		t.Fatalf("expecting payments without a key to be submitted but got %d requests", sent)
+	}
+
+	requests := sim.Requests()
+	sent := b2c.B2CRequest{}
+	json.Unmarshal(requests[len(requests)-1].Body, &sent)
+	if len(sent.OriginatorConversationID) != 36 {
+		t.Fatalf("expecting a generated UUID but got %q", sent.OriginatorConversationID)
+	}
+}
+
+func TestB2CNotSentCanBeSubmittedAgain(t *testing.T) {
+	tests := []struct {
+		name  string
+		path  string
+		err   error
+		times int
+	}{
+		{"token fetch failure", mpesatest.TokenPath, errors.New("connection reset by peer"), 1},
+		{"connection refused", mpesatest.B2CPath, &net.OpError{Op: "dial", Net: "tcp", Err: syscall.ECONNREFUSED}, 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sim := mpesatest.NewServer()
+			defer sim.Close()
+			sim.SetOutcome(mpesatest.OutcomeNoCallback)
+
+			transport := &failingTransport{}
+			api, err := sim.NewClient(
+				mpesasdk.WithHTTPClient(&http.Client{Transport: transport}),
+				mpesasdk.WithDefaultShortCode(554433),
+				mpesasdk.WithInitiator("testapi"),
+				mpesasdk.WithIdempotencyStore(idempotency.NewMemoryStore()),
+				mpesasdk.WithOperationRetryPolicy(common.B2COperation, &client.BackoffRetryPolicy{
+					MaxAttempts: 2,
+					BaseDelay:   time.Millisecond,
+					Retryable:   client.SafeRetryable,
+				}),
+			)
+			if err != nil {
+				t.Fatalf("failed to create client: %v", err)
+			}
+
+			req := b2c.B2CRequest{
+				SecurityCredential:       "credential",
+				CommandID:                common.BusinessPaymentCommand,
+				Amount:                   10,
+				PartyB:                   251700000000,
+				Remarks:                  "Payout",
+				QueueTimeOutURL:          "https://example.com/timeout",
+				ResultURL:                "https://example.com/result",
+				OriginatorConversationID: "payout-1",
+			}
+
+			transport.failNext(tt.path, tt.err, tt.times)
+			if _, err := api.MakeB2CPaymentRequest(req); err == nil || errors.Is(err, idempotency.ErrInProgress) {
+				t.Fatalf("expecting the submission to fail but got: %v", err)
+			}
+
+			if _, err := api.MakeB2CPaymentRequest(req); err != nil {
+				t.Fatalf("expecting the payment to be submitted again but got: %v", err)
+			}
+			if sent := sim.RequestsTo(mpesatest.B2CPath); sent != 1 {
+				t.Fatalf("expecting the payment to reach the API once but got %d requests", sent)
+			}
+		})
+	}
+}
+
+func TestTimedOutB2CIsBlockedUntilTheReservationExpires(t *testing.T) {
+	sim := mpesatest.NewServer()
+	defer sim.Close()
+	sim.SetOutcome(mpesatest.OutcomeNoCallback)
+
+	api, err := sim.NewClient(
+		mpesasdk.WithTimeout(200*time.Millisecond),
+		mpesasdk.WithDefaultShortCode(554433),
+		mpesasdk.WithInitiator("testapi"),
+		mpesasdk.WithIdempotencyStore(idempotency.NewMemoryStore()),
+		mpesasdk.WithIdempotencyTTL(time.Second),
+	)
+	if err != nil {
+		t.Fatalf("failed to create client: %v", err)
+	}
+
+	req := b2c.B2CRequest{
+		SecurityCredential:       "credential",
+		CommandID:                common.BusinessPaymentCommand,
+		Amount:                   10,
+		PartyB:                   251700000000,
+		Remarks:                  "Payout",
+		QueueTimeOutURL:          "https://example.com/timeout",
+		ResultURL:                "https://example.com/result",
+		OriginatorConversationID: "payout-0",
+	}
+
+	// Fetch the token first, so that only the payment times out
+	if _, err := api.MakeB2CPaymentRequest(req); err != nil {
+		t.Fatalf("expecting b2c to be accepted but got: %v", err)
+	}
+
+	req.OriginatorConversationID = "payout-1"
+	sim.SetDelay(400 * time.Millisecond)
+	if _, err := api.MakeB2CPaymentRequest(req); !errors.Is(err, sdkError.ErrTimeout) {
+		t.Fatalf("expecting the submission to time out but got: %v", err)
+	}
+	sim.SetDelay(0)
+
+	if _, err := api.MakeB2CPaymentRequest(req); !errors.Is(err, idempotency.ErrInProgress) {
+		t.Fatalf("expecting the timed out payment to be blocked but got: %v", err)
+	}
+
+	time.Sleep(time.Second)
+	if _, err := api.MakeB2CPaymentRequest(req); err != nil {
+		t.Fatalf("expecting the payment to be submitted once the reservation expired but got: %v", err)
+	}
+	if sent := sim.RequestsTo(mpesatest.B2CPath); sent != 3 {
+		t.Fatalf("expecting 3 payment requests but got %d", sent)
+	}
+}
+
+func TestB2CGatewayErrorIsBlockedUntilTheReservationExpires(t *testing.T) {
+	sim := mpesatest.NewServer()
+	defer sim.Close()
+	sim.SetOutcome(mpesatest.OutcomeNoCallback)
+
+	api, err := sim.NewClient(
+		mpesasdk.WithDefaultShortCode(554433),
+		mpesasdk.WithInitiator("testapi"),
+		mpesasdk.WithIdempotencyStore(idempotency.NewMemoryStore()),
+		mpesasdk.WithOperationRetryPolicy(common.B2COperation, client.NewTimeoutRetryPolicy(0)),
+	)
+	if err != nil {
+		t.Fatalf("failed to create client: %v", err)
+	}
+
+	req := b2c.B2CRequest{
+		SecurityCredential: "credential",
+		CommandID:          common.BusinessPaymentCommand,
+		Amount:             10,
+		PartyB:             251700000000,
+		Remarks:            "Payout",
+		QueueTimeOutURL:    "https://example.com/timeout",
+		ResultURL:          "https://example.com/result",
+	}
+
+	// The gateway may have forwarded the payment, its generated key stays reserved
+	sim.Fail(mpesatest.B2CPath, mpesatest.Failure{Status: http.StatusGatewayTimeout, ErrorCode: "504.001.01", ErrorMessage: "Gateway Timeout", Times: 1})
+	response, err := api.MakeB2CPaymentRequest(req)
+	if err == nil {
+		t.Fatalf("expecting the submission to fail")
+	}
+	if len(response.OriginatorConversatonId) != 36 {
+		t.Fatalf("expecting the generated OriginatorConversationID to be returned but got %q", response.OriginatorConversatonId)
+	}
+
+	req.OriginatorConversationID = response.OriginatorConversatonId
+	if _, err := api.MakeB2CPaymentRequest(req); !errors.Is(err, idempotency.ErrInProgress) {
+		t.Fatalf("expecting the payment to be blocked after the gateway error but got: %v", err)
+	}
+	if sent := sim.RequestsTo(mpesatest.B2CPath); sent != 1 {
+		t.Fatalf("expecting the payment to be sent once but got %d requests", sent)
+	}
+}
+
+func TestAPIErrorsAreInspectable(t *testing.T) {
+	sim := mpesatest.NewServer()
+	defer sim.Close()
+
+	api, err := sim.NewClient(
+		mpesasdk.WithDefaultShortCode(554433),
+		mpesasdk.WithRetryPolicy(client.NewTimeoutRetryPolicy(0)),
+	)
+	if err != nil {
+		t.Fatalf("failed to create client: %v", err)
+	}
+
+	req := account.AccountBalanceRequest{
+		Initiator:          "testapi",
+		SecurityCredential: "credential",
+		IdentifierType:     common.ShortCodeIdentifierType,
+		Remarks:            "Balance",
+		QueueTimeOutURL:    "https://example.com/timeout",
+		ResultURL:          "https://example.com/result",
+	}
+
+	sim.Fail(mpesatest.AccountBalancePath, mpesatest.Failure{Status: http.StatusBadRequest, ErrorCode: "400.002.02", ErrorMessage: "Bad Request - Invalid PartyA", Times: 1})
+	_, err = api.AccountBalance(req)
+
+	var sdkErr *sdkError.SDKError
+	if !errors.As(err, &sdkErr) || !errors.Is(err, sdkError.ErrValidation) {
+		t.Fatalf("expecting a validation SDKError but got %v", err)
+	}
+	if sdkErr.Code() != "400.002.02" || sdkErr.HTTPStatus() != http.StatusBadRequest || sdkErr.RequestID() == "" || len(sdkErr.RawBody()) == 0 {
+		t.Fatalf("expecting the details of the response but got %q %v %q %q", sdkErr.Code(), sdkErr.HTTPStatus(), sdkErr.RequestID(), sdkErr.RawBody())
+	}
+
+	sim.Fail(mpesatest.AccountBalancePath, mpesatest.Failure{Status: http.StatusServiceUnavailable, ErrorCode: "503.001.01", ErrorMessage: "Service Unavailable", Times: 1})
+	_, err = api.AccountBalance(req)
+	if !errors.As(err, &sdkErr) || !errors.Is(err, sdkError.ErrServer) || !sdkErr.Retryable() {
+		t.Fatalf("expecting a retryable server error but got %v", err)
+	}
+}
@@ -0,0 +1,698 @@
+// Package mpesatest provides a local M-Pesa simulator for offline integration testing.
+//
+// The simulator is an httptest.Server emulating the token, C2B, STK push, STK push query, B2C,
+// transaction status, account balance and reversal endpoints. Like the real API it acknowledges requests
+// synchronously and then posts the outcome to the callback URLs found in the requests.
+// Its behaviour can be scripted to force error codes, slow responses or specific outcomes
+// such as a customer cancelling the PIN prompt or insufficient funds.
+//
+// Example:
+//
+//	sim := mpesatest.NewServer()
+//	defer sim.Close()
+//
+//	client, _ := sim.NewClient()
+//	sim.SetOutcome(mpesatest.OutcomeCancelledByUser)
+//	res, err := client.STKPushPaymentRequest("passkey", req)
+package mpesatest
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/coleYab/mpesasdk"
+	"github.com/coleYab/mpesasdk/common"
+	"github.com/coleYab/mpesasdk/service"
+	"github.com/coleYab/mpesasdk/utils"
+)
+
+// Paths of the endpoints emulated by the simulator.
+const (
+	TokenPath               = "/v1/token/generate"
+	RegisterURLPath         = "/v1/c2b-register-url/register"
+	SimulateC2BPath         = "/mpesa/b2c/simulatetransaction/v1/request"
+	STKPushPath             = "/mpesa/stkpush/v1/processrequest"
+	STKPushQueryPath        = "/mpesa/stkpushquery/v1/query"
+	B2CPath                 = "/mpesa/b2c/v2/paymentrequest"
+	TransactionStatusPath   = "/mpesa/transactionstatus/v1/query"
+	AccountBalancePath      = "/mpesa/accountbalance/v1/query"
+	TransactionReversalPath = "/mpesa/reversal/v1/request"
+)
+
+// Credentials accepted by the simulator's token endpoint.
+const (
+	ConsumerKey    = "mpesatest-consumer-key"
+	ConsumerSecret = "mpesatest-consumer-secret"
+)
+
+// Outcome is the final result the simulator reports in the asynchronous callbacks.
+type Outcome int
+
+const (
+	OutcomeSuccess Outcome = iota
+	OutcomeCancelledByUser
+	OutcomeInsufficientFunds
+	// OutcomeTimeout reports STK pushes as timed out, and posts a queue timeout to the
+	// QueueTimeOutURL of asynchronous requests instead of a Result to their ResultURL.
+	OutcomeTimeout
+	OutcomeWrongPIN
+	// OutcomeNoCallback acknowledges requests but never posts a callback. STK pushes are
+	// reported as successful by the STK push query endpoint.
+	OutcomeNoCallback
+)
+
+// resultCode returns the result code and description M-Pesa sends for the outcome.
+func (o Outcome) resultCode() (int, string) {
+	switch o {
+	case OutcomeCancelledByUser:
+		return 1032, "Request cancelled by user"
+	case OutcomeInsufficientFunds:
+		return 1, "The balance is insufficient for the transaction"
+	case OutcomeTimeout:
+		return 1037, "DS timeout user cannot be reached"
+	case OutcomeWrongPIN:
+		return 2001, "The initiator information is invalid."
+	}
+	return 0, "The service request is processed successfully."
+}
+
+// Failure describes an error response the simulator returns instead of processing a request.
+//
+// Fields:
+//   - Status: The HTTP status code of the response.
+//   - ErrorCode: The `errorCode` of the error body (e.g. "500.003.1001").
+//   - ErrorMessage: The `errorMessage` of the error body.
+//   - Times: How many requests fail, 0 makes every request fail until Reset is called.
+type Failure struct {
+	Status       int
+	ErrorCode    string
+	ErrorMessage string
+	Times        int
+}
+
+// Request is a request received by the simulator.
+type Request struct {
+	Method string
+	Path   string
+	Header http.Header
+	Body   []byte
+}
+
+// Server is a scriptable M-Pesa simulator.
+type Server struct {
+	server         *httptest.Server
+	callbackClient *http.Client
+
+	mu            sync.Mutex
+	outcome       Outcome
+	delay         time.Duration
+	callbackDelay time.Duration
+	failures      map[string]*Failure
+	requests      []Request
+	registrations map[string]registration
+	stkPushes     map[string]stkPush
+	sequence      int
+
+	callbacks sync.WaitGroup
+}
+
+type registration struct {
+	ValidationURL   string
+	ConfirmationURL string
+}
+
+// stkPush is an STK push answered by the STK push query endpoint.
+type stkPush struct {
+	merchantRequestID string
+	resultCode        int
+	resultDesc        string
+	completesAt       time.Time // the customer is still entering the PIN until then
+}
+
+// NewServer starts a new simulator. Callers should Close it when done.
+//
+// Callbacks are posted with an HTTP client that does not verify TLS certificates, so that
+// httptest.NewTLSServer can be used to receive them (the SDK only accepts https callback URLs).
+func NewServer() *Server {
+	s := &Server{
+		failures:      map[string]*Failure{},
+		registrations: map[string]registration{},
+		stkPushes:     map[string]stkPush{},
+		callbackClient: &http.Client{
+			Timeout: 10 * time.Second,
+			Transport: &http.Transport{
+				TLSClientConfig: &tls.Config{InsecureSkipVerify: true},
+			},
+		},
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc(TokenPath, s.handleToken)
+	mux.HandleFunc(RegisterURLPath, s.handleRegisterURL)
+	mux.HandleFunc(SimulateC2BPath, s.handleSimulateC2B)
+	mux.HandleFunc(STKPushPath, s.handleSTKPush)
+	mux.HandleFunc(STKPushQueryPath, s.handleSTKPushQuery)
+	mux.HandleFunc(B2CPath, s.handleResultRequest)
+	mux.HandleFunc(TransactionStatusPath, s.handleResultRequest)
+	mux.HandleFunc(AccountBalancePath, s.handleResultRequest)
+	mux.HandleFunc(TransactionReversalPath, s.handleResultRequest)
+
+	s.server = httptest.NewServer(s.intercept(mux))
+	return s
+}
+
+// URL returns the base URL of the simulator.
+func (s *Server) URL() string {
+	return s.server.URL
+}
+
+// Close waits for pending callbacks and shuts the simulator down.
+func (s *Server) Close() {
+	s.callbacks.Wait()
+	s.server.Close()
+}
+
+// NewClient returns an MpesaClient talking to the simulator with the simulator's credentials.
+// The options are applied after the ones pointing the client at the simulator.
+func (s *Server) NewClient(opts ...mpesasdk.Option) (*mpesasdk.MpesaClient, error) {
+	defaults := []mpesasdk.Option{
+		mpesasdk.WithEnvironment(common.SANDBOX),
+		mpesasdk.WithBaseURL(s.URL()),
+		mpesasdk.WithLogger(service.NewStdLogger(service.ERROR)),
+	}
+	return mpesasdk.New(ConsumerKey, ConsumerSecret, append(defaults, opts...)...)
+}
+
+// SetCallbackClient replaces the HTTP client used to post callbacks.
+func (s *Server) SetCallbackClient(client *http.Client) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.callbackClient = client
+}
+
+// SetOutcome sets the outcome reported by the callbacks of subsequent requests.
+func (s *Server) SetOutcome(outcome Outcome) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.outcome = outcome
+}
+
+// SetDelay delays every response of the simulator by d.
+func (s *Server) SetDelay(d time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.delay = d
+}
+
+// SetCallbackDelay delays every callback of the simulator by d after the acknowledgement.
+func (s *Server) SetCallbackDelay(d time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.callbackDelay = d
+}
+
+// Fail makes requests to path fail with the given failure.
+func (s *Server) Fail(path string, failure Failure) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if failure.Status == 0 {
+		failure.Status = http.StatusInternalServerError
+	}
+	s.failures[path] = &failure
+}
+
+// Reset clears the scripted failures, delays and outcome.
+func (s *Server) Reset() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.failures = map[string]*Failure{}
+	s.delay = 0
+	s.callbackDelay = 0
+	s.outcome = OutcomeSuccess
+}
+
+// Requests returns the requests received by the simulator so far.
+func (s *Server) Requests() []Request {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]Request(nil), s.requests...)
+}
+
+// RequestsTo returns the number of requests received on path.
+func (s *Server) RequestsTo(path string) int {
+	count := 0
+	for _, req := range s.Requests() {
+		if req.Path == path {
+			count++
+		}
+	}
+	return count
+}
+
+// intercept records the requests and applies the scripted delays and failures.
+func (s *Server) intercept(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		r.Body = io.NopCloser(bytes.NewReader(body))
+
+		s.mu.Lock()
+		s.requests = append(s.requests, Request{Method: r.Method, Path: r.URL.Path, Header: r.Header.Clone(), Body: body})
+		delay := s.delay
+		failure := s.failures[r.URL.Path]
+		if failure != nil && failure.Times > 0 {
+			failure.Times--
+			if failure.Times == 0 {
+				delete(s.failures, r.URL.Path)
+			}
+		}
+		s.mu.Unlock()
+
+		if delay > 0 {
+			select {
+			case <-time.After(delay):
+			case <-r.Context().Done():
+				return
+			}
+		}
+
+		if failure != nil {
+			utils.WriteJSON(w, failure.Status, common.MpesaErrorResponse{
+				RequestId:    s.nextID("req"),
+				ErrorCode:    failure.ErrorCode,
+				ErrorMessage: failure.ErrorMessage,
+			})
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+func (s *Server) handleToken(w http.ResponseWriter, r *http.Request) {
+	key, secret, ok := r.BasicAuth()
+	if !ok || key != ConsumerKey || secret != ConsumerSecret {
+		utils.WriteJSON(w, http.StatusBadRequest, map[string]string{
+			"resultCode": "999991",
+			"resultDesc": "Invalid client id passed",
+		})
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, map[string]string{
+		"access_token": s.nextID("token"),
+		"token_type":   "Bearer",
+		"expires_in":   "3599",
+	})
+}
+
+func (s *Server) handleRegisterURL(w http.ResponseWriter, r *http.Request) {
+	req := struct {
+		ShortCode       string
+		ConfirmationURL string
+		ValidationURL   string
+	}{}
+	if !decodeRequest(w, r, &req) {
+		return
+	}
+
+	s.mu.Lock()
+	s.registrations[req.ShortCode] = registration{ValidationURL: req.ValidationURL, ConfirmationURL: req.ConfirmationURL}
+	s.mu.Unlock()
+
+	utils.WriteJSON(w, http.StatusOK, map[string]interface{}{
+		"header": map[string]string{
+			"responseCode":    "200",
+			"responseMessage": "Request processed successfully",
+			"customerMessage": "Request processed successfully",
+		},
+	})
+}
+
+func (s *Server) handleSimulateC2B(w http.ResponseWriter, r *http.Request) {
+	if !s.authorized(w, r) {
+		return
+	}
+
+	req := struct {
+		CommandID     string
+		Amount        uint64
+		Msisdn        string
+		BillRefNumber string
+		ShortCode     string
+	}{}
+	if !decodeRequest(w, r, &req) {
+		return
+	}
+
+	conversationID := s.nextID("AG")
+	utils.WriteJSON(w, http.StatusOK, common.MpesaSuccessResponse{
+		ConversationID:          conversationID,
+		OriginatorConversatonId: s.nextID("OC"),
+		ResponseDescription:     "Accept the service request successfully.",
+		ResponseCode:            "0",
+	})
+
+	s.mu.Lock()
+	urls, ok := s.registrations[req.ShortCode]
+	s.mu.Unlock()
+	if !ok {
+		return
+	}
+
+	payment := map[string]string{
+		"TransactionType":   "Pay Bill",
+		"TransID":           s.nextTransactionID("TX"),
+		"TransTime":         time.Now().Format("20060102150405"),
+		"TransAmount":       fmt.Sprint(req.Amount),
+		"BusinessShortCode": req.ShortCode,
+		"BillRefNumber":     req.BillRefNumber,
+		"MSISDN":            req.Msisdn,
+	}
+	s.postCallbacks(func(ctx context.Context) {
+		if urls.ValidationURL != "" {
+			ack := common.CallbackResponse{}
+			if err := s.post(ctx, urls.ValidationURL, payment, &ack); err != nil || ack.ResultCode != "0" {
+				return
+			}
+		}
+		s.post(ctx, urls.ConfirmationURL, payment, nil)
+	})
+}
+
+func (s *Server) handleSTKPush(w http.ResponseWriter, r *http.Request) {
+	if !s.authorized(w, r) {
+		return
+	}
+
+	req := struct {
+		Amount      uint64
+		PhoneNumber string
+		CallBackURL string
+	}{}
+	if !decodeRequest(w, r, &req) {
+		return
+	}
+
+	merchantRequestID := s.nextID("MR")
+	checkoutRequestID := s.nextID("ws_CO")
+	utils.WriteJSON(w, http.StatusOK, map[string]string{
+		"MerchantRequestID":   merchantRequestID,
+		"CheckoutRequestID":   checkoutRequestID,
+		"ResponseCode":        "0",
+		"ResponseDescription": "Success. Request accepted for processing",
+		"CustomerMessage":     "Success. Request accepted for processing",
+	})
+
+	outcome := s.currentOutcome()
+	code, description := outcome.resultCode()
+	s.mu.Lock()
+	s.stkPushes[checkoutRequestID] = stkPush{
+		merchantRequestID: merchantRequestID,
+		resultCode:        code,
+		resultDesc:        description,
+		completesAt:       time.Now().Add(s.callbackDelay),
+	}
+	s.mu.Unlock()
+	if outcome == OutcomeNoCallback {
+		return
+	}
+
+	callback := map[string]interface{}{
+		"MerchantRequestID": merchantRequestID,
+		"CheckoutRequestID": checkoutRequestID,
+		"ResultCode":        code,
+		"ResultDesc":        description,
+	}
+	if code == 0 {
+		callback["CallbackMetadata"] = map[string]interface{}{
+			"Item": []map[string]interface{}{
+				{"Name": "Amount", "Value": req.Amount},
+				{"Name": "MpesaReceiptNumber", "Value": s.nextTransactionID("RCPT")},
+				{"Name": "TransactionDate", "Value": number(time.Now().Format("20060102150405"))},
+				{"Name": "PhoneNumber", "Value": number(req.PhoneNumber)},
+			},
+		}
+	}
+
+	s.postCallbacks(func(ctx context.Context) {
+		s.post(ctx, req.CallBackURL, map[string]interface{}{
+			"Body": map[string]interface{}{"stkCallback": callback},
+		}, nil)
+	})
+}
+
+// handleSTKPushQuery reports the outcome of an STK push once its callback is due, and that it is
+// being processed before.
+func (s *Server) handleSTKPushQuery(w http.ResponseWriter, r *http.Request) {
+	if !s.authorized(w, r) {
+		return
+	}
+
+	req := struct {
+		CheckoutRequestID string
+	}{}
+	if !decodeRequest(w, r, &req) {
+		return
+	}
+
+	s.mu.Lock()
+	push, ok := s.stkPushes[req.CheckoutRequestID]
+	s.mu.Unlock()
+	switch {
+	case !ok:
+		utils.WriteJSON(w, http.StatusBadRequest, common.MpesaErrorResponse{
+			RequestId:    s.nextID("req"),
+			ErrorCode:    "400.002.02",
+			ErrorMessage: "Bad Request - Invalid CheckoutRequestID",
+		})
+		return
+	case time.Now().Before(push.completesAt):
+		utils.WriteJSON(w, http.StatusInternalServerError, common.MpesaErrorResponse{
+			RequestId:    s.nextID("req"),
+			ErrorCode:    "500.001.1001",
+			ErrorMessage: "The transaction is being processed",
+		})
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, map[string]string{
+		"ResponseCode":        "0",
+		"ResponseDescription": "The service request has been accepted successfully",
+		"MerchantRequestID":   push.merchantRequestID,
+		"CheckoutRequestID":   req.CheckoutRequestID,
+		"ResultCode":          strconv.Itoa(push.resultCode),
+		"ResultDesc":          push.resultDesc,
+	})
+}
+
+// handleResultRequest serves the asynchronous APIs answering on the ResultURL.
+func (s *Server) handleResultRequest(w http.ResponseWriter, r *http.Request) {
+	if !s.authorized(w, r) {
+		return
+	}
+
+	req := struct {
+		Amount                   uint64
+		ResultURL                string
+		QueueTimeOutURL          string
+		OriginatorConversationID string
+		TransactionID            string
+	}{}
+	if !decodeRequest(w, r, &req) {
+		return
+	}
+
+	if req.OriginatorConversationID == "" {
+		req.OriginatorConversationID = s.nextID("OC")
+	}
+
+	conversationID := s.nextID("AG")
+	utils.WriteJSON(w, http.StatusOK, common.MpesaSuccessResponse{
+		ConversationID:          conversationID,
+		OriginatorConversatonId: req.OriginatorConversationID,
+		ResponseDescription:     "Accept the service request successfully.",
+		ResponseCode:            "0",
+	})
+
+	outcome := s.currentOutcome()
+	if outcome == OutcomeNoCallback {
+		return
+	}
+
+	code, description := outcome.resultCode()
+	transactionID := s.nextTransactionID("TX")
+	result := map[string]interface{}{
+		"ResultType":               0,
+		"ResultCode":               code,
+		"ResultDesc":               description,
+		"OriginatorConversationID": req.OriginatorConversationID,
+		"ConversationID":           conversationID,
+		"TransactionID":            transactionID,
+		"ReferenceData": map[string]interface{}{
+			"ReferenceItem": map[string]string{"Key": "QueueTimeoutURL", "Value": req.QueueTimeOutURL},
+		},
+	}
+	if code == 0 {
+		result["ResultParameters"] = map[string]interface{}{
+			"ResultParameter": resultParameters(r.URL.Path, req.Amount, transactionID, req.TransactionID),
+		}
+	}
+
+	url := req.ResultURL
+	if outcome == OutcomeTimeout {
+		url = req.QueueTimeOutURL
+	}
+	s.postCallbacks(func(ctx context.Context) {
+		s.post(ctx, url, map[string]interface{}{"Result": result}, nil)
+	})
+}
+
+// resultParameters returns the result parameters M-Pesa sends for a successful request on path.
+func resultParameters(path string, amount uint64, transactionID, originalTransactionID string) []map[string]interface{} {
+	completed := time.Now().Format("02.01.2006 15:04:05")
+	switch path {
+	case B2CPath:
+		return []map[string]interface{}{
+			{"Key": "TransactionAmount", "Value": amount},
+			{"Key": "TransactionReceipt", "Value": transactionID},
+			{"Key": "B2CRecipientIsRegisteredCustomer", "Value": "Y"},
+			{"Key": "ReceiverPartyPublicName", "Value": "251700000000 - Test Customer"},
+			{"Key": "TransactionCompletedDateTime", "Value": completed},
+		}
+	case TransactionStatusPath:
+		return []map[string]interface{}{
+			{"Key": "ReceiptNo", "Value": originalTransactionID},
+			{"Key": "TransactionStatus", "Value": "Completed"},
+			{"Key": "FinalisedTime", "Value": number(time.Now().Format("20060102150405"))},
+		}
+	case AccountBalancePath:
+		return []map[string]interface{}{
+			{"Key": "AccountBalance", "Value": "Working Account|ETB|700000.00|700000.00|0.00|0.00&Utility Account|ETB|228037.00|228037.00|0.00|0.00"},
+			{"Key": "BOCompletedTime", "Value": number(time.Now().Format("20060102150405"))},
+		}
+	case TransactionReversalPath:
+		return []map[string]interface{}{
+			{"Key": "OriginalTransactionID", "Value": originalTransactionID},
+			{"Key": "Amount", "Value": amount},
+			{"Key": "TransCompletedTime", "Value": number(time.Now().Format("20060102150405"))},
+		}
+	}
+	return nil
+}
+
+// authorized checks the bearer token of the request.
+func (s *Server) authorized(w http.ResponseWriter, r *http.Request) bool {
+	if len(r.Header.Get("Authorization")) > len("Bearer ") {
+		return true
+	}
+
+	utils.WriteJSON(w, http.StatusUnauthorized, common.MpesaErrorResponse{
+		RequestId:    s.nextID("req"),
+		ErrorCode:    "404.001.03",
+		ErrorMessage: "Invalid Access Token",
+	})
+	return false
+}
+
+func (s *Server) currentOutcome() Outcome {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.outcome
+}
+
+// nextID returns a unique identifier with the given prefix.
+func (s *Server) nextID(prefix string) string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.sequence++
+	return fmt.Sprintf("%s_%d_%06d", prefix, time.Now().Unix(), s.sequence)
+}
+
+// nextTransactionID returns a unique transaction identifier, alphanumeric like the ones of
+// M-Pesa so that it can be used in transaction status and reversal requests.
+func (s *Server) nextTransactionID(prefix string) string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.sequence++
+	return fmt.Sprintf("%s%d%06d", prefix, time.Now().Unix(), s.sequence)
+}
+
+// postCallbacks runs fn in the background after the configured callback delay.
+func (s *Server) postCallbacks(fn func(ctx context.Context)) {
+	s.mu.Lock()
+	delay := s.callbackDelay
+	s.mu.Unlock()
+
+	s.callbacks.Add(1)
+	go func() {
+		defer s.callbacks.Done()
+		time.Sleep(delay)
+
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		fn(ctx)
+	}()
+}
+
+// post sends payload to url and decodes the acknowledgement into ack when it is not nil.
+func (s *Server) post(ctx context.Context, url string, payload interface{}, ack interface{}) error {
+	if url == "" {
+		return nil
+	}
+
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	s.mu.Lock()
+	client := s.callbackClient
+	s.mu.Unlock()
+
+	res, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	if ack != nil {
+		return json.NewDecoder(res.Body).Decode(ack)
+	}
+	return nil
+}
+
+func decodeRequest(w http.ResponseWriter, r *http.Request, v interface{}) bool {
+	if err := json.NewDecoder(r.Body).Decode(v); err != nil {
+		utils.WriteJSON(w, http.StatusBadRequest, common.MpesaErrorResponse{
+			ErrorCode:    "400.002.02",
+			ErrorMessage: "Bad Request - Invalid Body: " + err.Error(),
+		})
+		return false
+	}
+	return true
+}
+
+// number returns s as a JSON number when it is numeric, M-Pesa sends phone numbers and dates as numbers.
+func number(s string) interface{} {
+	if _, err := strconv.ParseUint(s, 10, 64); err == nil {
+		return json.Number(s)
+	}
+	return s
+}
@@ -0,0 +1,164 @@
+package mpesatest_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/coleYab/mpesasdk/b2c"
+	"github.com/coleYab/mpesasdk/c2b"
+	"github.com/coleYab/mpesasdk/common"
+	"github.com/coleYab/mpesasdk/mpesatest"
+	"github.com/coleYab/mpesasdk/results"
+)
+
+func TestSimulatorSTKPushCallback(t *testing.T) {
+	sim := mpesatest.NewServer()
+	defer sim.Close()
+
+	callbacks := make(chan *c2b.STKCallback, 1)
+	receiver := httptest.NewTLSServer(c2b.NewSTKCallbackHandler(func(ctx context.Context, cb *c2b.STKCallback) error {
+		callbacks <- cb
+		return nil
+	}))
+	defer receiver.Close()
+
+	client, err := sim.NewClient()
+	if err != nil {
+		t.Fatalf("failed to create client: %v", err)
+	}
+
+	sim.SetOutcome(mpesatest.OutcomeCancelledByUser)
+	res, err := client.STKPushPaymentRequest("passkey", c2b.STKPushPaymentRequest{
+		BusinessShortCode: 554433,
+		TransactionType:   common.CustomerPayBillOnlineTransaction,
+		Amount:            10,
+		PartyA:            "251700000000",
+		PartyB:            "554433",
+		PhoneNumber:       "251700000000",
+		CallBackURL:       receiver.URL + "/stk",
+		AccountReference:  "INV-1",
+		TransactionDesc:   "Payment",
+	})
+	if err != nil {
+		t.Fatalf("expecting stk push to be accepted but got: %v", err)
+	}
+
+	select {
+	case cb := <-callbacks:
+		if cb.CheckoutRequestID != res.CheckoutRequestID || cb.ResultCode != 1032 {
+			t.Fatalf("unexpected callback %+v", cb)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("no callback received")
+	}
+
+	if sim.RequestsTo(mpesatest.TokenPath) != 1 {
+		t.Fatalf("expecting a single token request but got %v", sim.RequestsTo(mpesatest.TokenPath))
+	}
+}
+
+func TestSimulatorB2CResult(t *testing.T) {
+	sim := mpesatest.NewServer()
+	defer sim.Close()
+
+	resultsReceived := make(chan *results.B2CResult, 1)
+	receiver := httptest.NewTLSServer(results.NewB2CResultHandler(func(ctx context.Context, r *results.B2CResult) error {
+		resultsReceived <- r
+		return nil
+	}))
+	defer receiver.Close()
+
+	client, err := sim.NewClient()
+	if err != nil {
+		t.Fatalf("failed to create client: %v", err)
+	}
+
+	request := b2c.B2CRequest{
+		InitiatorName:            "apiuser",
+		SecurityCredential:       "credential",
+		CommandID:                common.BusinessPaymentCommand,
+		Amount:                   100,
+		PartyA:                   600000,
+		PartyB:                   251700000000,
+		Remarks:                  "Payout",
+		QueueTimeOutURL:          receiver.URL + "/timeout",
+		ResultURL:                receiver.URL + "/result",
+		OriginatorConversationID: "payout-1",
+	}
+
+	sim.Fail(mpesatest.B2CPath, mpesatest.Failure{
+		Status:       http.StatusBadRequest,
+		ErrorCode:    "400.002.02",
+		ErrorMessage: "Bad Request - Invalid Amount",
+		Times:        1,
+	})
+	if _, err := client.MakeB2CPaymentRequest(request); err == nil {
+		t.Fatalf("expecting the scripted failure to be returned")
+	}
+
+	sim.SetOutcome(mpesatest.OutcomeInsufficientFunds)
+	if _, err := client.MakeB2CPaymentRequest(request); err != nil {
+		t.Fatalf("expecting b2c request to be accepted but got: %v", err)
+	}
+
+	select {
+	case r := <-resultsReceived:
+		if r.Successful() || r.ResultCode != "1" || r.OriginatorConversationID != "payout-1" {
+			t.Fatalf("unexpected result %+v", r.Result)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("no result received")
+	}
+}
+
+func TestSimulatorQueueTimeout(t *testing.T) {
+	sim := mpesatest.NewServer()
+	defer sim.Close()
+
+	timeouts := make(chan *results.QueueTimeout, 1)
+	mux := http.NewServeMux()
+	mux.Handle("/result", results.NewResultHandler(func(ctx context.Context, r *results.Result) error {
+		t.Errorf("unexpected result %+v", r)
+		return nil
+	}))
+	mux.Handle("/timeout", results.NewQueueTimeoutHandler(func(ctx context.Context, timeout *results.QueueTimeout) error {
+		timeouts <- timeout
+		return nil
+	}))
+	receiver := httptest.NewTLSServer(mux)
+	defer receiver.Close()
+
+	client, err := sim.NewClient()
+	if err != nil {
+		t.Fatalf("failed to create client: %v", err)
+	}
+
+	sim.SetOutcome(mpesatest.OutcomeTimeout)
+	_, err = client.MakeB2CPaymentRequest(b2c.B2CRequest{
+		InitiatorName:            "apiuser",
+		SecurityCredential:       "credential",
+		CommandID:                common.BusinessPaymentCommand,
+		Amount:                   100,
+		PartyA:                   600000,
+		PartyB:                   251700000000,
+		Remarks:                  "Payout",
+		QueueTimeOutURL:          receiver.URL + "/timeout",
+		ResultURL:                receiver.URL + "/result",
+		OriginatorConversationID: "payout-1",
+	})
+	if err != nil {
+		t.Fatalf("expecting b2c request to be accepted but got: %v", err)
+	}
+
+	select {
+	case timeout := <-timeouts:
+		if timeout.OriginatorConversationID != "payout-1" {
+			t.Fatalf("unexpected queue timeout %+v", timeout)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("no queue timeout received")
+	}
+}
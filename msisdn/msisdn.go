@@ -0,0 +1,194 @@
+// Package msisdn parses the phone numbers customers type, such as "0712 345 678",
+// "+251-712-345678" or "712345678", into the canonical form expected by the M-Pesa API:
+// the international number without the leading "+", e.g. "251712345678".
+//
+// Numbers are parsed against the Profile of a country, which also identifies the operator
+// of the number from its prefix:
+//
+//	number, err := msisdn.Parse("0712 345 678", msisdn.Ethiopia)
+//	if err != nil {
+//	    return err
+//	}
+//	fmt.Println(number, number.Operator) // 251712345678 Safaricom
+package msisdn
+
+import (
+	"strings"
+
+	sdkError "github.com/coleYab/mpesasdk/errors"
+)
+
+// Operator is the mobile network operator a number belongs to.
+type Operator string
+
+// Predefined operators.
+const (
+	Safaricom    Operator = "Safaricom"
+	EthioTelecom Operator = "Ethio Telecom"
+	Airtel       Operator = "Airtel"
+	Telkom       Operator = "Telkom"
+)
+
+// Range assigns the national numbers starting with Prefix to an operator.
+type Range struct {
+	Prefix   string
+	Operator Operator
+}
+
+// Profile describes the phone numbers of a country.
+//
+// Fields:
+//   - Country: The ISO 3166 code of the country, e.g. "ET".
+//   - CountryCode: The international calling code of the country, e.g. "251".
+//   - NationalLength: The number of digits of a national number, without the trunk prefix "0".
+//   - Ranges: The mobile prefixes of the national numbers, the longest matching prefix wins.
+type Profile struct {
+	Country        string
+	CountryCode    string
+	NationalLength int
+	Ranges         []Range
+}
+
+// Ethiopia is the profile of Ethiopian mobile numbers.
+var Ethiopia = &Profile{
+	Country:        "ET",
+	CountryCode:    "251",
+	NationalLength: 9,
+	Ranges: []Range{
+		{"7", Safaricom},
+		{"9", EthioTelecom},
+	},
+}
+
+// Kenya is the profile of Kenyan mobile numbers.
+var Kenya = &Profile{
+	Country:        "KE",
+	CountryCode:    "254",
+	NationalLength: 9,
+	Ranges: []Range{
+		{"70", Safaricom}, {"71", Safaricom}, {"72", Safaricom}, {"79", Safaricom},
+		{"740", Safaricom}, {"741", Safaricom}, {"742", Safaricom}, {"743", Safaricom},
+		{"745", Safaricom}, {"746", Safaricom}, {"748", Safaricom},
+		{"757", Safaricom}, {"758", Safaricom}, {"759", Safaricom},
+		{"768", Safaricom}, {"769", Safaricom},
+		{"110", Safaricom}, {"111", Safaricom}, {"112", Safaricom}, {"113", Safaricom},
+		{"114", Safaricom}, {"115", Safaricom},
+		{"73", Airtel}, {"78", Airtel}, {"762", Airtel},
+		{"750", Airtel}, {"751", Airtel}, {"752", Airtel}, {"753", Airtel},
+		{"754", Airtel}, {"755", Airtel}, {"756", Airtel},
+		{"100", Airtel}, {"101", Airtel}, {"102", Airtel},
+		{"77", Telkom},
+	},
+}
+
+// Number is a parsed mobile number.
+//
+// Fields:
+//   - Profile: The profile the number was parsed with.
+//   - National: The national number, without the country code nor the trunk prefix "0".
+//   - Operator: The operator of the number.
+type Number struct {
+	Profile  *Profile
+	National string
+	Operator Operator
+}
+
+// String returns the number in the canonical form of the M-Pesa API, e.g. "251712345678".
+func (n Number) String() string {
+	return n.Profile.CountryCode + n.National
+}
+
+// E164 returns the number in E.164 form, e.g. "+251712345678".
+func (n Number) E164() string {
+	return "+" + n.String()
+}
+
+// Local returns the number as dialled within the country, e.g. "0712345678".
+func (n Number) Local() string {
+	return "0" + n.National
+}
+
+// Parse parses a mobile number of the country of profile. Spaces, dashes, dots and brackets
+// are ignored, and the number may be given in international form (with or without "+" or "00"),
+// with the trunk prefix "0" or as a bare national number.
+//
+// Returns:
+//   - The parsed Number.
+//   - A validation error if raw is not a mobile number of the country.
+func Parse(raw string, profile *Profile) (Number, error) {
+	if profile == nil {
+		return Number{}, sdkError.ValidationError("no mobile number profile")
+	}
+
+	digits, ok := clean(raw)
+	if !ok {
+		return Number{}, invalid(profile)
+	}
+
+	var national string
+	switch {
+	case len(digits) == len(profile.CountryCode)+profile.NationalLength && strings.HasPrefix(digits, profile.CountryCode):
+		national = digits[len(profile.CountryCode):]
+	case len(digits) == profile.NationalLength+1 && digits[0] == '0':
+		national = digits[1:]
+	case len(digits) == profile.NationalLength:
+		national = digits
+	default:
+		return Number{}, invalid(profile)
+	}
+
+	operator, ok := profile.operator(national)
+	if !ok {
+		return Number{}, invalid(profile)
+	}
+	return Number{Profile: profile, National: national, Operator: operator}, nil
+}
+
+// Normalize returns raw in the canonical form of the M-Pesa API, e.g. "251712345678".
+func Normalize(raw string, profile *Profile) (string, error) {
+	number, err := Parse(raw, profile)
+	if err != nil {
+		return "", err
+	}
+	return number.String(), nil
+}
+
+// operator returns the operator of the longest prefix of national.
+func (p *Profile) operator(national string) (Operator, bool) {
+	var match Range
+	for _, r := range p.Ranges {
+		if strings.HasPrefix(national, r.Prefix) && len(r.Prefix) > len(match.Prefix) {
+			match = r
+		}
+	}
+	return match.Operator, match.Prefix != ""
+}
+
+// clean strips the separators and international prefix of raw, reporting whether only digits remain.
+func clean(raw string) (string, bool) {
+	raw = strings.TrimSpace(raw)
+
+	var b strings.Builder
+	for i, r := range raw {
+		switch {
+		case r >= '0' && r <= '9':
+			b.WriteRune(r)
+		case r == '+' && i == 0:
+		case r == ' ' || r == '-' || r == '.' || r == '(' || r == ')':
+		default:
+			return "", false
+		}
+	}
+
+	digits := b.String()
+	if strings.HasPrefix(raw, "+") {
+		return digits, digits != ""
+	}
+	return strings.TrimPrefix(digits, "00"), digits != ""
+}
+
+// invalid creates the error of a number that cannot be parsed. The number is left out of the
+// message, which may end up in logs.
+func invalid(profile *Profile) error {
+	return sdkError.ValidationError("invalid " + profile.Country + " mobile number")
+}
@@ -0,0 +1,64 @@
+package msisdn_test
+
+import (
+	"errors"
+	"testing"
+
+	sdkError "github.com/coleYab/mpesasdk/errors"
+	"github.com/coleYab/mpesasdk/msisdn"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		raw      string
+		profile  *msisdn.Profile
+		number   string
+		operator msisdn.Operator
+	}{
+		{"251712345678", msisdn.Ethiopia, "251712345678", msisdn.Safaricom},
+		{"0712345678", msisdn.Ethiopia, "251712345678", msisdn.Safaricom},
+		{"+251 712 345 678", msisdn.Ethiopia, "251712345678", msisdn.Safaricom},
+		{"712-345-678", msisdn.Ethiopia, "251712345678", msisdn.Safaricom},
+		{"00251 (712) 345.678", msisdn.Ethiopia, "251712345678", msisdn.Safaricom},
+		{"0911234567", msisdn.Ethiopia, "251911234567", msisdn.EthioTelecom},
+		{"0712345678", msisdn.Kenya, "254712345678", msisdn.Safaricom},
+		{"+254 110 123456", msisdn.Kenya, "254110123456", msisdn.Safaricom},
+		{"0733123456", msisdn.Kenya, "254733123456", msisdn.Airtel},
+		{"0762123456", msisdn.Kenya, "254762123456", msisdn.Airtel},
+		{"0771123456", msisdn.Kenya, "254771123456", msisdn.Telkom},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.raw, func(t *testing.T) {
+			number, err := msisdn.Parse(tt.raw, tt.profile)
+			if err != nil {
+				t.Fatalf("expecting %v to be parsed but got %v", tt.raw, err)
+			}
+			if number.String() != tt.number || number.Operator != tt.operator {
+				t.Fatalf("expecting %v (%v) but got %v (%v)", tt.number, tt.operator, number, number.Operator)
+			}
+		})
+	}
+}
+
+func TestParseRejectsInvalidNumbers(t *testing.T) {
+	for _, raw := range []string{"", "251112345678", "252712345678", "07123456", "07123456789", "0712a45678", "+", "0712345678 ext 1"} {
+		if _, err := msisdn.Parse(raw, msisdn.Ethiopia); !errors.Is(err, sdkError.ErrValidation) {
+			t.Errorf("expecting %q to be rejected but got %v", raw, err)
+		}
+	}
+
+	if _, err := msisdn.Parse("0201234567", msisdn.Kenya); err == nil {
+		t.Errorf("expecting a landline number to be rejected")
+	}
+	if _, err := msisdn.Parse("0712345678", nil); err == nil {
+		t.Errorf("expecting a number without profile to be rejected")
+	}
+}
+
+func TestNumberFormats(t *testing.T) {
+	number, _ := msisdn.Parse("0712345678", msisdn.Ethiopia)
+	if number.E164() != "+251712345678" || number.Local() != "0712345678" {
+		t.Fatalf("unexpected formats %v %v", number.E164(), number.Local())
+	}
+}
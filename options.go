@@ -0,0 +1,280 @@
+package mpesasdk
+
+import (
+	"crypto/x509"
+	"net/http"
+	"time"
+
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/coleYab/mpesasdk/auth"
+	"github.com/coleYab/mpesasdk/client"
+	"github.com/coleYab/mpesasdk/common"
+	"github.com/coleYab/mpesasdk/debug"
+	"github.com/coleYab/mpesasdk/idempotency"
+	"github.com/coleYab/mpesasdk/metrics"
+	"github.com/coleYab/mpesasdk/msisdn"
+	"github.com/coleYab/mpesasdk/redact"
+	"github.com/coleYab/mpesasdk/service"
+	"github.com/coleYab/mpesasdk/tracker"
+)
+
+// config holds the settings collected from the options passed to New.
+type config struct {
+	env               common.Enviroment
+	httpClient        *http.Client
+	logger            service.StructuredLogger
+	timeout           time.Duration
+	retryPolicy       client.RetryPolicy
+	operationPolicies map[common.Operation]client.RetryPolicy
+	baseURL           string
+	paths             map[common.Operation]string
+	shortCode         uint
+	passkey           string
+	initiator         string
+	tokenStore        auth.TokenStore
+	idempotencyStore  idempotency.Store
+	idempotencyTTL    time.Duration
+	msisdnProfile     *msisdn.Profile
+	redactor          *redact.Redactor
+	debugSink         debug.Sink
+	interceptors      []client.Interceptor
+	tracerProvider    trace.TracerProvider
+	tracePropagation  bool
+	metrics           metrics.Metrics
+	tracker           *tracker.Tracker
+	stkPushPollAfter  time.Duration
+	stkPushPollEvery  time.Duration
+
+	initiatorPassword string
+	certificate       *x509.Certificate
+}
+
+// Option configures an MpesaClient created with New.
+type Option func(*config)
+
+// WithEnvironment selects the environment of the M-Pesa API (common.SANDBOX by default).
+func WithEnvironment(env common.Enviroment) Option {
+	return func(c *config) {
+		c.env = env
+	}
+}
+
+// WithHTTPClient makes the client send its requests, including token requests, through
+// httpClient, allowing a tuned transport to be injected. The http.Client is not modified,
+// when WithTimeout is also given a copy of it is used.
+func WithHTTPClient(httpClient *http.Client) Option {
+	return func(c *config) {
+		c.httpClient = httpClient
+	}
+}
+
+// WithLogger sets the logger of the client (a service.StdLogger writing to os.Stdout by default),
+// e.g. service.NewSlogLogger or service.NewNopLogger. The messages are masked by the redactor
+// of the client before reaching logger, see WithRedactor.
+func WithLogger(logger service.StructuredLogger) Option {
+	return func(c *config) {
+		c.logger = logger
+	}
+}
+
+// WithTimeout sets the timeout of every HTTP request (5 seconds by default).
+func WithTimeout(timeout time.Duration) Option {
+	return func(c *config) {
+		c.timeout = timeout
+	}
+}
+
+// WithRetryPolicy sets the policy deciding which failed requests are retried
+// (client.NewDefaultRetryPolicy by default). B2C payments and reversals keep using
+// client.NewSafeRetryPolicy unless overridden with WithOperationRetryPolicy.
+func WithRetryPolicy(policy client.RetryPolicy) Option {
+	return func(c *config) {
+		c.retryPolicy = policy
+	}
+}
+
+// WithOperationRetryPolicy sets the retry policy of a single operation, overriding the one set
+// with WithRetryPolicy.
+func WithOperationRetryPolicy(op common.Operation, policy client.RetryPolicy) Option {
+	return func(c *config) {
+		c.operationPolicies[op] = policy
+	}
+}
+
+// WithBaseURL sends every request to baseURL instead of the environment's default host.
+func WithBaseURL(baseURL string) Option {
+	return func(c *config) {
+		c.baseURL = baseURL
+	}
+}
+
+// WithEndpointPath overrides the path of a single operation.
+func WithEndpointPath(op common.Operation, path string) Option {
+	return func(c *config) {
+		c.paths[op] = path
+	}
+}
+
+// WithDefaultShortCode sets the shortcode used by requests that leave their business
+// shortcode (BusinessShortCode, ShortCode, PartyA or ReceiverParty) empty.
+func WithDefaultShortCode(shortCode uint) Option {
+	return func(c *config) {
+		c.shortCode = shortCode
+	}
+}
+
+// WithPasskey sets the STK push passkey used when STKPushPaymentRequest or QuerySTKPush is called
+// with an empty passkey.
+func WithPasskey(passkey string) Option {
+	return func(c *config) {
+		c.passkey = passkey
+	}
+}
+
+// WithInitiator sets the initiator name used by requests that leave it empty.
+func WithInitiator(initiator string) Option {
+	return func(c *config) {
+		c.initiator = initiator
+	}
+}
+
+// WithInitiatorPassword makes the client fill the SecurityCredential of requests that leave it
+// empty (B2C, transaction status, account balance and reversal) by encrypting password with
+// the certificate of the environment, see the security package.
+func WithInitiatorPassword(password string) Option {
+	return func(c *config) {
+		c.initiatorPassword = password
+	}
+}
+
+// WithCertificate sets the certificate the initiator password is encrypted with, instead of
+// the one of the environment.
+func WithCertificate(cert *x509.Certificate) Option {
+	return func(c *config) {
+		c.certificate = cert
+	}
+}
+
+// WithTokenStore shares the OAuth token through store, so that clients of several processes or
+// replicas using the same credentials request one token instead of each requesting their own.
+func WithTokenStore(store auth.TokenStore) Option {
+	return func(c *config) {
+		c.tokenStore = store
+	}
+}
+
+// WithIdempotencyStore deduplicates B2C payments and reversals by OriginatorConversationID
+// through store: a request submitted again with the same OriginatorConversationID returns the
+// original acknowledgement instead of moving money twice.
+func WithIdempotencyStore(store idempotency.Store) Option {
+	return func(c *config) {
+		c.idempotencyStore = store
+	}
+}
+
+// WithIdempotencyTTL sets how long a submission with an unknown outcome, e.g. a timeout, blocks
+// the submissions with the same OriginatorConversationID (idempotency.DefaultReservationTTL by
+// default).
+func WithIdempotencyTTL(ttl time.Duration) Option {
+	return func(c *config) {
+		if ttl > 0 {
+			c.idempotencyTTL = ttl
+		}
+	}
+}
+
+// WithMSISDNProfile sets the country profile the phone numbers of STK push and simulated C2B
+// requests are normalized with (msisdn.Ethiopia by default), e.g. msisdn.Kenya for Daraja.
+func WithMSISDNProfile(profile *msisdn.Profile) Option {
+	return func(c *config) {
+		c.msisdnProfile = profile
+	}
+}
+
+// WithRedactor sets the Redactor masking the secrets and phone numbers of the log lines and
+// errors of the client (redact.New() with the profile of WithMSISDNProfile by default), e.g. to
+// mask phone numbers differently:
+//
+//	mpesasdk.WithRedactor(redact.New(redact.WithMSISDNMask(redact.MaskAll)))
+func WithRedactor(redactor *redact.Redactor) Option {
+	return func(c *config) {
+		c.redactor = redactor
+	}
+}
+
+// WithDebugSink enables the wire debug mode: the method, URL, headers, body, status and timing
+// of every attempt of a request and of its response are recorded to sink, masked by the redactor
+// of the client. It is meant for troubleshooting, e.g. to see what the API returned for a
+// ProcessingError:
+//
+//	sink := debug.NewMemorySink(10)
+//	client, err := mpesasdk.New(key, secret, mpesasdk.WithDebugSink(sink))
+//
+// or mpesasdk.WithDebugSink(debug.NewWriterSink(os.Stderr)) to dump the exchanges.
+func WithDebugSink(sink debug.Sink) Option {
+	return func(c *config) {
+		c.debugSink = sink
+	}
+}
+
+// WithInterceptors adds interceptors around every call of the client, e.g. to add headers,
+// tracing, metrics or audit hooks. They see the validated request, the HTTP request before it is
+// sent and the decoded response or error, and run in the order given, the first being the
+// outermost; see client.Interceptor. client.LoggingInterceptor and client.TimingInterceptor are
+// provided.
+func WithInterceptors(interceptors ...client.Interceptor) Option {
+	return func(c *config) {
+		c.interceptors = append(c.interceptors, interceptors...)
+	}
+}
+
+// WithTracerProvider sets the OpenTelemetry provider of the spans of the client (the global
+// tracer provider by default): a span per call, with child spans for every attempt and token
+// acquisition.
+func WithTracerProvider(tp trace.TracerProvider) Option {
+	return func(c *config) {
+		c.tracerProvider = tp
+	}
+}
+
+// WithCallbackTracePropagation adds the trace context of an STK push to the query of its
+// CallBackURL as a "traceparent" parameter, so that the callback handler continues the trace
+// of the push, see the tracing package. The CallBackURL is sent as given by default.
+func WithCallbackTracePropagation() Option {
+	return func(c *config) {
+		c.tracePropagation = true
+	}
+}
+
+// WithMetrics reports the calls of the client to m: their start, duration and error code, their
+// retries and the token refreshes, e.g. to the collector of the metrics/prometheus package.
+func WithMetrics(m metrics.Metrics) Option {
+	return func(c *config) {
+		c.metrics = m
+	}
+}
+
+// WithTracker records the requests acknowledged by the client in t, so that their outcome can be
+// awaited once their callback is received by the handlers wrapped by t, see the tracker package.
+// STKPushAndWait takes the outcome of STK pushes from t.
+func WithTracker(t *tracker.Tracker) Option {
+	return func(c *config) {
+		c.tracker = t
+	}
+}
+
+// WithSTKPushPolling sets when STKPushAndWait falls back to querying the status of an STK push
+// whose callback did not arrive: after waiting for it for after (30 seconds by default), then
+// every interval (5 seconds by default). Clients without a tracker, see WithTracker, receive no
+// callback and query every interval from the start.
+func WithSTKPushPolling(after, interval time.Duration) Option {
+	return func(c *config) {
+		if after > 0 {
+			c.stkPushPollAfter = after
+		}
+		if interval > 0 {
+			c.stkPushPollEvery = interval
+		}
+	}
+}
@@ -0,0 +1,356 @@
+// Package redact masks secrets and personal data before they reach a log line, an error message
+// or an HTTP dump produced by the SDK.
+//
+// A Redactor knows two sets of keys, matched case-insensitively against JSON properties, query
+// parameters, headers and log fields:
+//   - Secret keys (e.g. SecurityCredential, Password, apikey, Authorization) whose values are
+//     replaced with "[REDACTED]".
+//   - MSISDN keys (e.g. PhoneNumber, Msisdn, PartyA) whose values are masked with a configurable
+//     function, "2517****4567" by default.
+//
+// Phone numbers appearing in free text are masked as well, when they are in the international
+// form of one of the msisdn profiles of the Redactor (Ethiopian and Kenyan numbers by default):
+//
+//	r := redact.New(redact.WithMSISDNMask(redact.MaskMiddle(3, 2)))
+//	r.String(`{"PhoneNumber":"251712344567","Password":"MTIz"}`)
+//	// {"PhoneNumber":"251*******67","Password":"[REDACTED]"}
+package redact
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/coleYab/mpesasdk/msisdn"
+	"github.com/coleYab/mpesasdk/service"
+)
+
+// Redacted replaces the values of secret keys.
+const Redacted = "[REDACTED]"
+
+// DefaultSecretKeys are the keys whose values are always replaced with Redacted.
+var DefaultSecretKeys = []string{
+	"SecurityCredential", "Password", "Passkey", "apikey", "Authorization", "access_token",
+	"ConsumerKey", "ConsumerSecret", "client_secret", "InitiatorPassword",
+	"ReceiverPartyPublicName", "DebitPartyName", "CreditPartyName", "FirstName", "MiddleName", "LastName",
+}
+
+// DefaultMSISDNKeys are the keys whose values are masked as phone numbers.
+var DefaultMSISDNKeys = []string{
+	"PhoneNumber", "Msisdn", "MSISDN", "PartyA", "PartyB", "ReceiverParty",
+}
+
+// DefaultMSISDNProfiles are the profiles of the phone numbers masked in free text.
+var DefaultMSISDNProfiles = []*msisdn.Profile{msisdn.Ethiopia, msisdn.Kenya}
+
+// Redactor masks the secrets and phone numbers of strings, JSON bodies, headers and log fields.
+// A Redactor is safe for concurrent use once created.
+type Redactor struct {
+	secretKeys map[string]bool
+	msisdnKeys map[string]bool
+	maskMSISDN func(string) string
+	profiles   []*msisdn.Profile
+
+	secretPattern     *regexp.Regexp
+	secretJSONPattern *regexp.Regexp
+	msisdnPattern     *regexp.Regexp
+}
+
+// Option configures a Redactor created with New.
+type Option func(*Redactor)
+
+// WithSecretKeys adds keys whose values are replaced with Redacted.
+func WithSecretKeys(keys ...string) Option {
+	return func(r *Redactor) {
+		for _, key := range keys {
+			r.secretKeys[strings.ToLower(key)] = true
+		}
+	}
+}
+
+// WithMSISDNKeys adds keys whose values are masked as phone numbers.
+func WithMSISDNKeys(keys ...string) Option {
+	return func(r *Redactor) {
+		for _, key := range keys {
+			r.msisdnKeys[strings.ToLower(key)] = true
+		}
+	}
+}
+
+// WithMSISDNProfiles adds profiles whose phone numbers are masked in free text, e.g. the one
+// given to mpesasdk.WithMSISDNProfile.
+func WithMSISDNProfiles(profiles ...*msisdn.Profile) Option {
+	return func(r *Redactor) {
+		r.profiles = append(r.profiles, profiles...)
+	}
+}
+
+// WithMSISDNMask sets the function masking phone numbers (MaskMiddle(4, 4) by default).
+func WithMSISDNMask(mask func(string) string) Option {
+	return func(r *Redactor) {
+		r.maskMSISDN = mask
+	}
+}
+
+// New creates a Redactor masking the DefaultSecretKeys and DefaultMSISDNKeys, and the phone
+// numbers of the DefaultMSISDNProfiles in free text.
+func New(opts ...Option) *Redactor {
+	r := &Redactor{
+		secretKeys: map[string]bool{},
+		msisdnKeys: map[string]bool{},
+		maskMSISDN: MaskMiddle(4, 4),
+	}
+	WithSecretKeys(DefaultSecretKeys...)(r)
+	WithMSISDNKeys(DefaultMSISDNKeys...)(r)
+	WithMSISDNProfiles(DefaultMSISDNProfiles...)(r)
+	for _, opt := range opts {
+		opt(r)
+	}
+
+	secrets := make([]string, 0, len(r.secretKeys))
+	for key := range r.secretKeys {
+		secrets = append(secrets, regexp.QuoteMeta(key))
+	}
+	alternatives := strings.Join(secrets, "|")
+	r.secretPattern = regexp.MustCompile(`(?i)\b(` + alternatives + `)(=|:\s*)(Bearer\s+|Basic\s+)?[^&\s",}]+`)
+	r.secretJSONPattern = regexp.MustCompile(`(?i)"(` + alternatives + `)"(\s*:\s*)"[^"]*"`)
+	r.msisdnPattern = msisdnPattern(r.profiles)
+	return r
+}
+
+// msisdnPattern returns the pattern of the phone numbers of profiles in international form,
+// e.g. 251 followed by 9 digits for msisdn.Ethiopia.
+func msisdnPattern(profiles []*msisdn.Profile) *regexp.Regexp {
+	seen := map[string]bool{}
+	var numbers []string
+	for _, profile := range profiles {
+		if profile == nil {
+			continue
+		}
+		number := regexp.QuoteMeta(profile.CountryCode) + `\d{` + strconv.Itoa(profile.NationalLength) + `}`
+		if !seen[number] {
+			seen[number] = true
+			numbers = append(numbers, number)
+		}
+	}
+	if len(numbers) == 0 {
+		return regexp.MustCompile(`[^\s\S]`) // Never matches
+	}
+	return regexp.MustCompile(`\b(?:` + strings.Join(numbers, "|") + `)\b`)
+}
+
+// MaskMiddle returns a mask keeping the first keepPrefix and last keepSuffix characters of a
+// phone number, e.g. MaskMiddle(4, 4) masks "251712344567" as "2517****4567".
+func MaskMiddle(keepPrefix, keepSuffix int) func(string) string {
+	return func(s string) string {
+		if len(s) <= keepPrefix+keepSuffix {
+			return s
+		}
+		return s[:keepPrefix] + strings.Repeat("*", len(s)-keepPrefix-keepSuffix) + s[len(s)-keepSuffix:]
+	}
+}
+
+// MaskAll is a mask replacing phone numbers with Redacted.
+func MaskAll(string) string {
+	return Redacted
+}
+
+// IsSecret reports whether the values of key are replaced with Redacted.
+func (r *Redactor) IsSecret(key string) bool {
+	return r.secretKeys[strings.ToLower(key)]
+}
+
+// IsMSISDN reports whether the values of key are masked as phone numbers.
+func (r *Redactor) IsMSISDN(key string) bool {
+	return r.msisdnKeys[strings.ToLower(key)]
+}
+
+// MaskMSISDN masks a phone number.
+func (r *Redactor) MaskMSISDN(msisdn string) string {
+	return r.maskMSISDN(msisdn)
+}
+
+// String masks the secrets and phone numbers of free text, such as an error message, a URL or
+// a body that is not valid JSON. Secrets are recognized as key=value, key: value and JSON
+// properties, including "Authorization: Bearer <token>".
+func (r *Redactor) String(s string) string {
+	s = r.secretJSONPattern.ReplaceAllString(s, `"$1"$2"`+Redacted+`"`)
+	s = r.secretPattern.ReplaceAllString(s, `$1$2$3`+Redacted)
+	return r.msisdnPattern.ReplaceAllStringFunc(s, r.maskMSISDN)
+}
+
+// URL masks the secret and MSISDN query parameters of a URL, e.g. the apikey of the C2B URL
+// registration. The parameters are masked in place, the others are kept as they are.
+func (r *Redactor) URL(rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil || u.RawQuery == "" {
+		return r.String(rawURL)
+	}
+
+	rest, fragment, hasFragment := strings.Cut(rawURL, "#")
+	rest, query, _ := strings.Cut(rest, "?")
+	params := strings.Split(query, "&")
+	for i, param := range params {
+		rawKey, rawValue, _ := strings.Cut(param, "=")
+		key, err := url.QueryUnescape(rawKey)
+		if err != nil {
+			key = rawKey
+		}
+		value, err := url.QueryUnescape(rawValue)
+		if err != nil {
+			value = rawValue
+		}
+
+		if masked := fmt.Sprint(r.Field(key, value)); masked != value {
+			params[i] = rawKey + "=" + url.QueryEscape(masked)
+		}
+	}
+
+	redacted := r.String(rest) + "?" + strings.Join(params, "&")
+	if hasFragment {
+		redacted += "#" + r.String(fragment)
+	}
+	return redacted
+}
+
+// Header returns a copy of h whose secret headers, such as Authorization, are masked.
+func (r *Redactor) Header(h http.Header) http.Header {
+	redacted := h.Clone()
+	for key, values := range redacted {
+		if r.IsSecret(key) {
+			for i, value := range values {
+				// Keep the scheme, it tells Basic and Bearer authorizations apart
+				if scheme, _, ok := strings.Cut(value, " "); ok {
+					values[i] = scheme + " " + Redacted
+				} else {
+					values[i] = Redacted
+				}
+			}
+		}
+	}
+	return redacted
+}
+
+// JSON masks the secret and MSISDN properties of a JSON body, at any depth. Items of the
+// {"Name": "PhoneNumber", "Value": ...} and {"Key": ..., "Value": ...} form found in callbacks
+// are masked by their name. Bodies that are not valid JSON are masked with String.
+func (r *Redactor) JSON(body []byte) []byte {
+	decoder := json.NewDecoder(bytes.NewReader(body))
+	decoder.UseNumber()
+
+	var v any
+	if err := decoder.Decode(&v); err != nil || decoder.More() {
+		return []byte(r.String(string(body)))
+	}
+
+	var out bytes.Buffer
+	encoder := json.NewEncoder(&out)
+	encoder.SetEscapeHTML(false)
+	if err := encoder.Encode(r.walk(v)); err != nil {
+		return []byte(r.String(string(body)))
+	}
+	return bytes.TrimSuffix(out.Bytes(), []byte("\n"))
+}
+
+// walk masks the properties of a decoded JSON value.
+func (r *Redactor) walk(v any) any {
+	switch v := v.(type) {
+	case map[string]any:
+		// Callback items carry their name next to their value
+		for _, nameKey := range []string{"Name", "Key"} {
+			if name, ok := v[nameKey].(string); ok {
+				if value, ok := v["Value"]; ok {
+					v["Value"] = r.Field(name, value)
+				}
+			}
+		}
+		for key, value := range v {
+			if r.IsSecret(key) || r.IsMSISDN(key) {
+				v[key] = r.Field(key, value)
+			} else {
+				v[key] = r.walk(value)
+			}
+		}
+		return v
+	case []any:
+		for i, item := range v {
+			v[i] = r.walk(item)
+		}
+		return v
+	case string:
+		return r.String(v)
+	}
+	return v
+}
+
+// Field masks the value of a field named key: secrets are replaced with Redacted, phone numbers
+// are masked and the secrets and phone numbers of other strings and errors are masked with String.
+// Other values are returned as is.
+func (r *Redactor) Field(key string, value any) any {
+	switch {
+	case value == nil:
+		return nil
+	case r.IsSecret(key):
+		return Redacted
+	case r.IsMSISDN(key):
+		return r.maskMSISDN(fmt.Sprint(value))
+	}
+
+	switch v := value.(type) {
+	case string:
+		return r.String(v)
+	case error:
+		return r.String(v.Error())
+	}
+	return value
+}
+
+// Logger wraps logger so that the messages and fields it logs are masked.
+func (r *Redactor) Logger(logger service.StructuredLogger) service.StructuredLogger {
+	return &redactingLogger{logger: logger, redactor: r}
+}
+
+// redactingLogger is the Logger returned by Redactor.Logger.
+type redactingLogger struct {
+	logger   service.StructuredLogger
+	redactor *Redactor
+}
+
+// Debug implements service.StructuredLogger.
+func (l *redactingLogger) Debug(msg string, keyvals ...any) {
+	l.logger.Debug(l.redactor.String(msg), l.fields(keyvals)...)
+}
+
+// Info implements service.StructuredLogger.
+func (l *redactingLogger) Info(msg string, keyvals ...any) {
+	l.logger.Info(l.redactor.String(msg), l.fields(keyvals)...)
+}
+
+// Warn implements service.StructuredLogger.
+func (l *redactingLogger) Warn(msg string, keyvals ...any) {
+	l.logger.Warn(l.redactor.String(msg), l.fields(keyvals)...)
+}
+
+// Error implements service.StructuredLogger.
+func (l *redactingLogger) Error(msg string, keyvals ...any) {
+	l.logger.Error(l.redactor.String(msg), l.fields(keyvals)...)
+}
+
+// fields returns a masked copy of keyvals.
+func (l *redactingLogger) fields(keyvals []any) []any {
+	redacted := make([]any, len(keyvals))
+	for i := 0; i < len(keyvals); i += 2 {
+		if i+1 == len(keyvals) {
+			redacted[i] = l.redactor.Field("", keyvals[i])
+			break
+		}
+		redacted[i] = keyvals[i]
+		redacted[i+1] = l.redactor.Field(fmt.Sprint(keyvals[i]), keyvals[i+1])
+	}
+	return redacted
+}
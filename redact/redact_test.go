@@ -0,0 +1,99 @@
+package redact_test
+
+import (
+	"bytes"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/coleYab/mpesasdk/msisdn"
+	"github.com/coleYab/mpesasdk/redact"
+	"github.com/coleYab/mpesasdk/service"
+)
+
+func TestString(t *testing.T) {
+	r := redact.New()
+
+	tests := map[string]string{
+		`Post "https://api.safaricom.et/v1/c2b-register-url/register?apikey=abc123": EOF`: `Post "https://api.safaricom.et/v1/c2b-register-url/register?apikey=[REDACTED]": EOF`,
+		`Authorization: Bearer eyJhbGciOi.x-y`:                                            `Authorization: Bearer [REDACTED]`,
+		`{"Password": "MTIz", "Amount": 10}`:                                              `{"Password": "[REDACTED]", "Amount": 10}`,
+		`payment from 251712344567 failed`:                                                `payment from 2517****4567 failed`,
+		`SecurityCredential is required`:                                                  `SecurityCredential is required`,
+	}
+
+	for in, expected := range tests {
+		if out := r.String(in); out != expected {
+			t.Errorf("expecting %q to be redacted as %q but got %q", in, expected, out)
+		}
+	}
+}
+
+func TestJSON(t *testing.T) {
+	r := redact.New(redact.WithMSISDNMask(redact.MaskMiddle(3, 2)), redact.WithSecretKeys("AccountReference"))
+
+	body := `{"Body":{"stkCallback":{"CallbackMetadata":{"Item":[{"Name":"Amount","Value":10},{"Name":"PhoneNumber","Value":251712344567}]}}},` +
+		`"Password":"MTIz","AccountReference":"INV-1","PartyA":"251712344567","TransactionDesc":"<Payment>"}`
+
+	out := string(r.JSON([]byte(body)))
+	for _, leaked := range []string{"MTIz", "INV-1", "251712344567"} {
+		if strings.Contains(out, leaked) {
+			t.Fatalf("expecting %v to be redacted but got %v", leaked, out)
+		}
+	}
+	for _, kept := range []string{`"Value":10`, `"Value":"251*******67"`, `"PartyA":"251*******67"`, `"TransactionDesc":"<Payment>"`} {
+		if !strings.Contains(out, kept) {
+			t.Fatalf("expecting %v in %v", kept, out)
+		}
+	}
+
+	if out := string(r.JSON([]byte("apikey=abc"))); out != "apikey=[REDACTED]" {
+		t.Fatalf("expecting invalid JSON to be redacted as text but got %v", out)
+	}
+}
+
+func TestHeaderAndURL(t *testing.T) {
+	r := redact.New()
+
+	header := http.Header{"Authorization": {"Basic a2V5OnNlY3JldA=="}, "Content-Type": {"application/json"}}
+	redacted := r.Header(header)
+	if redacted.Get("Authorization") != "Basic [REDACTED]" || redacted.Get("Content-Type") != "application/json" {
+		t.Fatalf("unexpected redacted header %v", redacted)
+	}
+	if header.Get("Authorization") != "Basic a2V5OnNlY3JldA==" {
+		t.Fatalf("expecting the original header to be kept")
+	}
+
+	if u := r.URL("https://example.com/register?apikey=abc&msisdn=251712344567&page=1"); u != "https://example.com/register?apikey=%5BREDACTED%5D&msisdn=2517%2A%2A%2A%2A4567&page=1" {
+		t.Fatalf("unexpected redacted url %v", u)
+	}
+
+	// The other parameters are kept as they are, in their order
+	if u := r.URL("https://example.com/stk?z=%7E1&apikey=abc&a=b#top"); u != "https://example.com/stk?z=%7E1&apikey=%5BREDACTED%5D&a=b#top" {
+		t.Fatalf("unexpected redacted url %v", u)
+	}
+}
+
+func TestMSISDNProfiles(t *testing.T) {
+	tanzania := &msisdn.Profile{Country: "TZ", CountryCode: "255", NationalLength: 9}
+
+	if out := redact.New().String("payment from 255712344567"); out != "payment from 255712344567" {
+		t.Fatalf("expecting numbers of other countries to be kept by default but got %q", out)
+	}
+	if out := redact.New(redact.WithMSISDNProfiles(tanzania)).String("payment from 255712344567 and 254712344567"); out != "payment from 2557****4567 and 2547****4567" {
+		t.Fatalf("expecting the numbers of the added profile to be masked but got %q", out)
+	}
+}
+
+func TestLogger(t *testing.T) {
+	var out bytes.Buffer
+	std := service.NewStdLogger(service.DEBUG)
+	std.SetOutput(&out)
+
+	logger := redact.New().Logger(std)
+	logger.Info("sending 251712344567", "Passkey", "secret", "PhoneNumber", "0712344567", "attempt", 1)
+
+	if line := out.String(); !strings.Contains(line, "sending 2517****4567 Passkey=[REDACTED] PhoneNumber=0712**4567 attempt=1") {
+		t.Fatalf("unexpected log line %q", line)
+	}
+}
@@ -0,0 +1,225 @@
+// Package resultcodes catalogs the result and error codes reported by the M-Pesa API, in
+// synchronous error responses as well as in STK callbacks and ResultURL posts.
+//
+// Every known code maps to an Info describing what it means, who can fix it and whether
+// the request is worth sending again:
+//
+//	info := resultcodes.LookupInt(callback.ResultCode)
+//	if info.Category == resultcodes.CategoryCustomerAction {
+//	    notifyCustomer(info.Description)
+//	}
+package resultcodes
+
+import "strconv"
+
+// Code is a result or error code reported by the M-Pesa API.
+type Code string
+
+// Codes reported in callbacks, ResultURL posts, C2B validation responses and API errors.
+const (
+	Success                     Code = "0"
+	InsufficientBalance         Code = "1"
+	LessThanMinimum             Code = "2"
+	MoreThanMaximum             Code = "3"
+	ExceedsDailyLimit           Code = "4"
+	ExceedsMinimumBalance       Code = "5"
+	UnresolvedPrimaryParty      Code = "6"
+	UnresolvedReceiverParty     Code = "7"
+	ExceedsMaximumBalance       Code = "8"
+	InvalidDebitAccount         Code = "11"
+	InvalidCreditAccount        Code = "12"
+	UnresolvedDebitAccount      Code = "13"
+	UnresolvedCreditAccount     Code = "14"
+	DuplicateDetected           Code = "15"
+	InternalFailure             Code = "17"
+	UnresolvedInitiator         Code = "20"
+	TrafficBlocking             Code = "26"
+	SubscriberLocked            Code = "1001"
+	TransactionExpired          Code = "1019"
+	PushRequestError            Code = "1025"
+	CancelledByUser             Code = "1032"
+	UserUnreachable             Code = "1037"
+	InvalidInitiator            Code = "2001"
+	ProductNotPermitted         Code = "2028"
+	SecurityCredentialLocked    Code = "8006"
+	PushRequestFailed           Code = "9999"
+	OperatorDoesNotExist        Code = "SFC_IC0003"
+	ServiceDenied               Code = "SVC0403"
+	InvalidMSISDN               Code = "C2B00011"
+	InvalidAccountNumber        Code = "C2B00012"
+	InvalidAmount               Code = "C2B00013"
+	InvalidKYCDetails           Code = "C2B00014"
+	InvalidShortcode            Code = "C2B00015"
+	OtherC2BError               Code = "C2B00016"
+	InvalidAuthentication       Code = "400.008.01"
+	InvalidGrantType            Code = "400.008.02"
+	InvalidRequestPayload       Code = "400.002.05"
+	BadRequest                  Code = "400.002.02"
+	ResourceNotFound            Code = "404.001.01"
+	InvalidAccessToken          Code = "404.001.03"
+	InvalidAuthenticationHeader Code = "404.001.04"
+	APIProcessingError          Code = "500.001.1001"
+	SpikeArrestViolation        Code = "500.003.02"
+	QuotaViolation              Code = "500.003.03"
+	InternalServerError         Code = "500.003.1001"
+	ServiceUnavailable          Code = "503.001.01"
+)
+
+// Category tells who can act on a result code.
+type Category string
+
+const (
+	// CategorySuccess is the category of successful results.
+	CategorySuccess Category = "success"
+
+	// CategoryCustomerAction is the category of failures only the customer can resolve,
+	// e.g. a cancelled prompt, a wrong PIN or an insufficient balance.
+	CategoryCustomerAction Category = "customer_action"
+
+	// CategoryMerchantConfig is the category of failures caused by the request or the
+	// configuration of the merchant, e.g. invalid credentials or a wrong shortcode.
+	CategoryMerchantConfig Category = "merchant_config"
+
+	// CategoryTransient is the category of temporary failures of the platform.
+	CategoryTransient Category = "transient"
+
+	// CategoryFatal is the category of failures of a transaction that cannot succeed,
+	// e.g. an expired or duplicate transaction.
+	CategoryFatal Category = "fatal"
+
+	// CategoryUnknown is the category of codes missing from the catalog.
+	CategoryUnknown Category = "unknown"
+)
+
+// Advice tells whether and how a failed request may be sent again.
+type Advice string
+
+const (
+	// AdviceNone is the advice for successful results.
+	AdviceNone Advice = "none"
+
+	// AdviceRetry means the same request may be sent again after a backoff.
+	AdviceRetry Advice = "retry"
+
+	// AdviceRetryAfterCustomer means the request may be sent again once the customer is
+	// ready, e.g. after topping up or when reachable.
+	AdviceRetryAfterCustomer Advice = "retry_after_customer"
+
+	// AdviceFixRequest means the request or the configuration must be corrected first.
+	AdviceFixRequest Advice = "fix_request"
+
+	// AdviceCheckStatus means the outcome is unknown and the transaction status must be
+	// queried before sending the request again.
+	AdviceCheckStatus Advice = "check_status"
+
+	// AdviceDoNotRetry means sending the request again will not succeed.
+	AdviceDoNotRetry Advice = "do_not_retry"
+)
+
+// Info describes a result code.
+//
+// Fields:
+//   - Code: The result code.
+//   - Description: A human readable description of the code.
+//   - Category: Who can act on the code.
+//   - Advice: Whether and how the request may be sent again.
+type Info struct {
+	Code        Code
+	Description string
+	Category    Category
+	Advice      Advice
+}
+
+// Retryable reports whether the same request may be sent again without changes.
+func (i Info) Retryable() bool {
+	return i.Advice == AdviceRetry || i.Advice == AdviceRetryAfterCustomer
+}
+
+// Known reports whether the code is part of the catalog.
+func (i Info) Known() bool {
+	return i.Category != CategoryUnknown
+}
+
+// catalog holds the documented codes.
+var catalog = map[Code]Info{}
+
+func init() {
+	for _, info := range []Info{
+		{Success, "The service request is processed successfully", CategorySuccess, AdviceNone},
+		{InsufficientBalance, "The balance is insufficient for the transaction", CategoryCustomerAction, AdviceRetryAfterCustomer},
+		{LessThanMinimum, "The amount is less than the minimum transaction value", CategoryMerchantConfig, AdviceFixRequest},
+		{MoreThanMaximum, "The amount is more than the maximum transaction value", CategoryMerchantConfig, AdviceFixRequest},
+		{ExceedsDailyLimit, "The transaction would exceed the daily transfer limit", CategoryCustomerAction, AdviceRetryAfterCustomer},
+		{ExceedsMinimumBalance, "The transaction would exceed the minimum balance", CategoryCustomerAction, AdviceRetryAfterCustomer},
+		{UnresolvedPrimaryParty, "The primary party could not be resolved", CategoryMerchantConfig, AdviceFixRequest},
+		{UnresolvedReceiverParty, "The receiver party could not be resolved", CategoryMerchantConfig, AdviceFixRequest},
+		{ExceedsMaximumBalance, "The transaction would exceed the maximum balance of the receiver", CategoryCustomerAction, AdviceDoNotRetry},
+		{InvalidDebitAccount, "The debit account is invalid", CategoryMerchantConfig, AdviceFixRequest},
+		{InvalidCreditAccount, "The credit account is invalid", CategoryMerchantConfig, AdviceFixRequest},
+		{UnresolvedDebitAccount, "The debit account could not be resolved", CategoryMerchantConfig, AdviceFixRequest},
+		{UnresolvedCreditAccount, "The credit account could not be resolved", CategoryMerchantConfig, AdviceFixRequest},
+		{DuplicateDetected, "A duplicate transaction was detected", CategoryFatal, AdviceDoNotRetry},
+		{InternalFailure, "Internal failure of the platform", CategoryTransient, AdviceCheckStatus},
+		{UnresolvedInitiator, "The initiator could not be resolved", CategoryMerchantConfig, AdviceFixRequest},
+		{TrafficBlocking, "The system is busy, a traffic blocking condition is in place", CategoryTransient, AdviceRetry},
+		{SubscriberLocked, "A transaction is already in process for the subscriber", CategoryTransient, AdviceRetry},
+		{TransactionExpired, "The transaction has expired", CategoryFatal, AdviceDoNotRetry},
+		{PushRequestError, "An error occurred while sending the push request", CategoryTransient, AdviceRetry},
+		{CancelledByUser, "The request was cancelled by the user", CategoryCustomerAction, AdviceRetryAfterCustomer},
+		{UserUnreachable, "The user could not be reached or did not respond in time", CategoryCustomerAction, AdviceRetryAfterCustomer},
+		{InvalidInitiator, "The initiator information is invalid (wrong PIN or security credential)", CategoryCustomerAction, AdviceRetryAfterCustomer},
+		{ProductNotPermitted, "The request is not permitted according to the product assignment", CategoryMerchantConfig, AdviceFixRequest},
+		{SecurityCredentialLocked, "The security credential is locked", CategoryMerchantConfig, AdviceFixRequest},
+		{PushRequestFailed, "An error occurred while sending the push request", CategoryTransient, AdviceRetry},
+		{OperatorDoesNotExist, "The operator does not exist", CategoryMerchantConfig, AdviceFixRequest},
+		{ServiceDenied, "The service request was denied, the access token or credentials are invalid", CategoryMerchantConfig, AdviceFixRequest},
+		{InvalidMSISDN, "Invalid MSISDN", CategoryMerchantConfig, AdviceFixRequest},
+		{InvalidAccountNumber, "Invalid account number", CategoryMerchantConfig, AdviceFixRequest},
+		{InvalidAmount, "Invalid amount", CategoryMerchantConfig, AdviceFixRequest},
+		{InvalidKYCDetails, "Invalid KYC details", CategoryMerchantConfig, AdviceFixRequest},
+		{InvalidShortcode, "Invalid shortcode", CategoryMerchantConfig, AdviceFixRequest},
+		{OtherC2BError, "Other error", CategoryFatal, AdviceDoNotRetry},
+		{InvalidAuthentication, "Invalid authentication passed, the consumer key or secret is wrong", CategoryMerchantConfig, AdviceFixRequest},
+		{InvalidGrantType, "Invalid grant type passed", CategoryMerchantConfig, AdviceFixRequest},
+		{InvalidRequestPayload, "Invalid request payload", CategoryMerchantConfig, AdviceFixRequest},
+		{BadRequest, "Bad request, a field of the request is invalid", CategoryMerchantConfig, AdviceFixRequest},
+		{ResourceNotFound, "Resource not found", CategoryMerchantConfig, AdviceFixRequest},
+		{InvalidAccessToken, "Invalid access token", CategoryMerchantConfig, AdviceFixRequest},
+		{InvalidAuthenticationHeader, "Invalid authentication header", CategoryMerchantConfig, AdviceFixRequest},
+		{APIProcessingError, "The request could not be processed", CategoryTransient, AdviceCheckStatus},
+		{SpikeArrestViolation, "Spike arrest violation, too many requests", CategoryTransient, AdviceRetry},
+		{QuotaViolation, "Quota violation, the request quota is exhausted", CategoryTransient, AdviceRetry},
+		{InternalServerError, "Internal server error", CategoryTransient, AdviceCheckStatus},
+		{ServiceUnavailable, "The service is unavailable", CategoryTransient, AdviceRetry},
+	} {
+		catalog[info.Code] = info
+	}
+}
+
+// Lookup returns the Info of code. Codes missing from the catalog are reported with
+// CategoryUnknown, or CategoryTransient for the "5xx.*" codes of server errors.
+func Lookup(code string) Info {
+	c := Code(code)
+	if info, ok := catalog[c]; ok {
+		return info
+	}
+
+	if len(c) > 4 && c[0] == '5' && c[3] == '.' {
+		return Info{Code: c, Description: "Server error", Category: CategoryTransient, Advice: AdviceCheckStatus}
+	}
+	return Info{Code: c, Description: "Unknown result code " + code, Category: CategoryUnknown, Advice: AdviceCheckStatus}
+}
+
+// LookupInt is like Lookup for codes reported as integers, such as STKCallback.ResultCode.
+func LookupInt(code int) Info {
+	return Lookup(strconv.Itoa(code))
+}
+
+// All returns the Info of every code of the catalog.
+func All() []Info {
+	infos := make([]Info, 0, len(catalog))
+	for _, info := range catalog {
+		infos = append(infos, info)
+	}
+	return infos
+}
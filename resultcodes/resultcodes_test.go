@@ -0,0 +1,48 @@
+package resultcodes_test
+
+import (
+	"testing"
+
+	"github.com/coleYab/mpesasdk/resultcodes"
+)
+
+func TestLookup(t *testing.T) {
+	tests := []struct {
+		code      string
+		category  resultcodes.Category
+		advice    resultcodes.Advice
+		retryable bool
+	}{
+		{"0", resultcodes.CategorySuccess, resultcodes.AdviceNone, false},
+		{"1032", resultcodes.CategoryCustomerAction, resultcodes.AdviceRetryAfterCustomer, true},
+		{"2001", resultcodes.CategoryCustomerAction, resultcodes.AdviceRetryAfterCustomer, true},
+		{"15", resultcodes.CategoryFatal, resultcodes.AdviceDoNotRetry, false},
+		{"400.008.01", resultcodes.CategoryMerchantConfig, resultcodes.AdviceFixRequest, false},
+		{"500.003.02", resultcodes.CategoryTransient, resultcodes.AdviceRetry, true},
+		{"500.002.1001", resultcodes.CategoryTransient, resultcodes.AdviceCheckStatus, false},
+		{"42", resultcodes.CategoryUnknown, resultcodes.AdviceCheckStatus, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.code, func(t *testing.T) {
+			info := resultcodes.Lookup(tt.code)
+			if info.Code != resultcodes.Code(tt.code) || info.Category != tt.category || info.Advice != tt.advice || info.Retryable() != tt.retryable {
+				t.Fatalf("unexpected info %+v", info)
+			}
+		})
+	}
+}
+
+func TestLookupInt(t *testing.T) {
+	if info := resultcodes.LookupInt(1037); info.Code != resultcodes.UserUnreachable || !info.Known() {
+		t.Fatalf("expecting the user unreachable code but got %+v", info)
+	}
+}
+
+func TestCatalogIsComplete(t *testing.T) {
+	for _, info := range resultcodes.All() {
+		if info.Description == "" || info.Category == "" || info.Advice == "" {
+			t.Errorf("incomplete entry %+v", info)
+		}
+	}
+}
@@ -0,0 +1,56 @@
+package results
+
+import (
+	"strconv"
+	"strings"
+	"time"
+)
+
+// AccountBalanceResult is the result of an AccountBalanceRequest.
+type AccountBalanceResult struct {
+	Result
+}
+
+// Balance is the balance of a single account of a shortcode.
+type Balance struct {
+	Account   string
+	Currency  string
+	Available float64
+	Current   float64
+	Reserved  float64
+	Uncleared float64
+}
+
+// Balances returns the balances of the queried shortcode.
+//
+// M-Pesa sends them as a single "AccountBalance" parameter formatted as
+// "Working Account|ETB|700000.00|700000.00|0.00|0.00&Utility Account|ETB|...".
+func (r *AccountBalanceResult) Balances() ([]Balance, bool) {
+	value, ok := r.Parameters().String("AccountBalance")
+	if !ok {
+		return nil, false
+	}
+
+	balances := []Balance{}
+	for _, account := range strings.Split(value, "&") {
+		fields := strings.Split(account, "|")
+		if len(fields) < 3 {
+			continue
+		}
+
+		balance := Balance{Account: fields[0], Currency: fields[1]}
+		amounts := []*float64{&balance.Available, &balance.Current, &balance.Reserved, &balance.Uncleared}
+		for i, amount := range amounts {
+			if i+2 < len(fields) {
+				*amount, _ = strconv.ParseFloat(fields[i+2], 64)
+			}
+		}
+		balances = append(balances, balance)
+	}
+	return balances, true
+}
+
+// CompletedTime returns the time the balance was read.
+func (r *AccountBalanceResult) CompletedTime() (time.Time, bool) {
+	return r.Parameters().Time("BOCompletedTime")
+}
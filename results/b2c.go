@@ -0,0 +1,49 @@
+package results
+
+import "time"
+
+// B2CResult is the result of a B2CRequest.
+type B2CResult struct {
+	Result
+}
+
+// TransactionAmount returns the amount sent to the customer.
+func (r *B2CResult) TransactionAmount() (float64, bool) {
+	return r.Parameters().Float("TransactionAmount")
+}
+
+// TransactionReceipt returns the M-Pesa receipt of the payment.
+func (r *B2CResult) TransactionReceipt() (string, bool) {
+	return r.Parameters().String("TransactionReceipt")
+}
+
+// ReceiverPartyPublicName returns the phone number and name of the customer that received the funds.
+func (r *B2CResult) ReceiverPartyPublicName() (string, bool) {
+	return r.Parameters().String("ReceiverPartyPublicName")
+}
+
+// TransactionCompletedDateTime returns the time the payment was completed.
+func (r *B2CResult) TransactionCompletedDateTime() (time.Time, bool) {
+	return r.Parameters().Time("TransactionCompletedDateTime")
+}
+
+// RecipientIsRegisteredCustomer reports whether the recipient is a registered M-Pesa customer.
+func (r *B2CResult) RecipientIsRegisteredCustomer() (bool, bool) {
+	value, ok := r.Parameters().String("B2CRecipientIsRegisteredCustomer")
+	return value == "Y", ok
+}
+
+// UtilityAccountAvailableFunds returns the balance of the utility account after the payment.
+func (r *B2CResult) UtilityAccountAvailableFunds() (float64, bool) {
+	return r.Parameters().Float("B2CUtilityAccountAvailableFunds")
+}
+
+// WorkingAccountAvailableFunds returns the balance of the working account after the payment.
+func (r *B2CResult) WorkingAccountAvailableFunds() (float64, bool) {
+	return r.Parameters().Float("B2CWorkingAccountAvailableFunds")
+}
+
+// ChargesPaidAccountAvailableFunds returns the balance of the charges paid account after the payment.
+func (r *B2CResult) ChargesPaidAccountAvailableFunds() (float64, bool) {
+	return r.Parameters().Float("B2CChargesPaidAccountAvailableFunds")
+}
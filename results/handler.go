@@ -0,0 +1,188 @@
+package results
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/coleYab/mpesasdk/common"
+	"github.com/coleYab/mpesasdk/tracing"
+	"github.com/coleYab/mpesasdk/utils"
+)
+
+// ResultFunc is invoked by the result handlers for every decoded result.
+// Returning an error makes the handler reject the notification.
+type ResultFunc[T any] func(ctx context.Context, result *T) error
+
+// HandlerOption configures a handler created with NewResultHandler or NewQueueTimeoutHandler.
+type HandlerOption func(*handlerConfig)
+
+// handlerConfig holds the settings collected from the options of a handler.
+type handlerConfig struct {
+	tracerProvider trace.TracerProvider
+}
+
+// WithTracerProvider sets the OpenTelemetry provider of the spans of the handler, the global
+// tracer provider by default.
+func WithTracerProvider(tp trace.TracerProvider) HandlerOption {
+	return func(c *handlerConfig) {
+		c.tracerProvider = tp
+	}
+}
+
+// newHandlerConfig applies the options of a handler.
+func newHandlerConfig(opts []HandlerOption) *handlerConfig {
+	cfg := &handlerConfig{}
+	for _, opt := range opts {
+		opt(cfg)
+	}
+	return cfg
+}
+
+// NewResultHandler returns an http.Handler for a `ResultURL` that decodes the generic Result envelope.
+//
+// The handler acknowledges M-Pesa with:
+//   - 200 and ResultCode "0" when fn succeeds.
+//   - 400 and ResultCode "1" when the payload cannot be decoded.
+//   - 500 and ResultCode "1" when fn returns an error.
+//
+// fn runs within a "mpesa.callback.result" span of the global tracer provider, or the one set
+// with WithTracerProvider, continuing the trace carried by the request, see the tracing package.
+func NewResultHandler(fn ResultFunc[Result], opts ...HandlerOption) http.Handler {
+	cfg := newHandlerConfig(opts)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !allowPost(w, r) {
+			return
+		}
+
+		ctx, span := tracing.StartCallback(cfg.tracerProvider, r, "mpesa.callback.result")
+		result, err := ParseResult(r.Body)
+		if err != nil {
+			tracing.End(span, err)
+			utils.WriteJSON(w, http.StatusBadRequest, common.CallbackResponse{ResultCode: "1", ResultDesc: "Rejected"})
+			return
+		}
+		span.SetAttributes(
+			tracing.ConversationIDKey.String(result.ConversationID),
+			tracing.OriginatorConversationIDKey.String(result.OriginatorConversationID),
+			tracing.ResultCodeKey.String(string(result.ResultCode)),
+		)
+
+		err = fn(ctx, result)
+		tracing.End(span, err)
+		acknowledge(w, err)
+	})
+}
+
+// NewB2CResultHandler returns an http.Handler for the `ResultURL` of B2C requests.
+func NewB2CResultHandler(fn ResultFunc[B2CResult], opts ...HandlerOption) http.Handler {
+	return NewResultHandler(func(ctx context.Context, result *Result) error {
+		return fn(ctx, &B2CResult{Result: *result})
+	}, opts...)
+}
+
+// NewTransactionStatusResultHandler returns an http.Handler for the `ResultURL` of transaction status requests.
+func NewTransactionStatusResultHandler(fn ResultFunc[TransactionStatusResult], opts ...HandlerOption) http.Handler {
+	return NewResultHandler(func(ctx context.Context, result *Result) error {
+		return fn(ctx, &TransactionStatusResult{Result: *result})
+	}, opts...)
+}
+
+// NewAccountBalanceResultHandler returns an http.Handler for the `ResultURL` of account balance requests.
+func NewAccountBalanceResultHandler(fn ResultFunc[AccountBalanceResult], opts ...HandlerOption) http.Handler {
+	return NewResultHandler(func(ctx context.Context, result *Result) error {
+		return fn(ctx, &AccountBalanceResult{Result: *result})
+	}, opts...)
+}
+
+// NewReversalResultHandler returns an http.Handler for the `ResultURL` of transaction reversal requests.
+func NewReversalResultHandler(fn ResultFunc[ReversalResult], opts ...HandlerOption) http.Handler {
+	return NewResultHandler(func(ctx context.Context, result *Result) error {
+		return fn(ctx, &ReversalResult{Result: *result})
+	}, opts...)
+}
+
+// QueueTimeout is the notification M-Pesa posts to the `QueueTimeOutURL` when a request
+// expired in its queue before being processed.
+//
+// Fields:
+//   - Result: The decoded Result envelope, nil when M-Pesa did not send one.
+//   - OriginatorConversationID: The OriginatorConversationID of the expired request, when present.
+//   - Body: The raw payload of the notification.
+type QueueTimeout struct {
+	Result                   *Result
+	OriginatorConversationID string
+	Body                     []byte
+}
+
+// ParseQueueTimeout decodes a queue timeout notification.
+func ParseQueueTimeout(r io.Reader) (*QueueTimeout, error) {
+	body, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+
+	timeout := &QueueTimeout{Body: body}
+	if result, err := parseResult(body); err == nil {
+		timeout.Result = result
+		timeout.OriginatorConversationID = result.OriginatorConversationID
+		return timeout, nil
+	}
+
+	// Without a Result envelope M-Pesa echoes the original request
+	request := struct {
+		OriginatorConversationID string `json:"OriginatorConversationID"`
+	}{}
+	if err := json.Unmarshal(body, &request); err != nil {
+		return nil, err
+	}
+	timeout.OriginatorConversationID = request.OriginatorConversationID
+	return timeout, nil
+}
+
+// NewQueueTimeoutHandler returns an http.Handler for the `QueueTimeOutURL` of asynchronous requests.
+// fn runs within a "mpesa.callback.timeout" span, like the function of NewResultHandler.
+func NewQueueTimeoutHandler(fn ResultFunc[QueueTimeout], opts ...HandlerOption) http.Handler {
+	cfg := newHandlerConfig(opts)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !allowPost(w, r) {
+			return
+		}
+
+		ctx, span := tracing.StartCallback(cfg.tracerProvider, r, "mpesa.callback.timeout")
+		timeout, err := ParseQueueTimeout(r.Body)
+		if err != nil {
+			tracing.End(span, err)
+			utils.WriteJSON(w, http.StatusBadRequest, common.CallbackResponse{ResultCode: "1", ResultDesc: "Rejected"})
+			return
+		}
+		span.SetAttributes(tracing.OriginatorConversationIDKey.String(timeout.OriginatorConversationID))
+
+		err = fn(ctx, timeout)
+		tracing.End(span, err)
+		acknowledge(w, err)
+	})
+}
+
+// allowPost rejects requests that are not POST requests.
+func allowPost(w http.ResponseWriter, r *http.Request) bool {
+	if r.Method == http.MethodPost {
+		return true
+	}
+
+	w.Header().Set("Allow", http.MethodPost)
+	utils.WriteJSON(w, http.StatusMethodNotAllowed, common.CallbackResponse{ResultCode: "1", ResultDesc: "Method not allowed"})
+	return false
+}
+
+// acknowledge answers M-Pesa according to the error returned by the user function.
+func acknowledge(w http.ResponseWriter, err error) {
+	if err != nil {
+		utils.WriteJSON(w, http.StatusInternalServerError, common.CallbackResponse{ResultCode: "1", ResultDesc: "Rejected"})
+		return
+	}
+	utils.WriteJSON(w, http.StatusOK, common.CallbackResponse{ResultCode: "0", ResultDesc: "Accepted"})
+}
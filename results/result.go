@@ -0,0 +1,203 @@
+// Package results provides typed models and HTTP handlers for the asynchronous results M-Pesa posts
+// to the `ResultURL` and `QueueTimeOutURL` of B2C, transaction status, account balance and
+// transaction reversal requests.
+//
+// The synchronous response of those APIs only acknowledges the request, the actual outcome is
+// delivered later as a Result envelope to the ResultURL.
+package results
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"time"
+
+	sdkError "github.com/coleYab/mpesasdk/errors"
+	"github.com/coleYab/mpesasdk/resultcodes"
+)
+
+// Code is a result code sent by M-Pesa. Depending on the API it is sent either as a JSON
+// number or as a string, Code accepts both forms.
+type Code string
+
+// UnmarshalJSON decodes a result code from a JSON number or string.
+func (c *Code) UnmarshalJSON(data []byte) error {
+	if len(data) > 0 && data[0] == '"' {
+		var s string
+		if err := json.Unmarshal(data, &s); err != nil {
+			return err
+		}
+		*c = Code(s)
+		return nil
+	}
+
+	var n json.Number
+	if err := json.Unmarshal(data, &n); err != nil {
+		return err
+	}
+	*c = Code(n.String())
+	return nil
+}
+
+// Parameter is a single key/value pair of the result parameters or reference data.
+type Parameter struct {
+	Key   string      `json:"Key"`
+	Value interface{} `json:"Value,omitempty"`
+}
+
+// Parameters is a list of key/value pairs. M-Pesa sends a single object instead of a
+// list when there is only one entry, Parameters accepts both forms.
+type Parameters []Parameter
+
+// UnmarshalJSON decodes parameters from a JSON array or a single object.
+func (p *Parameters) UnmarshalJSON(data []byte) error {
+	data = bytes.TrimSpace(data)
+	if len(data) > 0 && data[0] == '{' {
+		single := Parameter{}
+		if err := unmarshalNumbers(data, &single); err != nil {
+			return err
+		}
+		*p = Parameters{single}
+		return nil
+	}
+
+	list := []Parameter{}
+	if err := unmarshalNumbers(data, &list); err != nil {
+		return err
+	}
+	*p = list
+	return nil
+}
+
+// Get returns the value of the parameter with the given key.
+func (p Parameters) Get(key string) (interface{}, bool) {
+	for _, param := range p {
+		if param.Key == key && param.Value != nil {
+			return param.Value, true
+		}
+	}
+	return nil, false
+}
+
+// String returns the value of the parameter with the given key formatted as a string.
+func (p Parameters) String(key string) (string, bool) {
+	value, ok := p.Get(key)
+	if !ok {
+		return "", false
+	}
+
+	switch v := value.(type) {
+	case string:
+		return v, true
+	case json.Number:
+		return v.String(), true
+	}
+	return fmt.Sprint(value), true
+}
+
+// Float returns the value of the parameter with the given key as a number.
+func (p Parameters) Float(key string) (float64, bool) {
+	value, ok := p.String(key)
+	if !ok {
+		return 0, false
+	}
+
+	f, err := strconv.ParseFloat(value, 64)
+	return f, err == nil
+}
+
+// Time returns the value of the parameter with the given key as a time. M-Pesa uses both
+// the "DD.MM.YYYY HH:MM:SS" and the "YYYYMMDDHHMMSS" formats in East Africa Time.
+func (p Parameters) Time(key string) (time.Time, bool) {
+	value, ok := p.String(key)
+	if !ok {
+		return time.Time{}, false
+	}
+
+	for _, layout := range []string{"02.01.2006 15:04:05", "20060102150405"} {
+		if t, err := time.ParseInLocation(layout, value, time.FixedZone("EAT", 3*60*60)); err == nil {
+			return t, true
+		}
+	}
+	return time.Time{}, false
+}
+
+// ResultParameters wraps the list of result parameters as sent by M-Pesa.
+type ResultParameters struct {
+	ResultParameter Parameters `json:"ResultParameter"`
+}
+
+// ReferenceData wraps the list of reference items as sent by M-Pesa.
+type ReferenceData struct {
+	ReferenceItem Parameters `json:"ReferenceItem"`
+}
+
+// Result is the envelope M-Pesa posts to the `ResultURL` of asynchronous APIs.
+type Result struct {
+	ResultType               int              `json:"ResultType"`
+	ResultCode               Code             `json:"ResultCode"`
+	ResultDesc               string           `json:"ResultDesc"`
+	OriginatorConversationID string           `json:"OriginatorConversationID"`
+	ConversationID           string           `json:"ConversationID"`
+	TransactionID            string           `json:"TransactionID"`
+	ResultParameters         ResultParameters `json:"ResultParameters"`
+	ReferenceData            ReferenceData    `json:"ReferenceData"`
+}
+
+type resultEnvelope struct {
+	Result *Result `json:"Result"`
+}
+
+// ParseResult decodes a Result envelope.
+//
+// Returns:
+//   - The decoded result.
+//   - A ValidationError if the payload is not a Result envelope.
+func ParseResult(r io.Reader) (*Result, error) {
+	body, err := io.ReadAll(r)
+	if err != nil {
+		return nil, sdkError.ProcessingError(err.Error())
+	}
+	return parseResult(body)
+}
+
+func parseResult(body []byte) (*Result, error) {
+	envelope := resultEnvelope{}
+	if err := unmarshalNumbers(body, &envelope); err != nil {
+		return nil, sdkError.ValidationError("invalid result payload: " + err.Error())
+	}
+
+	if envelope.Result == nil {
+		return nil, sdkError.ValidationError("invalid result payload: missing Result")
+	}
+	return envelope.Result, nil
+}
+
+// Successful reports whether the request completed successfully.
+func (r *Result) Successful() bool {
+	return r.ResultCode == "0"
+}
+
+// Info describes the result code of the result, e.g. whether the request can be sent again.
+func (r *Result) Info() resultcodes.Info {
+	return resultcodes.Lookup(string(r.ResultCode))
+}
+
+// Parameters returns the result parameters of the result.
+func (r *Result) Parameters() Parameters {
+	return r.ResultParameters.ResultParameter
+}
+
+// References returns the reference items of the result.
+func (r *Result) References() Parameters {
+	return r.ReferenceData.ReferenceItem
+}
+
+// unmarshalNumbers decodes data into v keeping numbers as json.Number.
+func unmarshalNumbers(data []byte, v interface{}) error {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	return decoder.Decode(v)
+}
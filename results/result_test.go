@@ -0,0 +1,125 @@
+package results_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+
+	"github.com/coleYab/mpesasdk/results"
+)
+
+const b2cResult = `{
+  "Result": {
+    "ResultType": 0,
+    "ResultCode": 0,
+    "ResultDesc": "The service request is processed successfully.",
+    "OriginatorConversationID": "10571-7910404-1",
+    "ConversationID": "AG_20191219_00004e48cf7e3533f581",
+    "TransactionID": "NLJ41HAY6Q",
+    "ResultParameters": {
+      "ResultParameter": [
+        {"Key": "TransactionAmount", "Value": 10},
+        {"Key": "TransactionReceipt", "Value": "NLJ41HAY6Q"},
+        {"Key": "B2CRecipientIsRegisteredCustomer", "Value": "Y"},
+        {"Key": "ReceiverPartyPublicName", "Value": "251708374149 - John Doe"},
+        {"Key": "TransactionCompletedDateTime", "Value": "19.12.2019 11:45:50"}
+      ]
+    },
+    "ReferenceData": {
+      "ReferenceItem": {"Key": "QueueTimeoutURL", "Value": "https://example.com/timeout"}
+    }
+  }
+}`
+
+func TestB2CResultHandler(t *testing.T) {
+	var received *results.B2CResult
+	handler := results.NewB2CResultHandler(func(ctx context.Context, r *results.B2CResult) error {
+		received = r
+		return nil
+	})
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/result", strings.NewReader(b2cResult)))
+	if rec.Code != http.StatusOK || received == nil {
+		t.Fatalf("expecting result to be accepted but got %v", rec.Code)
+	}
+
+	if !received.Successful() || received.ConversationID != "AG_20191219_00004e48cf7e3533f581" {
+		t.Fatalf("unexpected result %+v", received.Result)
+	}
+
+	if amount, ok := received.TransactionAmount(); !ok || amount != 10 {
+		t.Fatalf("unexpected amount %v (%v)", amount, ok)
+	}
+
+	if registered, ok := received.RecipientIsRegisteredCustomer(); !ok || !registered {
+		t.Fatalf("expecting recipient to be registered")
+	}
+
+	if completed, ok := received.TransactionCompletedDateTime(); !ok || completed.Day() != 19 || completed.Second() != 50 {
+		t.Fatalf("unexpected completion time %v (%v)", completed, ok)
+	}
+
+	if url, ok := received.References().String("QueueTimeoutURL"); !ok || url != "https://example.com/timeout" {
+		t.Fatalf("expecting a single reference item to be decoded but got %v", url)
+	}
+}
+
+func TestAccountBalanceResult(t *testing.T) {
+	payload := `{"Result":{"ResultType":0,"ResultCode":"0","ResultDesc":"ok","ResultParameters":{"ResultParameter":[
+		{"Key":"AccountBalance","Value":"Working Account|ETB|700000.00|700000.00|0.00|0.00&Utility Account|ETB|228037.00|228037.00|0.00|0.00"},
+		{"Key":"BOCompletedTime","Value":20200109125710}]}}}`
+
+	result, err := results.ParseResult(strings.NewReader(payload))
+	if err != nil {
+		t.Fatalf("expecting result to parse but got: %v", err)
+	}
+
+	balance := results.AccountBalanceResult{Result: *result}
+	balances, ok := balance.Balances()
+	if !ok || len(balances) != 2 || balances[1].Account != "Utility Account" || balances[1].Available != 228037 {
+		t.Fatalf("unexpected balances %+v", balances)
+	}
+
+	if completed, ok := balance.CompletedTime(); !ok || completed.Year() != 2020 {
+		t.Fatalf("unexpected completion time %v", completed)
+	}
+}
+
+func TestQueueTimeoutHandler(t *testing.T) {
+	var received *results.QueueTimeout
+	handler := results.NewQueueTimeoutHandler(func(ctx context.Context, q *results.QueueTimeout) error {
+		received = q
+		return nil
+	})
+
+	rec := httptest.NewRecorder()
+	body := `{"InitiatorName":"apiuser","OriginatorConversationID":"abc-123","CommandID":"BusinessPayment"}`
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/timeout", strings.NewReader(body)))
+	if rec.Code != http.StatusOK || received == nil || received.OriginatorConversationID != "abc-123" {
+		t.Fatalf("unexpected queue timeout %+v (%v)", received, rec.Code)
+	}
+}
+
+func TestHandlersUseTheTracerProvider(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+
+	results.NewB2CResultHandler(func(ctx context.Context, r *results.B2CResult) error {
+		return nil
+	}, results.WithTracerProvider(tp)).ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodPost, "/result", strings.NewReader(b2cResult)))
+
+	results.NewQueueTimeoutHandler(func(ctx context.Context, q *results.QueueTimeout) error {
+		return nil
+	}, results.WithTracerProvider(tp)).ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodPost, "/timeout", strings.NewReader(`{"OriginatorConversationID":"abc-123"}`)))
+
+	spans := recorder.Ended()
+	if len(spans) != 2 || spans[0].Name() != "mpesa.callback.result" || spans[1].Name() != "mpesa.callback.timeout" {
+		t.Fatalf("expecting the spans of the handlers to be recorded but got %v", spans)
+	}
+}
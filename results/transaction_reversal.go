@@ -0,0 +1,43 @@
+package results
+
+import "time"
+
+// ReversalResult is the result of a TransactionReversalRequest.
+type ReversalResult struct {
+	Result
+}
+
+// OriginalTransactionID returns the ID of the transaction that was reversed.
+func (r *ReversalResult) OriginalTransactionID() (string, bool) {
+	return r.Parameters().String("OriginalTransactionID")
+}
+
+// Amount returns the reversed amount.
+func (r *ReversalResult) Amount() (float64, bool) {
+	return r.Parameters().Float("Amount")
+}
+
+// Charge returns the charge applied to the reversal.
+func (r *ReversalResult) Charge() (float64, bool) {
+	return r.Parameters().Float("Charge")
+}
+
+// DebitAccountBalance returns the balance of the debited account after the reversal.
+func (r *ReversalResult) DebitAccountBalance() (string, bool) {
+	return r.Parameters().String("DebitAccountBalance")
+}
+
+// CreditPartyPublicName returns the party the funds were returned to.
+func (r *ReversalResult) CreditPartyPublicName() (string, bool) {
+	return r.Parameters().String("CreditPartyPublicName")
+}
+
+// DebitPartyPublicName returns the party the funds were taken from.
+func (r *ReversalResult) DebitPartyPublicName() (string, bool) {
+	return r.Parameters().String("DebitPartyPublicName")
+}
+
+// TransCompletedTime returns the time the reversal was completed.
+func (r *ReversalResult) TransCompletedTime() (time.Time, bool) {
+	return r.Parameters().Time("TransCompletedTime")
+}
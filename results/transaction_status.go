@@ -0,0 +1,48 @@
+package results
+
+import "time"
+
+// TransactionStatusResult is the result of a TransactionStatusRequest.
+type TransactionStatusResult struct {
+	Result
+}
+
+// ReceiptNo returns the receipt number of the queried transaction.
+func (r *TransactionStatusResult) ReceiptNo() (string, bool) {
+	return r.Parameters().String("ReceiptNo")
+}
+
+// TransactionStatus returns the status of the queried transaction (e.g. "Completed").
+func (r *TransactionStatusResult) TransactionStatus() (string, bool) {
+	return r.Parameters().String("TransactionStatus")
+}
+
+// Amount returns the amount of the queried transaction.
+func (r *TransactionStatusResult) Amount() (float64, bool) {
+	return r.Parameters().Float("Amount")
+}
+
+// DebitPartyName returns the party the funds were taken from.
+func (r *TransactionStatusResult) DebitPartyName() (string, bool) {
+	return r.Parameters().String("DebitPartyName")
+}
+
+// CreditPartyName returns the party the funds were sent to.
+func (r *TransactionStatusResult) CreditPartyName() (string, bool) {
+	return r.Parameters().String("CreditPartyName")
+}
+
+// ReasonType returns the type of the queried transaction.
+func (r *TransactionStatusResult) ReasonType() (string, bool) {
+	return r.Parameters().String("ReasonType")
+}
+
+// InitiatedTime returns the time the queried transaction was initiated.
+func (r *TransactionStatusResult) InitiatedTime() (time.Time, bool) {
+	return r.Parameters().Time("InitiatedTime")
+}
+
+// FinalisedTime returns the time the queried transaction was completed.
+func (r *TransactionStatusResult) FinalisedTime() (time.Time, bool) {
+	return r.Parameters().Time("FinalisedTime")
+}
@@ -0,0 +1,120 @@
+// Package security generates the SecurityCredential required by initiator requests (B2C,
+// transaction status, account balance and transaction reversal).
+//
+// The SecurityCredential is the initiator password encrypted with the public key of the
+// M-Pesa X.509 certificate of the environment (RSA, PKCS#1 v1.5 padding) and base64 encoded,
+// the equivalent of:
+//
+//	printf '%s' "$PASSWORD" | openssl pkeyutl -encrypt -certin -inkey cert.cer | base64 -w0
+package security
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"embed"
+	"encoding/base64"
+	"encoding/pem"
+	"fmt"
+	"os"
+	"sync"
+
+	"github.com/coleYab/mpesasdk/common"
+	sdkError "github.com/coleYab/mpesasdk/errors"
+)
+
+// certificates holds the M-Pesa certificates shipped with the SDK, see certs/README.md.
+//
+//go:embed certs
+var certificates embed.FS
+
+// certificateFiles maps each environment to the file of its certificate in certificates.
+var certificateFiles = map[common.Enviroment]string{
+	common.SANDBOX:    "certs/sandbox.cer",
+	common.PRODUCTION: "certs/production.cer",
+}
+
+var (
+	registeredMu sync.RWMutex
+	registered   = map[common.Enviroment]*x509.Certificate{}
+)
+
+// ParseCertificate parses a PEM or DER encoded X.509 certificate holding an RSA public key.
+func ParseCertificate(data []byte) (*x509.Certificate, error) {
+	if block, _ := pem.Decode(data); block != nil {
+		data = block.Bytes
+	}
+
+	cert, err := x509.ParseCertificate(data)
+	if err != nil {
+		return nil, sdkError.ProcessingError("invalid certificate: " + err.Error())
+	}
+
+	if _, ok := cert.PublicKey.(*rsa.PublicKey); !ok {
+		return nil, sdkError.ProcessingError("certificate does not hold an RSA public key")
+	}
+	return cert, nil
+}
+
+// LoadCertificate reads and parses the certificate file at path, such as the .cer file
+// downloaded from the M-Pesa developer portal.
+func LoadCertificate(path string) (*x509.Certificate, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return ParseCertificate(data)
+}
+
+// RegisterCertificate makes cert the certificate of env, replacing the one shipped with the SDK.
+// It is meant to be called during initialization, e.g. after a certificate rotation.
+func RegisterCertificate(env common.Enviroment, cert *x509.Certificate) {
+	registeredMu.Lock()
+	defer registeredMu.Unlock()
+	registered[env] = cert
+}
+
+// Certificate returns the certificate of env: the one registered with RegisterCertificate,
+// or else the one shipped with the SDK.
+func Certificate(env common.Enviroment) (*x509.Certificate, error) {
+	registeredMu.RLock()
+	cert, ok := registered[env]
+	registeredMu.RUnlock()
+	if ok {
+		return cert, nil
+	}
+
+	file, ok := certificateFiles[env]
+	if !ok {
+		return nil, sdkError.EnvironmentError(fmt.Sprintf("unknown environment %v", env))
+	}
+
+	data, err := certificates.ReadFile(file)
+	if err != nil {
+		return nil, sdkError.EnvironmentError(fmt.Sprintf("no certificate available for %v, use RegisterCertificate or a custom certificate", env))
+	}
+	return ParseCertificate(data)
+}
+
+// EncryptPassword returns the SecurityCredential of the initiator password, encrypted with cert.
+func EncryptPassword(password string, cert *x509.Certificate) (string, error) {
+	key, ok := cert.PublicKey.(*rsa.PublicKey)
+	if !ok {
+		return "", sdkError.ProcessingError("certificate does not hold an RSA public key")
+	}
+
+	encrypted, err := rsa.EncryptPKCS1v15(rand.Reader, key, []byte(password))
+	if err != nil {
+		return "", sdkError.ProcessingError("unable to encrypt the initiator password: " + err.Error())
+	}
+	return base64.StdEncoding.EncodeToString(encrypted), nil
+}
+
+// SecurityCredential returns the SecurityCredential of the initiator password in env.
+func SecurityCredential(env common.Enviroment, password string) (string, error) {
+	cert, err := Certificate(env)
+	if err != nil {
+		return "", err
+	}
+	return EncryptPassword(password, cert)
+}
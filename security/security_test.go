@@ -0,0 +1,110 @@
+package security_test
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"encoding/pem"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/coleYab/mpesasdk/common"
+	"github.com/coleYab/mpesasdk/security"
+)
+
+// newCertificate returns a self-signed certificate in DER form with its private key.
+func newCertificate(t *testing.T) ([]byte, *rsa.PrivateKey) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "mpesa test"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return der, key
+}
+
+func decrypt(t *testing.T, key *rsa.PrivateKey, credential string) string {
+	encrypted, err := base64.StdEncoding.DecodeString(credential)
+	if err != nil {
+		t.Fatalf("credential %q is not base64: %v", credential, err)
+	}
+
+	password, err := rsa.DecryptPKCS1v15(rand.Reader, key, encrypted)
+	if err != nil {
+		t.Fatalf("unable to decrypt the credential: %v", err)
+	}
+	return string(password)
+}
+
+func TestEncryptPassword(t *testing.T) {
+	der, key := newCertificate(t)
+	encoded := map[string][]byte{
+		"der": der,
+		"pem": pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}),
+	}
+
+	for name, data := range encoded {
+		t.Run(name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "cert.cer")
+			if err := os.WriteFile(path, data, 0o600); err != nil {
+				t.Fatal(err)
+			}
+
+			cert, err := security.LoadCertificate(path)
+			if err != nil {
+				t.Fatalf("unable to load the certificate: %v", err)
+			}
+
+			credential, err := security.EncryptPassword("Safaricom999!*!", cert)
+			if err != nil {
+				t.Fatalf("unable to encrypt the password: %v", err)
+			}
+
+			if got := decrypt(t, key, credential); got != "Safaricom999!*!" {
+				t.Fatalf("expecting the password to round trip but got %q", got)
+			}
+		})
+	}
+}
+
+func TestParseCertificateRejectsGarbage(t *testing.T) {
+	if _, err := security.ParseCertificate([]byte("not a certificate")); err == nil {
+		t.Fatal("expecting an error for an invalid certificate")
+	}
+}
+
+func TestRegisteredCertificate(t *testing.T) {
+	der, key := newCertificate(t)
+	cert, err := security.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	security.RegisterCertificate(common.SANDBOX, cert)
+
+	credential, err := security.SecurityCredential(common.SANDBOX, "initiator-password")
+	if err != nil {
+		t.Fatalf("unable to generate the credential: %v", err)
+	}
+
+	if got := decrypt(t, key, credential); got != "initiator-password" {
+		t.Fatalf("expecting the password to round trip but got %q", got)
+	}
+
+	if _, err := security.Certificate(common.Enviroment("Staging")); err == nil {
+		t.Fatal("expecting an error for an unknown environment")
+	}
+}
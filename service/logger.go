@@ -1,31 +1,48 @@
 /*
-Package service provides a custom logger with configurable log levels.
-
-The Logger allows logging messages with different severity levels, which can be set and changed dynamically. The log levels supported are:
-- DEBUG: Logs detailed debugging information.
-- INFO: Logs general information messages.
-- WARN: Logs warning messages indicating potential issues.
-- ERROR: Logs error messages indicating failures or significant issues.
-
-Logger methods include:
-- Debug: Logs messages at the DEBUG level.
-- Info: Logs messages at the INFO level.
-- Warn: Logs messages at the WARN level.
-- Error: Logs messages at the ERROR level.
-
-Logger also provides:
-- SetLevel: Allows changing the log level.
-- ParseLevel: Converts a string to the corresponding LogLevel.
+Package service provides the StructuredLogger interface the SDK logs through, and its implementations.
+
+Messages are logged with a constant message and structured fields given as alternating keys and
+values, e.g. logger.Info("request succeeded", "endpoint", "/mpesa/b2c/v2/paymentrequest", "status", 200).
+The fields logged by the SDK include endpoint, method, status, latency, attempt, conversation_id,
+originator_conversation_id and request_id.
+
+Implementations:
+  - StdLogger: Writes lines such as "[INFO] request succeeded status=200" to an io.Writer
+    (os.Stdout by default), filtered by a configurable LogLevel.
+  - NewSlogLogger: Sends the messages to a *slog.Logger, e.g. one with a JSON handler.
+  - NopLogger: Discards every message.
+
+StdLogger also provides:
+  - SetLevel: Allows changing the log level.
+  - SetOutput: Allows redirecting the log lines.
+  - ParseLevel: Converts a string to the corresponding LogLevel.
+
+Logger, the printf-style logger created by NewLogger, is kept for compatibility. It is not a
+StructuredLogger: its methods format their message with their arguments.
 */
 package service
 
 import (
+	"context"
 	"fmt"
+	"io"
 	"log"
+	"log/slog"
 	"os"
 	"strings"
 )
 
+// StructuredLogger is the interface the SDK logs through. Its methods match those of
+// *slog.Logger, which therefore implements it.
+//
+// Each method logs msg with the fields given as alternating keys and values.
+type StructuredLogger interface {
+	Debug(msg string, keyvals ...any)
+	Info(msg string, keyvals ...any)
+	Warn(msg string, keyvals ...any)
+	Error(msg string, keyvals ...any)
+}
+
 // LogLevel represents the severity of log messages.
 type LogLevel int
 
@@ -37,17 +54,161 @@ const (
 	ERROR                 // Logs error messages indicating failures or significant issues.
 )
 
-// Logger is a custom logger with configurable log levels.
+// StdLogger is a StructuredLogger writing a line per message through a standard log.Logger,
+// filtered by a configurable log level.
+type StdLogger struct {
+	level  LogLevel    // The current log level of the logger.
+	logger *log.Logger // The underlying standard logger.
+}
+
+// NewStdLogger creates a new StdLogger with a specified log level.
+// The default log output is os.Stdout, and the log format is the standard log format.
+func NewStdLogger(level LogLevel) *StdLogger {
+	return &StdLogger{
+		level:  level,
+		logger: log.New(os.Stdout, "", log.LstdFlags),
+	}
+}
+
+// SetLevel sets the logging level for the StdLogger.
+// If set to a higher level, lower levels will be ignored.
+func (l *StdLogger) SetLevel(level LogLevel) {
+	l.level = level
+}
+
+// SetOutput redirects the log lines to w.
+func (l *StdLogger) SetOutput(w io.Writer) {
+	l.logger.SetOutput(w)
+}
+
+// Debug logs a message at the DEBUG level.
+// This method logs if the current log level is DEBUG or lower (i.e., if level <= DEBUG).
+func (l *StdLogger) Debug(msg string, keyvals ...any) {
+	if l.level <= DEBUG {
+		l.log("DEBUG", msg, keyvals...)
+	}
+}
+
+// Info logs a message at the INFO level.
+// This method logs if the current log level is INFO or lower (i.e., if level <= INFO).
+func (l *StdLogger) Info(msg string, keyvals ...any) {
+	if l.level <= INFO {
+		l.log("INFO", msg, keyvals...)
+	}
+}
+
+// Warn logs a message at the WARN level.
+// This method logs if the current log level is WARN or lower (i.e., if level <= WARN).
+func (l *StdLogger) Warn(msg string, keyvals ...any) {
+	if l.level <= WARN {
+		l.log("WARN", msg, keyvals...)
+	}
+}
+
+// Error logs a message at the ERROR level.
+// This method logs if the current log level is ERROR or lower (i.e., if level <= ERROR).
+func (l *StdLogger) Error(msg string, keyvals ...any) {
+	if l.level <= ERROR {
+		l.log("ERROR", msg, keyvals...)
+	}
+}
+
+// log is a helper function to format and print the log messages with the specified level.
+// The fields are appended as key=value, values holding spaces are quoted.
+func (l *StdLogger) log(level, msg string, keyvals ...any) {
+	var b strings.Builder
+	fmt.Fprintf(&b, "[%s] %s", level, msg)
+	for i := 0; i < len(keyvals); i += 2 {
+		key, value := "!BADKEY", keyvals[i]
+		if i+1 < len(keyvals) {
+			key, value = fmt.Sprint(keyvals[i]), keyvals[i+1]
+		}
+
+		formatted := fmt.Sprint(value)
+		if strings.ContainsAny(formatted, " \t\n\"=") {
+			formatted = fmt.Sprintf("%q", formatted)
+		}
+		fmt.Fprintf(&b, " %s=%s", key, formatted)
+	}
+	l.logger.Print(b.String())
+}
+
+// slogLogger is the StructuredLogger created by NewSlogLogger.
+type slogLogger struct {
+	logger *slog.Logger
+}
+
+// NewSlogLogger creates a StructuredLogger sending the messages and their fields to logger as slog
+// attributes, slog.Default() when logger is nil.
+//
+// Example:
+//
+//	logger := service.NewSlogLogger(slog.New(slog.NewJSONHandler(os.Stderr, nil)))
+func NewSlogLogger(logger *slog.Logger) StructuredLogger {
+	if logger == nil {
+		logger = slog.Default()
+	}
+	return &slogLogger{logger: logger}
+}
+
+// Debug implements StructuredLogger.
+func (l *slogLogger) Debug(msg string, keyvals ...any) {
+	l.logger.Log(context.Background(), slog.LevelDebug, msg, keyvals...)
+}
+
+// Info implements StructuredLogger.
+func (l *slogLogger) Info(msg string, keyvals ...any) {
+	l.logger.Log(context.Background(), slog.LevelInfo, msg, keyvals...)
+}
+
+// Warn implements StructuredLogger.
+func (l *slogLogger) Warn(msg string, keyvals ...any) {
+	l.logger.Log(context.Background(), slog.LevelWarn, msg, keyvals...)
+}
+
+// Error implements StructuredLogger.
+func (l *slogLogger) Error(msg string, keyvals ...any) {
+	l.logger.Log(context.Background(), slog.LevelError, msg, keyvals...)
+}
+
+// NopLogger is a StructuredLogger discarding every message.
+type NopLogger struct{}
+
+// NewNopLogger creates a StructuredLogger discarding every message.
+func NewNopLogger() NopLogger {
+	return NopLogger{}
+}
+
+// Debug implements StructuredLogger.
+func (NopLogger) Debug(msg string, keyvals ...any) {}
+
+// Info implements StructuredLogger.
+func (NopLogger) Info(msg string, keyvals ...any) {}
+
+// Warn implements StructuredLogger.
+func (NopLogger) Warn(msg string, keyvals ...any) {}
+
+// Error implements StructuredLogger.
+func (NopLogger) Error(msg string, keyvals ...any) {}
+
+// Logger is a custom logger with configurable log levels, formatting its messages like
+// fmt.Sprintf.
+//
+// Deprecated: Logger is kept for compatibility, the SDK logs through a StructuredLogger such as
+// the StdLogger created by NewStdLogger. Passing a Logger where a StructuredLogger is expected
+// compiles, but its fields are then formatted as printf arguments.
 type Logger struct {
-	level LogLevel  // The current log level of the logger.
-	logger *log.Logger  // The underlying standard logger.
+	level  LogLevel    // The current log level of the logger.
+	logger *log.Logger // The underlying standard logger.
 }
 
 // NewLogger creates a new Logger with a specified log level.
 // The default log output is os.Stdout, and the log format is the standard log format.
+//
+// Deprecated: Use NewStdLogger, whose methods take structured fields instead of printf arguments.
 func NewLogger(level LogLevel) *Logger {
 	return &Logger{
-		level: level,
+		level:  level,
 		logger: log.New(os.Stdout, "", log.LstdFlags),
 	}
 }
@@ -58,6 +219,11 @@ func (l *Logger) SetLevel(level LogLevel) {
 	l.level = level
 }
 
+// SetOutput redirects the log lines to w.
+func (l *Logger) SetOutput(w io.Writer) {
+	l.logger.SetOutput(w)
+}
+
 // Debug logs a message at the DEBUG level.
 // This method logs if the current log level is DEBUG or lower (i.e., if level <= DEBUG).
 func (l *Logger) Debug(format string, args ...interface{}) {
@@ -113,4 +279,3 @@ func ParseLevel(level string) (LogLevel, error) {
 		return INFO, fmt.Errorf("unknown log level: %s", level)
 	}
 }
-
@@ -0,0 +1,62 @@
+package service_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"strings"
+	"testing"
+
+	"github.com/coleYab/mpesasdk/service"
+)
+
+func TestStdLogger(t *testing.T) {
+	var out bytes.Buffer
+	logger := service.NewStdLogger(service.INFO)
+	logger.SetOutput(&out)
+
+	logger.Debug("hidden", "attempt", 1)
+	logger.Info("request succeeded", "status", 200, "endpoint", "/mpesa/b2c", "error", "bad request")
+
+	line := strings.TrimSpace(out.String())
+	if strings.Contains(line, "hidden") {
+		t.Fatalf("expecting debug messages to be filtered but got %q", line)
+	}
+	if !strings.HasSuffix(line, `[INFO] request succeeded status=200 endpoint=/mpesa/b2c error="bad request"`) {
+		t.Fatalf("unexpected log line %q", line)
+	}
+}
+
+func TestSlogLogger(t *testing.T) {
+	var out bytes.Buffer
+	var logger service.StructuredLogger = service.NewSlogLogger(slog.New(slog.NewJSONHandler(&out, nil)))
+
+	logger.Warn("retrying api request", "attempt", 2, "status", 503)
+
+	var record map[string]any
+	if err := json.Unmarshal(out.Bytes(), &record); err != nil {
+		t.Fatalf("expecting a JSON record but got %q", out.String())
+	}
+	if record["level"] != "WARN" || record["msg"] != "retrying api request" || record["attempt"] != 2.0 || record["status"] != 503.0 {
+		t.Fatalf("unexpected record %v", record)
+	}
+}
+
+func TestDeprecatedLoggerFormatsItsMessages(t *testing.T) {
+	var out bytes.Buffer
+	logger := service.NewLogger(service.INFO)
+	logger.SetOutput(&out)
+	logger.SetLevel(service.WARN)
+
+	logger.Info("hidden")
+	logger.Warn("retrying in %d seconds", 2)
+
+	line := strings.TrimSpace(out.String())
+	if strings.Contains(line, "hidden") || !strings.HasSuffix(line, "[WARN] retrying in 2 seconds") {
+		t.Fatalf("unexpected log line %q", line)
+	}
+}
+
+// A *slog.Logger can be used as a StructuredLogger without adapter
+var _ service.StructuredLogger = (*slog.Logger)(nil)
+var _ service.StructuredLogger = service.NewNopLogger()
@@ -0,0 +1,149 @@
+package mpesasdk
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"time"
+
+	"github.com/coleYab/mpesasdk/c2b"
+	sdkError "github.com/coleYab/mpesasdk/errors"
+	"github.com/coleYab/mpesasdk/resultcodes"
+	"github.com/coleYab/mpesasdk/tracker"
+)
+
+// STKPushStatus is the final status of an STK push awaited with STKPushAndWait.
+type STKPushStatus string
+
+// Statuses of an STK push.
+const (
+	STKPushPaid      STKPushStatus = "Paid"      // The customer paid.
+	STKPushCancelled STKPushStatus = "Cancelled" // The customer cancelled the PIN prompt.
+	STKPushTimedOut  STKPushStatus = "TimedOut"  // The customer did not answer the PIN prompt in time or could not be reached.
+	STKPushFailed    STKPushStatus = "Failed"    // The payment failed for another reason, see the ResultCode.
+)
+
+// STKPushOutcome is the final outcome of an STK push.
+//
+// Fields:
+//   - Status: The final status of the payment.
+//   - MerchantRequestID, CheckoutRequestID: The identifiers of the STK push.
+//   - ResultCode, ResultDesc: The result reported by M-Pesa (e.g. "0", "1032").
+//   - Receipt: The M-Pesa receipt number of a paid STK push. The STK push query does not report
+//     it, it is empty when the outcome was not received in the callback.
+//   - Callback: The callback recorded by the tracker, nil when the outcome was queried.
+type STKPushOutcome struct {
+	Status            STKPushStatus
+	MerchantRequestID string
+	CheckoutRequestID string
+	ResultCode        string
+	ResultDesc        string
+	Receipt           string
+	Callback          *c2b.STKCallback
+}
+
+// complete sets the status of the outcome from the result code of the STK push.
+func (o *STKPushOutcome) complete(code, description string) {
+	o.ResultCode, o.ResultDesc = code, description
+	switch resultcodes.Code(code) {
+	case resultcodes.Success:
+		o.Status = STKPushPaid
+	case resultcodes.CancelledByUser:
+		o.Status = STKPushCancelled
+	case resultcodes.TransactionExpired, resultcodes.UserUnreachable:
+		o.Status = STKPushTimedOut
+	default:
+		o.Status = STKPushFailed
+	}
+}
+
+// STKPushAndWait sends an STK push and waits for its final outcome.
+//
+// The outcome is taken from the callback of the STK push when the client has a tracker, see
+// WithTracker, and its STK callback handler is wrapped by the tracker. When the callback did not
+// arrive in time, the status of the STK push is queried until it is final, see
+// WithSTKPushPolling; a queried outcome is also recorded in the tracker. Without a tracker the
+// status is queried every polling interval from the start.
+//
+// Parameters:
+//   - ctx: The context bounding the STK push and the wait, e.g. with the time the customer has to pay.
+//   - passkey: The STK passkey, the one of WithPasskey when empty.
+//   - req: The STK push.
+//
+// Returns:
+//   - The outcome of the STK push, holding its identifiers even when an error is returned.
+//   - An error if the STK push was not accepted, its status could not be queried, or ctx is done
+//     before the outcome is known.
+//
+// Example:
+//
+//	ctx, cancel := context.WithTimeout(ctx, 2*time.Minute)
+//	defer cancel()
+//	outcome, err := client.STKPushAndWait(ctx, "", req)
+//	if err == nil && outcome.Status == mpesasdk.STKPushPaid {
+//	    fulfil(order, outcome.Receipt)
+//	}
+func (m *MpesaClient) STKPushAndWait(ctx context.Context, passkey string, req c2b.STKPushPaymentRequest) (STKPushOutcome, error) {
+	res, err := m.STKPushPaymentRequestCtx(ctx, passkey, req)
+	if err != nil {
+		return STKPushOutcome{}, err
+	}
+	outcome := STKPushOutcome{MerchantRequestID: res.MerchantRequestID, CheckoutRequestID: res.CheckoutRequestID}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	// The STK push was tracked by the interceptor of the tracker before it was returned
+	callbacks := make(chan tracker.Payment, 1)
+	if m.tracker != nil {
+		go func() {
+			if payment, err := m.tracker.Await(ctx, res.CheckoutRequestID); err == nil {
+				callbacks <- payment
+			}
+		}()
+	}
+
+	// Without a tracker no callback can arrive, the status is queried from the start
+	wait := m.stkPushPollAfter
+	if m.tracker == nil {
+		wait = m.stkPushPollEvery
+	}
+	poll := time.NewTimer(wait)
+	defer poll.Stop()
+	query := c2b.STKPushQueryRequest{BusinessShortCode: req.BusinessShortCode, CheckoutRequestID: res.CheckoutRequestID}
+	for {
+		select {
+		case <-ctx.Done():
+			return outcome, ctx.Err()
+		case payment := <-callbacks:
+			outcome.complete(payment.ResultCode, payment.ResultDesc)
+			outcome.Receipt = payment.TransactionID
+			outcome.Callback, _ = payment.Result.(*c2b.STKCallback)
+			return outcome, nil
+		case <-poll.C:
+		}
+
+		status, err := m.QuerySTKPushCtx(ctx, passkey, query)
+		switch {
+		case err == nil:
+			outcome.complete(status.ResultCode, status.ResultDesc)
+			if code, err := strconv.Atoi(status.ResultCode); err == nil && m.tracker != nil {
+				m.tracker.HandleSTKCallback(ctx, &c2b.STKCallback{
+					MerchantRequestID: res.MerchantRequestID,
+					CheckoutRequestID: res.CheckoutRequestID,
+					ResultCode:        code,
+					ResultDesc:        status.ResultDesc,
+				})
+			}
+			return outcome, nil
+		case ctx.Err() != nil:
+			return outcome, ctx.Err()
+		case errors.Is(err, sdkError.ErrValidation) || errors.Is(err, sdkError.ErrAuth):
+			return outcome, err
+		}
+
+		// The customer has not answered yet ("500.001.1001"), or the query failed: ask again
+		m.logger.Debug("stk push is pending", "checkout_request_id", res.CheckoutRequestID, "error", err)
+		poll.Reset(m.stkPushPollEvery)
+	}
+}
@@ -0,0 +1,137 @@
+// Package tracing holds the OpenTelemetry instrumentation shared by the packages of the SDK.
+//
+// The client creates a span per call (e.g. "mpesa.B2C") with a child span per attempt
+// ("mpesa.attempt") and per token acquisition ("mpesa.token"). The callback handlers create a
+// span per notification (e.g. "mpesa.callback.stk").
+//
+// M-Pesa does not forward trace headers to callbacks, so the trace context of an STK push can be
+// carried in a "traceparent" query parameter of its CallBackURL instead, and extracted by the
+// callback handler: the push and its callback appear in one trace. The client only adds it when
+// enabled with mpesasdk.WithCallbackTracePropagation.
+package tracing
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// ScopeName is the instrumentation scope of the spans of the SDK.
+const ScopeName = "github.com/coleYab/mpesasdk"
+
+// Attributes of the spans of the SDK.
+const (
+	OperationKey                = attribute.Key("mpesa.operation")
+	EndpointKey                 = attribute.Key("mpesa.endpoint")
+	EnvironmentKey              = attribute.Key("mpesa.environment")
+	AttemptKey                  = attribute.Key("mpesa.attempt")
+	AttemptsKey                 = attribute.Key("mpesa.attempts")
+	ResponseCodeKey             = attribute.Key("mpesa.response_code")
+	ResultCodeKey               = attribute.Key("mpesa.result_code")
+	ErrorCodeKey                = attribute.Key("mpesa.error_code")
+	ConversationIDKey           = attribute.Key("mpesa.conversation_id")
+	OriginatorConversationIDKey = attribute.Key("mpesa.originator_conversation_id")
+	MerchantRequestIDKey        = attribute.Key("mpesa.merchant_request_id")
+	CheckoutRequestIDKey        = attribute.Key("mpesa.checkout_request_id")
+	TokenCachedKey              = attribute.Key("mpesa.token.cached")
+	HTTPMethodKey               = attribute.Key("http.request.method")
+	HTTPStatusCodeKey           = attribute.Key("http.response.status_code")
+)
+
+// traceContext propagates the trace context of callback URLs, whatever the global propagator.
+var traceContext = propagation.TraceContext{}
+
+// Tracer returns the tracer of the SDK from tp, from the global tracer provider when tp is nil.
+func Tracer(tp trace.TracerProvider) trace.Tracer {
+	if tp == nil {
+		tp = otel.GetTracerProvider()
+	}
+	return tp.Tracer(ScopeName)
+}
+
+// End ends span, recording err as its status when not nil.
+func End(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
+
+// InjectURL returns rawURL with the trace context of ctx appended to its query, so that the
+// callback posted to it can be linked to the trace. The rest of rawURL is kept byte for byte,
+// except for trace context parameters it already holds, which are replaced. rawURL is returned
+// as is when ctx holds no valid span or rawURL cannot be parsed.
+func InjectURL(ctx context.Context, rawURL string) string {
+	if !trace.SpanContextFromContext(ctx).IsValid() {
+		return rawURL
+	}
+	if _, err := url.Parse(rawURL); err != nil {
+		return rawURL
+	}
+
+	carrier := propagation.MapCarrier{}
+	traceContext.Inject(ctx, carrier)
+
+	rest, fragment, hasFragment := strings.Cut(rawURL, "#")
+	rest, query, _ := strings.Cut(rest, "?")
+	var params []string
+	for _, param := range strings.Split(query, "&") {
+		key, _, _ := strings.Cut(param, "=")
+		if key, err := url.QueryUnescape(key); param == "" || err == nil && carrier.Get(key) != "" {
+			continue
+		}
+		params = append(params, param)
+	}
+	for _, key := range traceContext.Fields() {
+		if value := carrier.Get(key); value != "" {
+			params = append(params, url.QueryEscape(key)+"="+url.QueryEscape(value))
+		}
+	}
+
+	injected := rest + "?" + strings.Join(params, "&")
+	if hasFragment {
+		injected += "#" + fragment
+	}
+	return injected
+}
+
+// Extract returns the context of a callback request carrying the trace context found in its
+// headers, through the global propagator, or in the query parameters added by InjectURL, which
+// take precedence.
+func Extract(r *http.Request) context.Context {
+	ctx := otel.GetTextMapPropagator().Extract(r.Context(), propagation.HeaderCarrier(r.Header))
+
+	query := r.URL.Query()
+	carrier := propagation.MapCarrier{}
+	for _, key := range traceContext.Fields() {
+		if value := query.Get(key); value != "" {
+			carrier.Set(key, value)
+		}
+	}
+	if len(carrier) == 0 {
+		return ctx
+	}
+	return traceContext.Extract(ctx, carrier)
+}
+
+// StartCallback starts the span of a callback request named name, continuing the trace
+// extracted with Extract. A span already in the context of r, e.g. one started by an HTTP
+// middleware, is linked when it belongs to another trace.
+func StartCallback(tp trace.TracerProvider, r *http.Request, name string) (context.Context, trace.Span) {
+	opts := []trace.SpanStartOption{trace.WithSpanKind(trace.SpanKindServer)}
+
+	ctx := Extract(r)
+	current := trace.SpanContextFromContext(r.Context())
+	if current.IsValid() && current.TraceID() != trace.SpanContextFromContext(ctx).TraceID() {
+		opts = append(opts, trace.WithLinks(trace.Link{SpanContext: current}))
+	}
+	return Tracer(tp).Start(ctx, name, opts...)
+}
@@ -0,0 +1,52 @@
+package tracing_test
+
+import (
+	"context"
+	"net/http/httptest"
+	"testing"
+
+	"go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+
+	"github.com/coleYab/mpesasdk/tracing"
+)
+
+func TestCallbackContinuesTheTraceOfTheCallbackURL(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	tp := trace.NewTracerProvider(trace.WithSpanProcessor(recorder))
+
+	ctx, push := tracing.Tracer(tp).Start(context.Background(), "mpesa.STKPush")
+	callbackURL := tracing.InjectURL(ctx, "https://example.com/stk?order=42")
+	push.End()
+
+	r := httptest.NewRequest("POST", callbackURL, nil)
+	if r.URL.Query().Get("order") != "42" {
+		t.Fatalf("expecting the query to be kept in %v", callbackURL)
+	}
+
+	_, callback := tracing.StartCallback(tp, r, "mpesa.callback.stk")
+	callback.End()
+
+	spans := recorder.Ended()
+	if len(spans) != 2 || spans[1].Parent().SpanID() != spans[0].SpanContext().SpanID() ||
+		spans[1].SpanContext().TraceID() != spans[0].SpanContext().TraceID() {
+		t.Fatalf("expecting the callback span to be a child of the push span but got %+v", spans)
+	}
+}
+
+func TestInjectURLWithoutSpan(t *testing.T) {
+	if got := tracing.InjectURL(context.Background(), "https://example.com/stk"); got != "https://example.com/stk" {
+		t.Fatalf("expecting the URL to be left as is but got %v", got)
+	}
+}
+
+func TestInjectURLKeepsTheQuery(t *testing.T) {
+	ctx, span := tracing.Tracer(trace.NewTracerProvider()).Start(context.Background(), "mpesa.STKPush")
+	defer span.End()
+
+	got := tracing.InjectURL(ctx, "https://example.com/stk?b=2&a=%7E1&traceparent=stale#done")
+	traceparent := "traceparent=00-" + span.SpanContext().TraceID().String() + "-" + span.SpanContext().SpanID().String() + "-01"
+	if want := "https://example.com/stk?b=2&a=%7E1&" + traceparent + "#done"; got != want {
+		t.Fatalf("expecting %v but got %v", want, got)
+	}
+}
@@ -0,0 +1,517 @@
+// Package tracker follows payments and other asynchronous requests from their acknowledgement
+// by the API to their final outcome, posted later by M-Pesa to a callback URL.
+//
+// A Tracker records every request acknowledged by the client, indexed by all of its
+// identifiers (MerchantRequestID, CheckoutRequestID, ConversationID, OriginatorConversationID
+// and, once completed, the TransactionID of M-Pesa), and updates its State when the callback
+// handlers of the SDK receive its result:
+//
+//	payments := tracker.New()
+//	client, err := mpesasdk.New(key, secret, mpesasdk.WithTracker(payments))
+//
+//	http.Handle("/mpesa/stk", c2b.NewSTKCallbackHandler(payments.STKCallbackFunc(onCallback)))
+//	http.Handle("/mpesa/result", results.NewResultHandler(payments.ResultFunc(onResult)))
+//	http.Handle("/mpesa/timeout", results.NewQueueTimeoutHandler(payments.QueueTimeoutFunc(onTimeout)))
+//
+//	res, err := client.STKPushPaymentRequestCtx(ctx, passkey, req)
+//	payment, err := payments.Await(ctx, res.CheckoutRequestID)
+//
+// The Tracker keeps its state in memory: the callbacks have to be received by the process that
+// sent the requests.
+package tracker
+
+import (
+	"context"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/coleYab/mpesasdk/account"
+	"github.com/coleYab/mpesasdk/b2c"
+	"github.com/coleYab/mpesasdk/c2b"
+	"github.com/coleYab/mpesasdk/client"
+	"github.com/coleYab/mpesasdk/common"
+	sdkError "github.com/coleYab/mpesasdk/errors"
+	"github.com/coleYab/mpesasdk/resultcodes"
+	"github.com/coleYab/mpesasdk/results"
+	"github.com/coleYab/mpesasdk/transaction"
+)
+
+// ErrNotTracked is returned by Await for an identifier no tracked payment has.
+var ErrNotTracked = sdkError.CustomError("NOT_TRACKED", "no payment is tracked with this identifier")
+
+// State is the stage of the lifecycle of a payment.
+type State string
+
+// States of a payment. Pending is the only state that is not final, except for Succeeded
+// payments that can still be Reversed.
+const (
+	Pending   State = "Pending"   // Acknowledged by the API, waiting for its result.
+	Succeeded State = "Succeeded" // Completed successfully.
+	Failed    State = "Failed"    // Rejected, e.g. cancelled by the customer or insufficient funds.
+	TimedOut  State = "TimedOut"  // Expired, e.g. the customer could not be reached or the request timed out in the queue.
+	Reversed  State = "Reversed"  // Completed, then reversed with a transaction reversal.
+)
+
+// Payment is a request tracked by a Tracker.
+//
+// Fields:
+//   - Operation: The operation of the request (e.g., common.STKPushOperation).
+//   - MerchantRequestID, CheckoutRequestID: The identifiers of an STK push.
+//   - ConversationID, OriginatorConversationID: The identifiers of the other asynchronous requests.
+//   - TransactionID: The M-Pesa transaction of a completed payment, e.g. the receipt number of an STK push.
+//   - OriginalTransactionID: The transaction a reversal reverses.
+//   - State: The stage of the payment.
+//   - ResultCode, ResultDesc: The result reported by M-Pesa, empty while Pending.
+//   - Result: The notification that completed the payment: a *c2b.STKCallback, a
+//     *results.Result or a *results.QueueTimeout.
+//   - CreatedAt, UpdatedAt: When the payment was first seen and last changed.
+type Payment struct {
+	Operation                common.Operation
+	MerchantRequestID        string
+	CheckoutRequestID        string
+	ConversationID           string
+	OriginatorConversationID string
+	TransactionID            string
+	OriginalTransactionID    string
+	State                    State
+	ResultCode               string
+	ResultDesc               string
+	Result                   interface{}
+	CreatedAt                time.Time
+	UpdatedAt                time.Time
+}
+
+// ids returns the non-empty identifiers of the payment.
+func (p *Payment) ids() []string {
+	var ids []string
+	for _, id := range []string{p.MerchantRequestID, p.CheckoutRequestID, p.ConversationID, p.OriginatorConversationID, p.TransactionID} {
+		if id != "" {
+			ids = append(ids, id)
+		}
+	}
+	return ids
+}
+
+// merge copies the operation and identifiers of other the payment is missing.
+func (p *Payment) merge(other Payment) {
+	fill := func(field *string, value string) {
+		if *field == "" {
+			*field = value
+		}
+	}
+	if p.Operation == "" {
+		p.Operation = other.Operation
+	}
+	fill(&p.MerchantRequestID, other.MerchantRequestID)
+	fill(&p.CheckoutRequestID, other.CheckoutRequestID)
+	fill(&p.ConversationID, other.ConversationID)
+	fill(&p.OriginatorConversationID, other.OriginatorConversationID)
+	fill(&p.TransactionID, other.TransactionID)
+	fill(&p.OriginalTransactionID, other.OriginalTransactionID)
+}
+
+// entry is a tracked payment.
+type entry struct {
+	payment Payment
+	done    chan struct{} // closed once the payment left Pending
+}
+
+// Tracker tracks payments in memory. It is safe for concurrent use.
+type Tracker struct {
+	mu        sync.Mutex
+	entries   map[string]*entry
+	retention time.Duration
+	pruned    time.Time
+}
+
+// Option configures a Tracker created with New.
+type Option func(*Tracker)
+
+// WithRetention sets how long a payment is kept after its last change (24 hours by default).
+func WithRetention(retention time.Duration) Option {
+	return func(t *Tracker) {
+		t.retention = retention
+	}
+}
+
+// New creates an empty Tracker.
+func New(opts ...Option) *Tracker {
+	t := &Tracker{
+		entries:   map[string]*entry{},
+		retention: 24 * time.Hour,
+		pruned:    time.Now(),
+	}
+	for _, opt := range opts {
+		opt(t)
+	}
+	return t
+}
+
+// Track records a payment, Pending unless its State is set. A payment already tracked under one
+// of its identifiers is completed with the identifiers of p instead.
+//
+// Returns:
+//   - The tracked payment.
+func (t *Tracker) Track(p Payment) Payment {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.prune()
+	e := t.lookup(p.ids())
+	if e == nil {
+		e = t.add(p)
+	}
+	e.payment.merge(p)
+	t.index(e)
+	t.reverseOriginal(e)
+	return e.payment
+}
+
+// Get returns the payment tracked under id, which may be any of its identifiers.
+func (t *Tracker) Get(id string) (Payment, bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	e, ok := t.entries[id]
+	if !ok {
+		return Payment{}, false
+	}
+	return e.payment, true
+}
+
+// State returns the state of the payment tracked under id.
+func (t *Tracker) State(id string) (State, bool) {
+	p, ok := t.Get(id)
+	return p.State, ok
+}
+
+// List returns the tracked payments in one of states, or all of them without states, oldest first.
+func (t *Tracker) List(states ...State) []Payment {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	seen := map[*entry]bool{}
+	var payments []Payment
+	for _, e := range t.entries {
+		if seen[e] {
+			continue
+		}
+		seen[e] = true
+
+		if len(states) == 0 || hasState(states, e.payment.State) {
+			payments = append(payments, e.payment)
+		}
+	}
+	sort.Slice(payments, func(i, j int) bool {
+		return payments[i].CreatedAt.Before(payments[j].CreatedAt)
+	})
+	return payments
+}
+
+// Remove stops tracking the payment tracked under id.
+func (t *Tracker) Remove(id string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if e, ok := t.entries[id]; ok {
+		t.remove(e)
+	}
+}
+
+// Await waits until the payment tracked under id leaves Pending.
+//
+// Parameters:
+//   - ctx: The context bounding the wait.
+//   - id: Any of the identifiers of the payment.
+//
+// Returns:
+//   - The payment, in its final state unless an error is returned.
+//   - ErrNotTracked if no payment is tracked under id, or the error of ctx once done.
+func (t *Tracker) Await(ctx context.Context, id string) (Payment, error) {
+	t.mu.Lock()
+	e, ok := t.entries[id]
+	t.mu.Unlock()
+	if !ok {
+		return Payment{}, ErrNotTracked
+	}
+
+	select {
+	case <-e.done:
+	case <-ctx.Done():
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if e.payment.State == Pending {
+		return e.payment, ctx.Err()
+	}
+	return e.payment, nil
+}
+
+// Interceptor returns the client.Interceptor tracking the requests acknowledged by the API:
+// STK pushes, B2C payments, reversals, transaction status and account balance queries. It is
+// added to a client with mpesasdk.WithTracker.
+func (t *Tracker) Interceptor() client.Interceptor {
+	return func(next client.RoundTrip) client.RoundTrip {
+		return func(ctx context.Context, call *client.Call) (interface{}, error) {
+			res, err := next(ctx, call)
+			if err == nil {
+				t.trackCall(call, res)
+			}
+			return res, err
+		}
+	}
+}
+
+// trackCall tracks the request of call from its acknowledgement res.
+func (t *Tracker) trackCall(call *client.Call, res interface{}) {
+	p := Payment{Operation: call.Operation}
+	switch r := res.(type) {
+	case c2b.STKPushRequestSuccessResponse:
+		p.MerchantRequestID, p.CheckoutRequestID = r.MerchantRequestID, r.CheckoutRequestID
+	case b2c.B2CSuccessResponse:
+		p.ConversationID, p.OriginatorConversationID = r.ConversationID, r.OriginatorConversatonId
+	case transaction.TransactionReversalSuccessResponse:
+		p.ConversationID, p.OriginatorConversationID = r.ConversationID, r.OriginatorConversatonId
+	case transaction.TransactionStatusSuccessResponse:
+		p.ConversationID, p.OriginatorConversationID = r.ConversationID, r.OriginatorConversatonId
+	case account.AccountBalanceSuccessResponse:
+		p.ConversationID, p.OriginatorConversationID = r.ConversationID, r.OriginatorConversatonId
+	default:
+		return
+	}
+
+	// The acknowledgement may leave out the OriginatorConversationID sent with the request
+	switch req := call.Request.(type) {
+	case *b2c.B2CRequest:
+		p.merge(Payment{OriginatorConversationID: req.OriginatorConversationID})
+	case *transaction.TransactionReversalRequest:
+		p.merge(Payment{OriginatorConversationID: req.OriginatorConversationID, OriginalTransactionID: req.TransactionID})
+	case *transaction.TransactionStatusRequest:
+		p.merge(Payment{OriginatorConversationID: req.OriginatorConversationID})
+	case *account.AccountBalanceRequest:
+		p.merge(Payment{OriginatorConversationID: req.OriginatorConversationID})
+	}
+	t.Track(p)
+}
+
+// HandleSTKCallback completes the STK push the callback belongs to. It has the signature of a
+// c2b.STKCallbackFunc, see STKCallbackFunc to chain it with another function.
+func (t *Tracker) HandleSTKCallback(ctx context.Context, callback *c2b.STKCallback) error {
+	code := resultcodes.Code(strconv.Itoa(callback.ResultCode))
+	update := Payment{
+		Operation:         common.STKPushOperation,
+		MerchantRequestID: callback.MerchantRequestID,
+		CheckoutRequestID: callback.CheckoutRequestID,
+		State:             stateOf(code),
+		ResultCode:        string(code),
+		ResultDesc:        callback.ResultDesc,
+		Result:            callback,
+	}
+	update.TransactionID, _ = callback.MpesaReceiptNumber()
+	t.complete(update)
+	return nil
+}
+
+// HandleResult completes the request the result belongs to. The successful result of a
+// reversal also moves the reversed payment, when tracked, to Reversed. It has the signature of
+// a results.ResultFunc, pass &r.Result for the typed results, e.g. of NewB2CResultHandler.
+func (t *Tracker) HandleResult(ctx context.Context, result *results.Result) error {
+	update := Payment{
+		ConversationID:           result.ConversationID,
+		OriginatorConversationID: result.OriginatorConversationID,
+		TransactionID:            result.TransactionID,
+		State:                    stateOf(resultcodes.Code(result.ResultCode)),
+		ResultCode:               string(result.ResultCode),
+		ResultDesc:               result.ResultDesc,
+		Result:                   result,
+	}
+	update.OriginalTransactionID, _ = (&results.ReversalResult{Result: *result}).OriginalTransactionID()
+	t.complete(update)
+	return nil
+}
+
+// HandleQueueTimeout times out the request the notification belongs to.
+func (t *Tracker) HandleQueueTimeout(ctx context.Context, timeout *results.QueueTimeout) error {
+	update := Payment{
+		OriginatorConversationID: timeout.OriginatorConversationID,
+		State:                    TimedOut,
+		ResultDesc:               "The request timed out in the queue",
+		Result:                   timeout,
+	}
+	if timeout.Result != nil {
+		update.ConversationID = timeout.Result.ConversationID
+		update.ResultCode = string(timeout.Result.ResultCode)
+		update.ResultDesc = timeout.Result.ResultDesc
+	}
+	t.complete(update)
+	return nil
+}
+
+// STKCallbackFunc returns a c2b.STKCallbackFunc calling next, when not nil, then
+// HandleSTKCallback if next succeeded: waiters see the payment complete once next processed it.
+func (t *Tracker) STKCallbackFunc(next c2b.STKCallbackFunc) c2b.STKCallbackFunc {
+	return func(ctx context.Context, callback *c2b.STKCallback) error {
+		if next != nil {
+			if err := next(ctx, callback); err != nil {
+				return err
+			}
+		}
+		return t.HandleSTKCallback(ctx, callback)
+	}
+}
+
+// ResultFunc returns a results.ResultFunc calling next, when not nil, then HandleResult if
+// next succeeded.
+func (t *Tracker) ResultFunc(next results.ResultFunc[results.Result]) results.ResultFunc[results.Result] {
+	return func(ctx context.Context, result *results.Result) error {
+		if next != nil {
+			if err := next(ctx, result); err != nil {
+				return err
+			}
+		}
+		return t.HandleResult(ctx, result)
+	}
+}
+
+// QueueTimeoutFunc returns a results.ResultFunc calling next, when not nil, then
+// HandleQueueTimeout if next succeeded.
+func (t *Tracker) QueueTimeoutFunc(next results.ResultFunc[results.QueueTimeout]) results.ResultFunc[results.QueueTimeout] {
+	return func(ctx context.Context, timeout *results.QueueTimeout) error {
+		if next != nil {
+			if err := next(ctx, timeout); err != nil {
+				return err
+			}
+		}
+		return t.HandleQueueTimeout(ctx, timeout)
+	}
+}
+
+// stateOf returns the state of a payment completed with code.
+func stateOf(code resultcodes.Code) State {
+	switch code {
+	case resultcodes.Success:
+		return Succeeded
+	case resultcodes.TransactionExpired, resultcodes.UserUnreachable:
+		return TimedOut
+	}
+	return Failed
+}
+
+// complete moves the payment identified by update to the state of update, tracking it when
+// the notification arrived before its acknowledgement. Payments that already left Pending keep
+// their state, M-Pesa may post a notification more than once.
+func (t *Tracker) complete(update Payment) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.prune()
+	e := t.lookup(update.ids())
+	if e == nil {
+		e = t.add(Payment{})
+	}
+	e.payment.merge(update)
+	t.index(e)
+	if e.payment.State == Pending {
+		t.settle(e, update)
+	}
+	t.reverseOriginal(e)
+}
+
+// reverseOriginal moves the payment reversed by the reversal of e to Reversed once the reversal
+// succeeded, whichever of its acknowledgement and result was received last.
+func (t *Tracker) reverseOriginal(e *entry) {
+	reversal := e.payment
+	if reversal.State != Succeeded || reversal.OriginalTransactionID == "" {
+		return
+	}
+
+	if original, ok := t.entries[reversal.OriginalTransactionID]; ok && original != e && original.payment.State == Succeeded {
+		t.settle(original, Payment{State: Reversed, ResultCode: reversal.ResultCode, ResultDesc: reversal.ResultDesc, Result: reversal.Result})
+	}
+}
+
+// settle sets the outcome of the payment of e from update and wakes its waiters.
+func (t *Tracker) settle(e *entry, update Payment) {
+	e.payment.State = update.State
+	e.payment.ResultCode = update.ResultCode
+	e.payment.ResultDesc = update.ResultDesc
+	e.payment.Result = update.Result
+	e.payment.UpdatedAt = time.Now()
+	if e.payment.State != Pending {
+		select {
+		case <-e.done:
+		default:
+			close(e.done)
+		}
+	}
+}
+
+// lookup returns the entry tracked under one of ids, nil if there is none.
+func (t *Tracker) lookup(ids []string) *entry {
+	for _, id := range ids {
+		if e, ok := t.entries[id]; ok {
+			return e
+		}
+	}
+	return nil
+}
+
+// add creates a Pending entry for p, unless p has a state.
+func (t *Tracker) add(p Payment) *entry {
+	now := time.Now()
+	e := &entry{payment: p, done: make(chan struct{})}
+	if e.payment.State == "" {
+		e.payment.State = Pending
+	}
+	e.payment.CreatedAt, e.payment.UpdatedAt = now, now
+	if e.payment.State != Pending {
+		close(e.done)
+	}
+	return e
+}
+
+// index indexes e under the identifiers of its payment that are not taken by another payment.
+func (t *Tracker) index(e *entry) {
+	for _, id := range e.payment.ids() {
+		if _, ok := t.entries[id]; !ok {
+			t.entries[id] = e
+		}
+	}
+}
+
+// remove drops e from the index.
+func (t *Tracker) remove(e *entry) {
+	for _, id := range e.payment.ids() {
+		if t.entries[id] == e {
+			delete(t.entries, id)
+		}
+	}
+}
+
+// prune drops the payments that did not change within the retention, at most once a minute.
+func (t *Tracker) prune() {
+	now := time.Now()
+	if now.Sub(t.pruned) < time.Minute {
+		return
+	}
+	t.pruned = now
+
+	for _, e := range t.entries {
+		if now.Sub(e.payment.UpdatedAt) > t.retention {
+			t.remove(e)
+		}
+	}
+}
+
+// hasState reports whether states holds state.
+func hasState(states []State, state State) bool {
+	for _, s := range states {
+		if s == state {
+			return true
+		}
+	}
+	return false
+}
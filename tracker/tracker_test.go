@@ -0,0 +1,191 @@
+package tracker_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/coleYab/mpesasdk"
+	"github.com/coleYab/mpesasdk/b2c"
+	"github.com/coleYab/mpesasdk/c2b"
+	"github.com/coleYab/mpesasdk/common"
+	"github.com/coleYab/mpesasdk/mpesatest"
+	"github.com/coleYab/mpesasdk/results"
+	"github.com/coleYab/mpesasdk/tracker"
+	"github.com/coleYab/mpesasdk/transaction"
+)
+
+// newReceiver serves the callbacks of the simulator to the tracker.
+func newReceiver(payments *tracker.Tracker) *httptest.Server {
+	mux := http.NewServeMux()
+	mux.Handle("/stk", c2b.NewSTKCallbackHandler(payments.STKCallbackFunc(nil)))
+	mux.Handle("/result", results.NewResultHandler(payments.ResultFunc(nil)))
+	mux.Handle("/timeout", results.NewQueueTimeoutHandler(payments.QueueTimeoutFunc(nil)))
+	return httptest.NewTLSServer(mux)
+}
+
+func stkPush(receiver *httptest.Server) c2b.STKPushPaymentRequest {
+	return c2b.STKPushPaymentRequest{
+		BusinessShortCode: 554433,
+		TransactionType:   common.CustomerPayBillOnlineTransaction,
+		Amount:            10,
+		PartyA:            "251700000000",
+		PartyB:            "554433",
+		PhoneNumber:       "251700000000",
+		CallBackURL:       receiver.URL + "/stk",
+		AccountReference:  "INV-1",
+		TransactionDesc:   "Payment",
+	}
+}
+
+func TestSTKPushOutcomesAreTracked(t *testing.T) {
+	sim := mpesatest.NewServer()
+	defer sim.Close()
+	payments := tracker.New()
+	receiver := newReceiver(payments)
+	defer receiver.Close()
+
+	client, err := sim.NewClient(mpesasdk.WithTracker(payments))
+	if err != nil {
+		t.Fatalf("failed to create client: %v", err)
+	}
+
+	tests := []struct {
+		outcome mpesatest.Outcome
+		state   tracker.State
+		code    string
+	}{
+		{mpesatest.OutcomeSuccess, tracker.Succeeded, "0"},
+		{mpesatest.OutcomeCancelledByUser, tracker.Failed, "1032"},
+		{mpesatest.OutcomeTimeout, tracker.TimedOut, "1037"},
+	}
+	for _, tt := range tests {
+		sim.SetOutcome(tt.outcome)
+		res, err := client.STKPushPaymentRequest("passkey", stkPush(receiver))
+		if err != nil {
+			t.Fatalf("expecting stk push to be accepted but got: %v", err)
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		payment, err := payments.Await(ctx, res.CheckoutRequestID)
+		cancel()
+		if err != nil {
+			t.Fatalf("expecting the callback to complete the payment but got: %v", err)
+		}
+		if payment.State != tt.state || payment.ResultCode != tt.code || payment.MerchantRequestID != res.MerchantRequestID {
+			t.Fatalf("unexpected payment %+v", payment)
+		}
+		if _, ok := payment.Result.(*c2b.STKCallback); !ok {
+			t.Fatalf("expecting the callback as result but got %T", payment.Result)
+		}
+		if tt.state == tracker.Succeeded {
+			if state, _ := payments.State(payment.TransactionID); payment.TransactionID == "" || state != tracker.Succeeded {
+				t.Fatalf("expecting the payment to be indexed by its receipt %q", payment.TransactionID)
+			}
+		}
+	}
+
+	if got := len(payments.List(tracker.Failed, tracker.TimedOut)); got != 2 {
+		t.Fatalf("expecting 2 unsuccessful payments but got %v", got)
+	}
+}
+
+func TestReversalMovesThePaymentToReversed(t *testing.T) {
+	sim := mpesatest.NewServer()
+	defer sim.Close()
+	payments := tracker.New()
+	receiver := newReceiver(payments)
+	defer receiver.Close()
+
+	client, err := sim.NewClient(mpesasdk.WithTracker(payments))
+	if err != nil {
+		t.Fatalf("failed to create client: %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	payout, err := client.MakeB2CPaymentRequestCtx(ctx, b2c.B2CRequest{
+		InitiatorName:      "apiuser",
+		SecurityCredential: "credential",
+		CommandID:          common.BusinessPaymentCommand,
+		Amount:             100,
+		PartyA:             600000,
+		PartyB:             251700000000,
+		Remarks:            "Payout",
+		QueueTimeOutURL:    receiver.URL + "/timeout",
+		ResultURL:          receiver.URL + "/result",
+	})
+	if err != nil {
+		t.Fatalf("expecting b2c request to be accepted but got: %v", err)
+	}
+	payment, err := payments.Await(ctx, payout.OriginatorConversatonId)
+	if err != nil || payment.State != tracker.Succeeded || payment.ConversationID != payout.ConversationID {
+		t.Fatalf("unexpected payment %+v: %v", payment, err)
+	}
+
+	reversal, err := client.ReverseTransactionCtx(ctx, transaction.TransactionReversalRequest{
+		Initiator:              "apiuser",
+		SecurityCredential:     "credential",
+		CommandID:              common.TransactionReversalCommand,
+		TransactionID:          payment.TransactionID,
+		Amount:                 100,
+		ReceiverParty:          "600000",
+		RecieverIdentifierType: common.ShortCodeIdentifierType,
+		QueueTimeOutURL:        receiver.URL + "/timeout",
+		ResultURL:              receiver.URL + "/result",
+		Remarks:                "Refund",
+	})
+	if err != nil {
+		t.Fatalf("expecting reversal to be accepted but got: %v", err)
+	}
+	if _, err := payments.Await(ctx, reversal.ConversationID); err != nil {
+		t.Fatalf("expecting the reversal to complete but got: %v", err)
+	}
+
+	if state, _ := payments.State(payout.ConversationID); state != tracker.Reversed {
+		t.Fatalf("expecting the payout to be reversed but got %v", state)
+	}
+}
+
+func TestCallbacksBeforeTheAcknowledgementAreKept(t *testing.T) {
+	payments := tracker.New()
+
+	payments.HandleQueueTimeout(context.Background(), &results.QueueTimeout{OriginatorConversationID: "oc-1"})
+	payments.Track(tracker.Payment{Operation: common.B2COperation, ConversationID: "AG-1", OriginatorConversationID: "oc-1"})
+
+	payment, err := payments.Await(context.Background(), "AG-1")
+	if err != nil || payment.State != tracker.TimedOut || payment.Operation != common.B2COperation {
+		t.Fatalf("unexpected payment %+v: %v", payment, err)
+	}
+
+	// A late result does not change the outcome
+	payments.HandleResult(context.Background(), &results.Result{ConversationID: "AG-1", ResultCode: "0"})
+	if state, _ := payments.State("oc-1"); state != tracker.TimedOut {
+		t.Fatalf("expecting the payment to stay timed out but got %v", state)
+	}
+}
+
+func TestAwait(t *testing.T) {
+	payments := tracker.New()
+
+	if _, err := payments.Await(context.Background(), "unknown"); !errors.Is(err, tracker.ErrNotTracked) {
+		t.Fatalf("expecting ErrNotTracked but got: %v", err)
+	}
+
+	payments.Track(tracker.Payment{Operation: common.STKPushOperation, MerchantRequestID: "MR-1", CheckoutRequestID: "ws_CO-1"})
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	payment, err := payments.Await(ctx, "ws_CO-1")
+	if !errors.Is(err, context.DeadlineExceeded) || payment.State != tracker.Pending {
+		t.Fatalf("expecting the wait to time out on a pending payment but got %+v: %v", payment, err)
+	}
+
+	payments.Remove("MR-1")
+	if _, ok := payments.Get("ws_CO-1"); ok {
+		t.Fatalf("expecting the payment to be removed under all of its identifiers")
+	}
+}
@@ -3,13 +3,15 @@
 package transaction
 
 import (
+	"cmp"
 	"encoding/json"
-	"fmt"
 	"io"
 	"net/http"
 
+
 	"github.com/coleYab/mpesasdk/common"
 	sdkError "github.com/coleYab/mpesasdk/errors"
+	"github.com/coleYab/mpesasdk/validation"
 )
 
 // TransactionReversalRequest represents the parameters for reversing a transaction.
@@ -41,6 +43,13 @@ type TransactionReversalRequest struct {
 	Remarks                 string                   `json:"Remarks"`
 	Occasion                string                   `json:"Occasion"`
 	OriginatorConversationID string                  `json:"OriginatorConversationID"`
+
+	securityCredential string
+}
+
+// SetSecurityCredential sets the SecurityCredential used when the request leaves it empty.
+func (t *TransactionReversalRequest) SetSecurityCredential(credential string) {
+	t.securityCredential = credential
 }
 
 // TransactionReversalSuccessResponse represents a successful response for a reversal request.
@@ -55,44 +64,71 @@ type TransactionReversalSuccessResponse common.MpesaSuccessResponse
 //   - An instance of TransactionReversalSuccessResponse if the reversal was successful.
 //   - An error if the response indicates a failure or the decoding fails.
 func (t *TransactionReversalRequest) DecodeResponse(res *http.Response) (interface{}, error) {
-	bodyData, _ := io.ReadAll(res.Body)
+	bodyData, err := io.ReadAll(res.Body)
+	if err != nil {
+		return TransactionReversalSuccessResponse{}, sdkError.NewResponseReadError(res.StatusCode, bodyData, err)
+	}
+
 	responseData := TransactionReversalSuccessResponse{}
-	err := json.Unmarshal(bodyData, &responseData)
+	err = json.Unmarshal(bodyData, &responseData)
 	if err != nil {
-		return TransactionReversalSuccessResponse{}, err
+		return TransactionReversalSuccessResponse{}, sdkError.NewUnexpectedResponseError(res.StatusCode, bodyData, err)
 	}
 
 	if responseData.ResponseCode != "0" {
-		errorResponseData := common.MpesaErrorResponse{}
-		err := json.Unmarshal(bodyData, &errorResponseData)
+		errorResponseData, err := common.ParseErrorResponse(bodyData)
 		if err != nil {
-			return TransactionReversalSuccessResponse{}, err
+			return TransactionReversalSuccessResponse{}, sdkError.NewUnexpectedResponseError(res.StatusCode, bodyData, err)
 		}
-		return TransactionReversalSuccessResponse{}, t.decodeError(errorResponseData)
+		return TransactionReversalSuccessResponse{}, t.decodeError(res, bodyData, errorResponseData)
 	}
 
 	return responseData, nil
 }
 
-// FillDefaults is a placeholder for initializing default values in TransactionReversalRequest.
-func (t *TransactionReversalRequest) FillDefaults() {}
+// FillDefaults initializes default values for the TransactionReversalRequest.
+// An empty CommandID is set to TransactionReversal.
+func (t *TransactionReversalRequest) FillDefaults() {
+	if t.CommandID == "" {
+		t.CommandID = common.TransactionReversalCommand
+	}
+	if t.SecurityCredential == "" {
+		t.SecurityCredential = t.securityCredential
+	}
+}
 
 // Validate checks the validity of the TransactionReversalRequest parameters.
 //
 // Returns:
 //   - An error if validation fails, or nil if the request is valid.
 func (t *TransactionReversalRequest) Validate() error {
-	return nil
+	v := validation.New()
+	v.Required("Initiator", t.Initiator)
+	v.Required("SecurityCredential", cmp.Or(t.SecurityCredential, t.securityCredential))
+	if t.CommandID != "" {
+		v.OneOf("CommandID", t.CommandID, common.TransactionReversalCommand)
+	}
+	v.Alphanumeric("TransactionID", t.TransactionID)
+	v.Min("Amount", t.Amount, 1)
+	v.Shortcode("ReceiverParty", t.ReceiverParty)
+	v.Required("RecieverIdentifierType", string(t.RecieverIdentifierType))
+	v.Length("Remarks", t.Remarks, 2, validation.MaxRemarksLength)
+	v.URL("QueueTimeOutURL", t.QueueTimeOutURL)
+	v.URL("ResultURL", t.ResultURL)
+	v.MaxLength("Occasion", t.Occasion, validation.MaxOccasionLength)
+	return v.Err()
 }
 
 // decodeError processes an M-Pesa error response and returns a structured error.
 //
 // Parameters:
+//   - res: The HTTP response the error was decoded from.
+//   - body: The body of the response.
 //   - e: The MpesaErrorResponse containing error details.
 //
 // Returns:
 //   - An error describing the failure with details from the response.
-func (t *TransactionReversalRequest) decodeError(e common.MpesaErrorResponse) error {
-	return sdkError.NewSDKError(e.ErrorCode, fmt.Sprintf("Request %v failed due to %v", e.RequestId, e.ErrorMessage))
+func (t *TransactionReversalRequest) decodeError(res *http.Response, body []byte, e common.MpesaErrorResponse) error {
+	return sdkError.NewAPIError(res.StatusCode, e.RequestId, e.ErrorCode, e.ErrorMessage, body)
 }
 
@@ -1,13 +1,14 @@
 package transaction
 
 import (
+	"cmp"
 	"encoding/json"
-	"fmt"
 	"io"
 	"net/http"
 
 	"github.com/coleYab/mpesasdk/common"
 	sdkError "github.com/coleYab/mpesasdk/errors"
+	"github.com/coleYab/mpesasdk/validation"
 )
 
 // TransactionStatusRequest represents the parameters for querying the status of a transaction.
@@ -37,6 +38,13 @@ type TransactionStatusRequest struct {
 	ResultURL                string               `json:"ResultURL"`
 	SecurityCredential       string               `json:"SecurityCredential"`
 	TransactionID            string               `json:"TransactionID"`
+
+	securityCredential string
+}
+
+// SetSecurityCredential sets the SecurityCredential used when the request leaves it empty.
+func (t *TransactionStatusRequest) SetSecurityCredential(credential string) {
+	t.securityCredential = credential
 }
 
 // TransactionStatusSuccessResponse represents a successful response for a status query.
@@ -44,20 +52,23 @@ type TransactionStatusSuccessResponse common.MpesaSuccessResponse
 
 // DecodeResponse decodes the HTTP response for a transaction status query.
 func (t *TransactionStatusRequest) DecodeResponse(res *http.Response) (interface{}, error) {
-	bodyData, _ := io.ReadAll(res.Body)
+	bodyData, err := io.ReadAll(res.Body)
+	if err != nil {
+		return TransactionStatusSuccessResponse{}, sdkError.NewResponseReadError(res.StatusCode, bodyData, err)
+	}
+
 	responseData := TransactionStatusSuccessResponse{}
-	err := json.Unmarshal(bodyData, &responseData)
+	err = json.Unmarshal(bodyData, &responseData)
 	if err != nil {
-		return TransactionStatusSuccessResponse{}, err
+		return TransactionStatusSuccessResponse{}, sdkError.NewUnexpectedResponseError(res.StatusCode, bodyData, err)
 	}
 
 	if responseData.ResponseCode != "0" {
-		errorResponseData := common.MpesaErrorResponse{}
-		err := json.Unmarshal(bodyData, &errorResponseData)
+		errorResponseData, err := common.ParseErrorResponse(bodyData)
 		if err != nil {
-			return TransactionStatusSuccessResponse{}, err
+			return TransactionStatusSuccessResponse{}, sdkError.NewUnexpectedResponseError(res.StatusCode, bodyData, err)
 		}
-		return TransactionStatusSuccessResponse{}, t.decodeError(errorResponseData)
+		return TransactionStatusSuccessResponse{}, t.decodeError(res, bodyData, errorResponseData)
 	}
 
 	return responseData, nil
@@ -66,14 +77,27 @@ func (t *TransactionStatusRequest) DecodeResponse(res *http.Response) (interface
 // FillDefaults initializes default values for the TransactionStatusRequest.
 func (t *TransactionStatusRequest) FillDefaults() {
 	t.CommandID = common.TransactionStatusCommand
+	if t.SecurityCredential == "" {
+		t.SecurityCredential = t.securityCredential
+	}
 }
 
 // Validate checks the validity of the TransactionStatusRequest parameters.
 func (t *TransactionStatusRequest) Validate() error {
-	return nil
+	v := validation.New()
+	v.Required("Initiator", t.Initiator)
+	v.Required("SecurityCredential", cmp.Or(t.SecurityCredential, t.securityCredential))
+	v.Alphanumeric("TransactionID", t.TransactionID)
+	v.OneOf("IdentifierType", t.IdentifierType, common.MsisdnIdentifierType, common.TillNumberIdentifierType, common.ShortCodeIdentifierType)
+	v.Party("PartyA", t.PartyA, t.IdentifierType)
+	v.Length("Remarks", t.Remarks, 2, validation.MaxRemarksLength)
+	v.URL("QueueTimeOutURL", t.QueueTimeOutURL)
+	v.URL("ResultURL", t.ResultURL)
+	v.MaxLength("Occasion", t.Occasion, validation.MaxOccasionLength)
+	return v.Err()
 }
 
 // decodeError processes an M-Pesa error response and returns a structured error.
-func (t *TransactionStatusRequest) decodeError(e common.MpesaErrorResponse) error {
-	return sdkError.NewSDKError(e.ErrorCode, fmt.Sprintf("Request %v failed due to %v", e.RequestId, e.ErrorMessage))
+func (t *TransactionStatusRequest) decodeError(res *http.Response, body []byte, e common.MpesaErrorResponse) error {
+	return sdkError.NewAPIError(res.StatusCode, e.RequestId, e.ErrorCode, e.ErrorMessage, body)
 }
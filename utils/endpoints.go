@@ -0,0 +1,100 @@
+package utils
+
+import (
+	"strings"
+	"sync"
+
+	"github.com/coleYab/mpesasdk/common"
+)
+
+// Default hosts of the M-Pesa API.
+const (
+	SandboxBaseURL    = "https://apisandbox.safaricom.et"
+	ProductionBaseURL = "https://api.safaricom.et"
+)
+
+// DefaultEndpointPaths lists the default path of every operation supported by the SDK.
+var DefaultEndpointPaths = map[common.Operation]string{
+	common.TokenOperation:               "/v1/token/generate",
+	common.RegisterURLOperation:         "/v1/c2b-register-url/register",
+	common.SimulateC2BOperation:         "/mpesa/b2c/simulatetransaction/v1/request",
+	common.STKPushOperation:             "/mpesa/stkpush/v1/processrequest",
+	common.STKPushQueryOperation:        "/mpesa/stkpushquery/v1/query",
+	common.B2COperation:                 "/mpesa/b2c/v2/paymentrequest",
+	common.TransactionStatusOperation:   "/mpesa/transactionstatus/v1/query",
+	common.AccountBalanceOperation:      "/mpesa/accountbalance/v1/query",
+	common.TransactionReversalOperation: "/mpesa/reversal/v1/request",
+}
+
+// DefaultBaseURL returns the default host of the M-Pesa API for the environment.
+func DefaultBaseURL(env common.Enviroment) string {
+	if env == common.PRODUCTION {
+		return ProductionBaseURL
+	}
+	return SandboxBaseURL
+}
+
+// Endpoints resolves the URL of every operation. It starts with the default host of the
+// environment and the DefaultEndpointPaths, both of which can be overridden, e.g. to target
+// a local stub, a proxy, a Kenya Daraja deployment or a different version of an API.
+//
+// Endpoints is safe for concurrent use.
+type Endpoints struct {
+	mu      sync.RWMutex
+	baseURL string
+	paths   map[common.Operation]string
+}
+
+// NewEndpoints creates an Endpoints using the default hosts and paths.
+//
+// Returns:
+//   - A pointer to the initialized Endpoints.
+func NewEndpoints() *Endpoints {
+	paths := make(map[common.Operation]string, len(DefaultEndpointPaths))
+	for op, path := range DefaultEndpointPaths {
+		paths[op] = path
+	}
+	return &Endpoints{paths: paths}
+}
+
+// SetBaseURL overrides the host of every operation.
+// An empty baseURL restores the environment's default host.
+func (e *Endpoints) SetBaseURL(baseURL string) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.baseURL = strings.TrimSuffix(baseURL, "/")
+}
+
+// SetPath overrides the path of a single operation.
+//
+// Example:
+//
+//	endpoints.SetPath(common.B2COperation, "/mpesa/b2c/v1/paymentrequest")
+func (e *Endpoints) SetPath(op common.Operation, path string) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.paths[op] = path
+}
+
+// BaseURL returns the host requests for the environment are sent to.
+func (e *Endpoints) BaseURL(env common.Enviroment) string {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+
+	if e.baseURL != "" {
+		return e.baseURL
+	}
+	return DefaultBaseURL(env)
+}
+
+// Path returns the path of the operation.
+func (e *Endpoints) Path(op common.Operation) string {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	return e.paths[op]
+}
+
+// URL returns the full URL of the operation for the environment.
+func (e *Endpoints) URL(env common.Enviroment, op common.Operation) string {
+	return e.BaseURL(env) + e.Path(op)
+}
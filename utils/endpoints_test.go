@@ -0,0 +1,32 @@
+package utils_test
+
+import (
+	"testing"
+
+	"github.com/coleYab/mpesasdk/common"
+	utils "github.com/coleYab/mpesasdk/utils"
+)
+
+func TestEndpoints(t *testing.T) {
+	endpoints := utils.NewEndpoints()
+
+	if url := endpoints.URL(common.PRODUCTION, common.B2COperation); url != "https://api.safaricom.et/mpesa/b2c/v2/paymentrequest" {
+		t.Fatalf("unexpected default url %v", url)
+	}
+
+	endpoints.SetBaseURL("https://sandbox.safaricom.co.ke/")
+	endpoints.SetPath(common.B2COperation, "/mpesa/b2c/v1/paymentrequest")
+
+	if url := endpoints.URL(common.SANDBOX, common.B2COperation); url != "https://sandbox.safaricom.co.ke/mpesa/b2c/v1/paymentrequest" {
+		t.Fatalf("unexpected overridden url %v", url)
+	}
+
+	if path := utils.DefaultEndpointPaths[common.B2COperation]; path != "/mpesa/b2c/v2/paymentrequest" {
+		t.Fatalf("overriding a path must not change the defaults but got %v", path)
+	}
+
+	endpoints.SetBaseURL("")
+	if url := endpoints.URL(common.SANDBOX, common.TokenOperation); url != "https://apisandbox.safaricom.et/v1/token/generate" {
+		t.Fatalf("unexpected url after reset %v", url)
+	}
+}
@@ -2,7 +2,9 @@ package utils
 
 import (
 	"encoding/base64"
+	"encoding/json"
 	"fmt"
+	"net/http"
 	"time"
 )
 
@@ -24,3 +26,18 @@ func GenerateTimestampAndPassword(shortcode uint, passkey string) (string, strin
 	password := fmt.Sprintf("%d%s%s", shortcode, passkey, timestamp)
 	return timestamp, base64.StdEncoding.EncodeToString([]byte(password))
 }
+
+// WriteJSON serializes v as JSON and writes it to w with the given status code.
+//
+// Parameters:
+//   - w: The response writer to write to.
+//   - status: The HTTP status code of the response.
+//   - v: The value to encode as the response body.
+//
+// Returns:
+//   - An error if encoding or writing the body fails.
+func WriteJSON(w http.ResponseWriter, status int, v interface{}) error {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	return json.NewEncoder(w).Encode(v)
+}
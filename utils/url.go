@@ -24,11 +24,7 @@ import (
 // Example:
 //   url := ConstructURL(common.SANDBOX, "/mpesa/accountbalance/v1/query")
 func ConstructURL(env common.Enviroment, endpoint string) string {
-    baseURL := "https://apisandbox.safaricom.et"
-    if env == common.PRODUCTION {
-        baseURL = "https://api.safaricom.et"
-    }
-    return fmt.Sprintf("%s%s", baseURL, endpoint)
+    return fmt.Sprintf("%s%s", DefaultBaseURL(env), endpoint)
 }
 
 // ValidateURL validates that the given URL is a properly formatted HTTPS URL.
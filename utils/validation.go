@@ -4,6 +4,8 @@ import (
 	"fmt"
 	"regexp"
 	"strings"
+
+	sdkError "github.com/coleYab/mpesasdk/errors"
 )
 
 // ValidateEthiopianPhoneNumber validates whether a given phone number is a valid Ethiopian Safaricom number.
@@ -13,6 +15,8 @@ import (
 //   - Must start with "2517" (the country code and prefix for Safaricom numbers).
 //   - The remaining 8 digits must be numeric.
 //
+// Numbers typed by customers, e.g. "0712 345 678", can be normalized first with msisdn.Normalize.
+//
 // Parameters:
 //   - phoneNumber: The phone number string to validate.
 //
@@ -29,16 +33,14 @@ func ValidateEthiopianPhoneNumber(phoneNumber string) error {
 	phoneNumber = strings.TrimSpace(phoneNumber)
 
 	if len(phoneNumber) != 12 || !strings.HasPrefix(phoneNumber, "2517") {
-        fmt.Printf("Phone no: %v, with len, %v failed here", phoneNumber, len(phoneNumber))
-        return fmt.Errorf("mpesasdk: validation error: Invalid Safaricom Phone Number")
+        return sdkError.ValidationError("invalid Safaricom phone number")
 	}
 
 	// Ensure the rest of the phone number consists of digits (after '251')
 	phonePart := phoneNumber[4:] // Exclude '2517' part
     matches, _ := regexp.MatchString("^[0-9]{8}$", phonePart)
     if !matches {
-        fmt.Printf("Phone no: %v, with len, %v failed there", phoneNumber, len(phoneNumber))
-        return fmt.Errorf("mpesasdk: validation error: Invalid Safaricom Phone Number")
+        return sdkError.ValidationError("invalid Safaricom phone number")
     }
 
     return nil
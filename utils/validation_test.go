@@ -8,20 +8,22 @@ import (
 )
 
 func TestValidateString(t *testing.T) {
-	testCases := map[string][]int{
-		"Value 1": {1, 2},
-		"Value 2": {-1, 4},
-		"Value 3": {1, 8},
-		"Value 4": {8, 10},
+	testCases := []struct {
+		value  string
+		limits []int
+	}{
+		{"Value 1", []int{1, 2}},
+		{"Value 2", []int{-1, 4}},
+		{"Value 3", []int{1, 8}},
+		{"Value 4", []int{8, 10}},
 	}
 
 	expectErr := []bool{
 		true, true, false, true,
 	}
 
-	idx := 0
-	for k, v := range testCases {
-		err := utils.ValidateString(k, v[0], v[1])
+	for idx, tc := range testCases {
+		err := utils.ValidateString(tc.value, tc.limits[0], tc.limits[1])
 
 		if !expectErr[idx] && err != nil {
 			log.Fatalf("Test %v: Expecting no errors but got: %v", idx, err.Error())
@@ -29,7 +31,6 @@ func TestValidateString(t *testing.T) {
 		if expectErr[idx] && err == nil {
 			log.Fatalf("Test %v: Expecting errors but got nil insted", idx)
 		}
-		idx += 1
 	}
 
 }
@@ -97,10 +98,11 @@ func TestValidateEthiopianPhoneNumber(t *testing.T) {
 
 	for phone, isValid := range phoneNumbers {
 		err := utils.ValidateEthiopianPhoneNumber(phone)
-		if !isValid && err != nil {
-            log.Fatalf("Error: expecing error bu)
+		if !isValid && err == nil {
+			log.Fatalf("Error: expecting error for %v but got nil instead", phone)
 		}
-		if isValid && err == nil {
+		if isValid && err != nil {
+			log.Fatalf("Error: expecting %v to be valid but got: %v", phone, err)
 		}
 	}
 }
@@ -0,0 +1,247 @@
+// Package validation checks the fields of requests against the limits documented by the M-Pesa
+// API before they are sent.
+//
+// Rules are declared field by field on a Validator, which collects every failure instead of
+// stopping at the first one:
+//
+//	v := validation.New()
+//	v.Required("Initiator", r.Initiator)
+//	v.Length("Remarks", r.Remarks, 2, validation.MaxRemarksLength)
+//	v.URL("ResultURL", r.ResultURL)
+//	return v.Err()
+//
+// The error returned by Err is an *errors.SDKError of kind errors.ErrValidation whose cause is
+// the ValidationErrors naming each offending field and rule:
+//
+//	var fields validation.ValidationErrors
+//	if stderrors.As(err, &fields) {
+//	    for _, f := range fields {
+//	        log.Printf("%v failed rule %v: %v", f.Field, f.Rule, f.Message)
+//	    }
+//	}
+package validation
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+
+	"github.com/coleYab/mpesasdk/common"
+	sdkError "github.com/coleYab/mpesasdk/errors"
+)
+
+// Limits documented by the M-Pesa API.
+const (
+	// MaxRemarksLength is the maximum length of the Remarks of a request.
+	MaxRemarksLength = 100
+
+	// MaxOccasionLength is the maximum length of the Occasion of a request.
+	MaxOccasionLength = 100
+
+	// MaxAccountReferenceLength is the maximum length of the AccountReference of an STK push.
+	MaxAccountReferenceLength = 12
+
+	// MaxTransactionDescLength is the maximum length of the TransactionDesc of an STK push.
+	MaxTransactionDescLength = 13
+
+	// MinShortcodeLength and MaxShortcodeLength bound the digits of a shortcode or till number.
+	MinShortcodeLength = 5
+	MaxShortcodeLength = 7
+
+	// MinMSISDNLength and MaxMSISDNLength bound the digits of a phone number in international
+	// format, without the leading "+".
+	MinMSISDNLength = 9
+	MaxMSISDNLength = 15
+)
+
+// Names of the rules reported in a FieldError.
+const (
+	RuleRequired     = "required"
+	RuleLength       = "length"
+	RuleMin          = "min"
+	RuleOneOf        = "oneof"
+	RuleURL          = "url"
+	RuleShortcode    = "shortcode"
+	RuleMSISDN       = "msisdn"
+	RuleAlphanumeric = "alphanumeric"
+)
+
+// FieldError describes a field failing a rule.
+//
+// Fields:
+//   - Field: The name of the field, as serialized in the request.
+//   - Rule: The name of the rule the field failed, one of the Rule constants.
+//   - Message: A human readable description of the failure.
+type FieldError struct {
+	Field   string
+	Rule    string
+	Message string
+}
+
+// Error implements the error interface for FieldError.
+func (e FieldError) Error() string {
+	return e.Field + " " + e.Message
+}
+
+// ValidationErrors lists the fields of a request failing their rules.
+type ValidationErrors []FieldError
+
+// Error implements the error interface for ValidationErrors.
+func (v ValidationErrors) Error() string {
+	messages := make([]string, len(v))
+	for i, e := range v {
+		messages[i] = e.Error()
+	}
+	return strings.Join(messages, "; ")
+}
+
+// Is reports whether target is errors.ErrValidation.
+func (v ValidationErrors) Is(target error) bool {
+	return target == sdkError.ErrValidation
+}
+
+// Field returns the error of the named field, nil if it passed every rule.
+func (v ValidationErrors) Field(name string) *FieldError {
+	for i := range v {
+		if v[i].Field == name {
+			return &v[i]
+		}
+	}
+	return nil
+}
+
+// Validator collects the failures of the rules declared on the fields of a request.
+type Validator struct {
+	errs ValidationErrors
+}
+
+// New creates a Validator without failures.
+func New() *Validator {
+	return &Validator{}
+}
+
+// Fail records a failure of field, e.g. for a rule specific to a request.
+func (v *Validator) Fail(field, rule, message string) {
+	v.errs = append(v.errs, FieldError{Field: field, Rule: rule, Message: message})
+}
+
+// Required checks that value is not blank.
+func (v *Validator) Required(field, value string) {
+	if strings.TrimSpace(value) == "" {
+		v.Fail(field, RuleRequired, "is required")
+	}
+}
+
+// Length checks that value holds between minLen and maxLen characters.
+func (v *Validator) Length(field, value string, minLen, maxLen int) {
+	n := len([]rune(value))
+	switch {
+	case n == 0 && minLen > 0:
+		v.Fail(field, RuleRequired, "is required")
+	case n < minLen || n > maxLen:
+		v.Fail(field, RuleLength, fmt.Sprintf("must be between %v and %v characters long", minLen, maxLen))
+	}
+}
+
+// MaxLength checks the length of a field that may be left empty.
+func (v *Validator) MaxLength(field, value string, maxLen int) {
+	if len([]rune(value)) > maxLen {
+		v.Fail(field, RuleLength, fmt.Sprintf("must be at most %v characters long", maxLen))
+	}
+}
+
+// Min checks that value is at least least, e.g. that an amount is positive.
+func (v *Validator) Min(field string, value, least uint64) {
+	if value < least {
+		v.Fail(field, RuleMin, fmt.Sprintf("must be at least %v", least))
+	}
+}
+
+// OneOf checks that value is one of allowed. The values are compared with ==, so allowed must
+// hold values of the same type as value, such as the constants of common.CommandId.
+func (v *Validator) OneOf(field string, value any, allowed ...any) {
+	for _, a := range allowed {
+		if value == a {
+			return
+		}
+	}
+
+	names := make([]string, len(allowed))
+	for i, a := range allowed {
+		names[i] = fmt.Sprint(a)
+	}
+	v.Fail(field, RuleOneOf, fmt.Sprintf("must be one of %v but is %q", strings.Join(names, ", "), fmt.Sprint(value)))
+}
+
+// URL checks that value is an absolute HTTPS URL, as required for callback URLs.
+func (v *Validator) URL(field, value string) {
+	u, err := url.Parse(value)
+	if err != nil || u.Scheme != "https" || u.Host == "" {
+		v.Fail(field, RuleURL, "must be an absolute https URL")
+	}
+}
+
+// Shortcode checks that value is a shortcode or till number.
+func (v *Validator) Shortcode(field, value string) {
+	if !isDigits(value, MinShortcodeLength, MaxShortcodeLength) {
+		v.Fail(field, RuleShortcode, fmt.Sprintf("must be a shortcode of %v to %v digits", MinShortcodeLength, MaxShortcodeLength))
+	}
+}
+
+// MSISDN checks that value is a phone number in international format, e.g. 251712345678.
+func (v *Validator) MSISDN(field, value string) {
+	if !isDigits(value, MinMSISDNLength, MaxMSISDNLength) {
+		v.Fail(field, RuleMSISDN, "must be a phone number in international format")
+	}
+}
+
+// Party checks that value is a phone number when identifierType is common.MsisdnIdentifierType,
+// and a shortcode or till number otherwise.
+func (v *Validator) Party(field, value string, identifierType common.IdentifierType) {
+	if identifierType == common.MsisdnIdentifierType {
+		v.MSISDN(field, value)
+		return
+	}
+	v.Shortcode(field, value)
+}
+
+// Alphanumeric checks that value only holds letters and digits, such as an M-Pesa receipt.
+func (v *Validator) Alphanumeric(field, value string) {
+	if value == "" {
+		v.Fail(field, RuleRequired, "is required")
+		return
+	}
+	for _, r := range value {
+		if (r < '0' || r > '9') && (r < 'a' || r > 'z') && (r < 'A' || r > 'Z') {
+			v.Fail(field, RuleAlphanumeric, "must only hold letters and digits")
+			return
+		}
+	}
+}
+
+// Errors returns the failures recorded so far.
+func (v *Validator) Errors() ValidationErrors {
+	return v.errs
+}
+
+// Err returns nil when every rule passed, otherwise an *errors.SDKError of kind
+// errors.ErrValidation wrapping the ValidationErrors.
+func (v *Validator) Err() error {
+	if len(v.errs) == 0 {
+		return nil
+	}
+	return sdkError.NewDetailedError("VALIDATION_ERROR", v.errs.Error(), sdkError.Details{Kind: sdkError.ErrValidation, Cause: v.errs})
+}
+
+// isDigits reports whether s holds between minLen and maxLen ASCII digits.
+func isDigits(s string, minLen, maxLen int) bool {
+	if len(s) < minLen || len(s) > maxLen {
+		return false
+	}
+	for _, r := range s {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,101 @@
+package validation_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/coleYab/mpesasdk/b2c"
+	"github.com/coleYab/mpesasdk/c2b"
+	"github.com/coleYab/mpesasdk/common"
+	sdkError "github.com/coleYab/mpesasdk/errors"
+	"github.com/coleYab/mpesasdk/validation"
+)
+
+func TestValidatorCollectsEveryFailure(t *testing.T) {
+	req := b2c.B2CRequest{
+		InitiatorName:      "apiuser",
+		SecurityCredential: "credential",
+		CommandID:          "Payout",
+		PartyA:             600000,
+		PartyB:             251700000000,
+		Remarks:            "Payout",
+		QueueTimeOutURL:    "http://example.com/timeout",
+		ResultURL:          "https://example.com/result",
+	}
+
+	err := req.Validate()
+
+	var sdkErr *sdkError.SDKError
+	if !errors.As(err, &sdkErr) || sdkErr.Code() != "VALIDATION_ERROR" || !errors.Is(err, sdkError.ErrValidation) {
+		t.Fatalf("expecting a validation SDKError but got %v", err)
+	}
+
+	var fields validation.ValidationErrors
+	if !errors.As(err, &fields) || len(fields) != 3 {
+		t.Fatalf("expecting 3 field errors but got %v", err)
+	}
+
+	expected := map[string]string{
+		"CommandID":       validation.RuleOneOf,
+		"Amount":          validation.RuleMin,
+		"QueueTimeOutURL": validation.RuleURL,
+	}
+	for field, rule := range expected {
+		if f := fields.Field(field); f == nil || f.Rule != rule {
+			t.Errorf("expecting %v to fail rule %v but got %+v", field, rule, f)
+		}
+	}
+}
+
+func TestSTKPushLimits(t *testing.T) {
+	req := c2b.STKPushPaymentRequest{
+		BusinessShortCode: 554433,
+		TransactionType:   common.CustomerPayBillOnlineTransaction,
+		Amount:            10,
+		PartyA:            "251700000000",
+		PartyB:            "554433",
+		PhoneNumber:       "0700000000x",
+		CallBackURL:       "https://example.com/callback",
+		AccountReference:  "INV-000000001",
+		TransactionDesc:   "Payment",
+	}
+
+	var fields validation.ValidationErrors
+	if !errors.As(req.Validate(), &fields) || len(fields) != 2 {
+		t.Fatalf("expecting 2 field errors but got %v", fields)
+	}
+	if f := fields.Field("PhoneNumber"); f == nil || f.Rule != validation.RuleMSISDN {
+		t.Errorf("expecting the phone number to be rejected but got %+v", f)
+	}
+	if f := fields.Field("AccountReference"); f == nil || f.Rule != validation.RuleLength {
+		t.Errorf("expecting the account reference to be too long but got %+v", f)
+	}
+
+	req.PhoneNumber = "251700000000"
+	req.AccountReference = "INV-1"
+	if err := req.Validate(); err != nil {
+		t.Fatalf("expecting the request to be valid but got %v", err)
+	}
+}
+
+func TestLength(t *testing.T) {
+	tests := []struct {
+		value string
+		rule  string
+	}{
+		{"", validation.RuleRequired},
+		{"a", validation.RuleLength},
+		{"ok", ""},
+		{"ሰላም", ""},
+	}
+
+	for _, tt := range tests {
+		v := validation.New()
+		v.Length("Remarks", tt.value, 2, 3)
+
+		errs := v.Errors()
+		if tt.rule == "" && len(errs) != 0 || tt.rule != "" && (len(errs) != 1 || errs[0].Rule != tt.rule) {
+			t.Errorf("unexpected errors %v for %q", errs, tt.value)
+		}
+	}
+}